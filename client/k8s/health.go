@@ -17,6 +17,8 @@ const (
 	// Succeeded 正常终止
 	Succeeded   = "succeeded"
 	Terminating = "terminating"
+	// Suspended 已暂停
+	Suspended = "suspended"
 	// Failed 异常停止
 	Failed = "failed"
 	Evicte = "evicte"
@@ -56,6 +58,8 @@ const (
 	DockerDaemonNotReady = "dockerdaemonnotready"
 	// NetworkPluginNotReady 网络插件还没有完全启动
 	NetworkPluginNotReady = "networkpluginnotready"
+	// Unschedulable 没有满足条件的节点可以调度
+	Unschedulable = "unschedulable"
 )
 
 func Status(status string) health.Health {
@@ -77,6 +81,7 @@ func Status(status string) health.Health {
 	case
 		Succeeded,
 		Terminating,
+		Suspended,
 		CrashLoopBackOff,
 		Evicte:
 		return health.STOP
@@ -9,6 +9,7 @@ import (
 	"github.com/hosgf/element/uerrors"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	k8s "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -17,12 +18,48 @@ import (
 )
 
 func New(isDebug, isTest bool) *Kubernetes {
+	return newKubernetes(&options{isDebug: isDebug, isTest: isTest})
+}
+
+// NewWithClient 基于已有的客户端创建，便于接入 fake clientset 或自定义的客户端实现
+func NewWithClient(isDebug bool, api k8s.Interface, metricsApi metricsv.Interface) *Kubernetes {
+	return newKubernetes(&options{isDebug: isDebug, api: api, metricsApi: metricsApi})
+}
+
+// WithRegion 设置集群所属的区域，查询进程和资源时回填到结果中
+func (k *Kubernetes) WithRegion(region string) *Kubernetes {
+	k.region = region
+	return k
+}
+
+// Region 集群所属的区域
+func (k *Kubernetes) Region() string {
+	return k.region
+}
+
+// WithDynamic 设置动态客户端，用于 Gateway API 等未内置类型的资源
+func (k *Kubernetes) WithDynamic(dynamicApi dynamic.Interface) *Kubernetes {
+	k.dynamicApi = dynamicApi
+	k.ingress.resetGateway()
+	return k
+}
+
+func newKubernetes(o *options) *Kubernetes {
 	k := &Kubernetes{}
-	k.options = &options{isDebug: isDebug, isTest: isTest}
+	k.options = o
 	k.nodes = &nodesOperation{k.options}
 	k.namespace = &namespaceOperation{k.options}
-	k.service = &serviceOperation{k.options}
+	k.service = &serviceOperation{k8s: k, options: k.options}
+	k.ingress = &ingressOperation{options: k.options}
 	k.pods = &podsOperation{k.options}
+	k.jobs = &jobsOperation{k8s: k, options: k.options}
+	k.configs = &configOperation{k.options}
+	k.rollout = &rolloutOperation{k.options}
+	k.autoscaler = &autoscalerOperation{k8s: k, options: k.options}
+	k.networkPolicy = &networkPolicyOperation{k.options}
+	k.snapshot = &snapshotOperation{k8s: k, options: k.options}
+	k.diagnosis = &diagnosisOperation{k8s: k, options: k.options}
+	k.cache = &cacheOperation{k8s: k, options: k.options, caches: make(map[string]*namespaceCache), handlers: make(map[int]CacheHandler)}
 	k.storage = &storageOperation{k8s: k, options: k.options}
 	k.storageResource = &storageResourceOperation{k.options}
 	k.metrics = &metricsOperation{k.options}
@@ -34,10 +71,12 @@ func New(isDebug, isTest bool) *Kubernetes {
 type options struct {
 	isDebug    bool
 	isTest     bool
+	region     string
 	err        error
 	c          *rest.Config
-	api        *k8s.Clientset
-	metricsApi *metricsv.Clientset
+	api        k8s.Interface
+	metricsApi metricsv.Interface
+	dynamicApi dynamic.Interface
 }
 
 type Kubernetes struct {
@@ -45,7 +84,16 @@ type Kubernetes struct {
 	nodes           *nodesOperation
 	namespace       *namespaceOperation
 	service         *serviceOperation
+	ingress         *ingressOperation
 	pods            *podsOperation
+	jobs            *jobsOperation
+	configs         *configOperation
+	rollout         *rolloutOperation
+	autoscaler      *autoscalerOperation
+	networkPolicy   *networkPolicyOperation
+	snapshot        *snapshotOperation
+	diagnosis       *diagnosisOperation
+	cache           *cacheOperation
 	storage         *storageOperation
 	storageResource *storageResourceOperation
 	metrics         *metricsOperation
@@ -69,10 +117,46 @@ func (k *Kubernetes) Service() *serviceOperation {
 	return k.service
 }
 
+func (k *Kubernetes) Ingress() *ingressOperation {
+	return k.ingress
+}
+
 func (k *Kubernetes) Pod() *podsOperation {
 	return k.pods
 }
 
+func (k *Kubernetes) Job() *jobsOperation {
+	return k.jobs
+}
+
+func (k *Kubernetes) Config() *configOperation {
+	return k.configs
+}
+
+func (k *Kubernetes) Rollout() *rolloutOperation {
+	return k.rollout
+}
+
+func (k *Kubernetes) Autoscaler() *autoscalerOperation {
+	return k.autoscaler
+}
+
+func (k *Kubernetes) NetworkPolicy() *networkPolicyOperation {
+	return k.networkPolicy
+}
+
+func (k *Kubernetes) Snapshot() *snapshotOperation {
+	return k.snapshot
+}
+
+func (k *Kubernetes) Diagnosis() *diagnosisOperation {
+	return k.diagnosis
+}
+
+func (k *Kubernetes) Cache() *cacheOperation {
+	return k.cache
+}
+
 func (k *Kubernetes) Storage() *storageOperation {
 	return k.storage
 }
@@ -97,9 +181,21 @@ func (k *Kubernetes) Init(homePath string) error {
 		k.err = uerrors.WrapKubernetesError(ctx, err, "初始化Kubernetes配置")
 		return k.err
 	}
-	config.QPS = 50    // 每秒最大 50 个请求
-	config.Burst = 100 // 突发请求 100 个
+	return k.InitWithConfig(config)
+}
+
+// InitWithConfig 基于已有的 REST 配置初始化客户端，未设置限流时使用默认值
+func (k *Kubernetes) InitWithConfig(config *rest.Config) error {
+	ctx := context.Background()
+	if config.QPS <= 0 {
+		config.QPS = 50 // 每秒最大 50 个请求
+	}
+	if config.Burst <= 0 {
+		config.Burst = 100 // 突发请求 100 个
+	}
+	k.err = nil
 	k.c = config
+	var err error
 	k.api, err = k8s.NewForConfig(k.c)
 	if err != nil {
 		k.err = uerrors.WrapKubernetesError(ctx, err, "创建Kubernetes客户端")
@@ -110,6 +206,12 @@ func (k *Kubernetes) Init(homePath string) error {
 		k.err = uerrors.WrapKubernetesError(ctx, err, "创建Metrics客户端")
 		return k.err
 	}
+	k.dynamicApi, err = dynamic.NewForConfig(k.c)
+	if err != nil {
+		k.err = uerrors.WrapKubernetesError(ctx, err, "创建动态客户端")
+		return k.err
+	}
+	k.ingress.resetGateway()
 	return nil
 }
 
@@ -125,6 +227,10 @@ func (k *Kubernetes) Version() (string, error) {
 }
 
 func (k *Kubernetes) config(homePath string) string {
+	return kubeconfigPath(homePath)
+}
+
+func kubeconfigPath(homePath string) string {
 	if homePath != "" {
 		return filepath.Join(homePath, ".kube", "config")
 	}
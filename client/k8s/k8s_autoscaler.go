@@ -0,0 +1,346 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/uerrors"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MetricSourceType 自定义指标的来源
+type MetricSourceType string
+
+const (
+	MetricSourcePods     MetricSourceType = "Pods"     // 每个实例上报的指标，按平均值计算
+	MetricSourceExternal MetricSourceType = "External" // 集群外部的指标，例如消息队列长度
+)
+
+type autoscalerOperation struct {
+	*options
+	k8s *Kubernetes
+}
+
+// AutoscalingConfig 进程组的自动伸缩配置，配置后副本数由 HPA 管理
+type AutoscalingConfig struct {
+	MinReplicas int32          `json:"minReplicas,omitempty"` // 最小副本数，默认 1
+	MaxReplicas int32          `json:"maxReplicas,omitempty"` // 最大副本数
+	CPU         int32          `json:"cpu,omitempty"`         // CPU 目标使用率，百分比
+	Memory      int32          `json:"memory,omitempty"`      // 内存目标使用率，百分比
+	Metrics     []MetricTarget `json:"metrics,omitempty"`     // 自定义指标
+}
+
+// MetricTarget 自定义指标的伸缩目标
+type MetricTarget struct {
+	Name     string            `json:"name,omitempty"`     // 指标名称
+	Type     MetricSourceType  `json:"type,omitempty"`     // Pods External，默认 Pods
+	Target   string            `json:"target,omitempty"`   // 目标值，Pods 为每个实例的平均值，External 为总值
+	Selector map[string]string `json:"selector,omitempty"` // 指标的标签过滤条件
+}
+
+// Autoscaler 进程组的自动伸缩器
+type Autoscaler struct {
+	Model
+	AutoscalingConfig
+	Kind    WorkloadKind `json:"kind,omitempty"`
+	Current int32        `json:"current,omitempty"` // 当前副本数
+	Desired int32        `json:"desired,omitempty"` // 期望副本数
+}
+
+// Replicas 进程组的副本数情况
+type Replicas struct {
+	Min     int32 `json:"min,omitempty"`
+	Max     int32 `json:"max,omitempty"`
+	Current int32 `json:"current"`
+	Desired int32 `json:"desired"`
+}
+
+func (a *AutoscalingConfig) minReplicas() int32 {
+	if a.MinReplicas < 1 {
+		return 1
+	}
+	return a.MinReplicas
+}
+
+func (a *AutoscalingConfig) validate() error {
+	if a.MaxReplicas < 1 {
+		return uerrors.NewValidationError("maxReplicas", "请传入最大副本数")
+	}
+	if a.MaxReplicas < a.minReplicas() {
+		return uerrors.NewValidationError("maxReplicas", "最大副本数不能小于最小副本数")
+	}
+	if a.CPU <= 0 && a.Memory <= 0 && len(a.Metrics) < 1 {
+		return uerrors.NewValidationError("metrics", "请传入至少一个伸缩指标")
+	}
+	for _, m := range a.Metrics {
+		if len(m.Name) < 1 {
+			return uerrors.NewValidationError("metrics.name", "请传入指标名称")
+		}
+		if _, err := resource.ParseQuantity(m.Target); err != nil {
+			return uerrors.NewValidationError("metrics.target", fmt.Sprintf("指标目标值不合法: name=%s, target=%s", m.Name, m.Target))
+		}
+	}
+	return nil
+}
+
+func (pg *ProcessGroupConfig) toAutoscaler() *Autoscaler {
+	if pg.Autoscaling == nil {
+		return nil
+	}
+	labels := &pg.Labels
+	if len(labels.Group) < 1 {
+		labels.Group = pg.GroupName
+	}
+	a := &Autoscaler{
+		Model:             Model{Namespace: pg.Namespace, Name: pg.GroupName, AllowUpdate: true},
+		AutoscalingConfig: *pg.Autoscaling,
+		Kind:              ToWorkloadKind(pg.Kind.String()),
+	}
+	a.setTypesLabels(labels)
+	return a
+}
+
+func (a *Autoscaler) toHPA() *autoscalingv2.HorizontalPodAutoscaler {
+	replicas := a.minReplicas()
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      a.Model.Name,
+			Namespace: a.Namespace,
+			Labels:    a.labels(),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       a.Kind.String(),
+				Name:       a.Model.Name,
+			},
+			MinReplicas: &replicas,
+			MaxReplicas: a.MaxReplicas,
+			Metrics:     a.toMetrics(),
+		},
+	}
+}
+
+func (a *Autoscaler) toMetrics() []autoscalingv2.MetricSpec {
+	metrics := make([]autoscalingv2.MetricSpec, 0)
+	if a.CPU > 0 {
+		metrics = append(metrics, toResourceMetric(corev1.ResourceCPU, a.CPU))
+	}
+	if a.Memory > 0 {
+		metrics = append(metrics, toResourceMetric(corev1.ResourceMemory, a.Memory))
+	}
+	for _, m := range a.Metrics {
+		target := resource.MustParse(m.Target)
+		metric := autoscalingv2.MetricIdentifier{Name: m.Name}
+		if len(m.Selector) > 0 {
+			metric.Selector = &v1.LabelSelector{MatchLabels: m.Selector}
+		}
+		if gstr.Equal(string(m.Type), string(MetricSourceExternal)) {
+			metrics = append(metrics, autoscalingv2.MetricSpec{
+				Type: autoscalingv2.ExternalMetricSourceType,
+				External: &autoscalingv2.ExternalMetricSource{
+					Metric: metric,
+					Target: autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &target},
+				},
+			})
+			continue
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: metric,
+				Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &target},
+			},
+		})
+	}
+	return metrics
+}
+
+func toResourceMetric(name corev1.ResourceName, utilization int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name:   name,
+			Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &utilization},
+		},
+	}
+}
+
+func toAutoscaler(hpa autoscalingv2.HorizontalPodAutoscaler) *Autoscaler {
+	a := &Autoscaler{
+		Model:   Model{Namespace: hpa.Namespace, Name: hpa.Name},
+		Kind:    ToWorkloadKind(hpa.Spec.ScaleTargetRef.Kind),
+		Current: hpa.Status.CurrentReplicas,
+		Desired: hpa.Status.DesiredReplicas,
+	}
+	a.setLabels(hpa.Labels)
+	a.MaxReplicas = hpa.Spec.MaxReplicas
+	if hpa.Spec.MinReplicas != nil {
+		a.MinReplicas = *hpa.Spec.MinReplicas
+	}
+	for _, m := range hpa.Spec.Metrics {
+		switch m.Type {
+		case autoscalingv2.ResourceMetricSourceType:
+			if m.Resource == nil || m.Resource.Target.AverageUtilization == nil {
+				continue
+			}
+			switch m.Resource.Name {
+			case corev1.ResourceCPU:
+				a.CPU = *m.Resource.Target.AverageUtilization
+			case corev1.ResourceMemory:
+				a.Memory = *m.Resource.Target.AverageUtilization
+			}
+		case autoscalingv2.PodsMetricSourceType:
+			if m.Pods != nil && m.Pods.Target.AverageValue != nil {
+				a.Metrics = append(a.Metrics, toMetricTarget(MetricSourcePods, m.Pods.Metric, m.Pods.Target.AverageValue))
+			}
+		case autoscalingv2.ExternalMetricSourceType:
+			if m.External != nil && m.External.Target.Value != nil {
+				a.Metrics = append(a.Metrics, toMetricTarget(MetricSourceExternal, m.External.Metric, m.External.Target.Value))
+			}
+		}
+	}
+	return a
+}
+
+func toMetricTarget(t MetricSourceType, metric autoscalingv2.MetricIdentifier, target *resource.Quantity) MetricTarget {
+	m := MetricTarget{Name: metric.Name, Type: t, Target: target.String()}
+	if metric.Selector != nil {
+		m.Selector = metric.Selector.MatchLabels
+	}
+	return m
+}
+
+// Replicas 当前与期望的副本数
+func (a *Autoscaler) Replicas() *Replicas {
+	return &Replicas{Min: a.MinReplicas, Max: a.MaxReplicas, Current: a.Current, Desired: a.Desired}
+}
+
+func (o *autoscalerOperation) Get(ctx context.Context, namespace, group string) (*Autoscaler, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	hpa, err := o.api.AutoscalingV2().HorizontalPodAutoscalers(namespace).Get(ctx, group, v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取HPA: namespace=%s, group=%s", namespace, group))
+	}
+	return toAutoscaler(*hpa), nil
+}
+
+func (o *autoscalerOperation) List(ctx context.Context, namespace string, groups ...string) ([]*Autoscaler, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	autoscalers := make([]*Autoscaler, 0)
+	for _, g := range groups {
+		list, err := o.list(ctx, namespace, g)
+		if err != nil {
+			return nil, err
+		}
+		for _, hpa := range list {
+			autoscalers = append(autoscalers, toAutoscaler(hpa))
+		}
+	}
+	return autoscalers, nil
+}
+
+func (o *autoscalerOperation) list(ctx context.Context, namespace, group string) ([]autoscalingv2.HorizontalPodAutoscaler, error) {
+	list, err := o.api.AutoscalingV2().HorizontalPodAutoscalers(namespace).List(ctx, toGroupListOptions(group))
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取HPA列表: namespace=%s, group=%s", namespace, group))
+	}
+	return list.Items, nil
+}
+
+// Apply 创建或更新进程组的 HPA
+func (o *autoscalerOperation) Apply(ctx context.Context, autoscaler *Autoscaler) error {
+	if o.err != nil {
+		return o.err
+	}
+	if err := autoscaler.validate(); err != nil {
+		return err
+	}
+	if autoscaler.Kind == WorkloadDaemonSet {
+		return uerrors.NewValidationError("kind", "DaemonSet 不支持自动伸缩")
+	}
+	name := autoscaler.Model.Name
+	api := o.api.AutoscalingV2().HorizontalPodAutoscalers(autoscaler.Namespace)
+	data, err := api.Get(ctx, name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查HPA是否存在: namespace=%s, name=%s", autoscaler.Namespace, name))
+	if err != nil {
+		return err
+	}
+	hpa := autoscaler.toHPA()
+	if !has {
+		if _, err := api.Create(ctx, hpa, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建HPA: namespace=%s, name=%s", autoscaler.Namespace, name))
+		}
+		return nil
+	}
+	data.Labels = hpa.Labels
+	data.Spec = hpa.Spec
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新HPA: namespace=%s, name=%s", autoscaler.Namespace, name))
+	}
+	return nil
+}
+
+func (o *autoscalerOperation) DeleteGroup(ctx context.Context, namespace string, groups ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(groups) < 1 {
+		return uerrors.NewValidationError("groups", "请传入要删除的进程组名称")
+	}
+	api := o.api.AutoscalingV2().HorizontalPodAutoscalers(namespace)
+	for _, group := range groups {
+		if len(group) < 1 {
+			continue
+		}
+		list, err := o.list(ctx, namespace, group)
+		if err != nil {
+			return err
+		}
+		for _, hpa := range list {
+			if err := api.Delete(ctx, hpa.Name, v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除HPA: namespace=%s, name=%s", namespace, hpa.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// Scale 手动调整进程组的副本数，已开启自动伸缩的进程组需先关闭自动伸缩
+func (o *autoscalerOperation) Scale(ctx context.Context, namespace, group string, replicas int32) error {
+	if o.err != nil {
+		return o.err
+	}
+	if replicas < 0 {
+		return uerrors.NewValidationError("replicas", "副本数不能小于0")
+	}
+	autoscaler, err := o.Get(ctx, namespace, group)
+	if err != nil {
+		return err
+	}
+	if autoscaler != nil {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("进程组已开启自动伸缩，副本数由HPA管理: namespace=%s, group=%s", namespace, group))
+	}
+	kind, err := o.k8s.Rollout().kind(ctx, namespace, group)
+	if err != nil {
+		return err
+	}
+	_, err = o.setReplicas(ctx, namespace, group, kind, replicas)
+	return err
+}
@@ -0,0 +1,544 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/health"
+	"github.com/hosgf/element/logger"
+	"github.com/hosgf/element/types"
+	"github.com/hosgf/element/uerrors"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	autoscalinglisters "k8s.io/client-go/listers/autoscaling/v2"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultCacheResync 缓存全量同步周期
+	DefaultCacheResync = 10 * time.Minute
+	// DefaultCacheSyncTimeout 等待缓存首次同步的超时时间
+	DefaultCacheSyncTimeout = 60 * time.Second
+)
+
+// CacheEventType 缓存变更事件类型
+type CacheEventType string
+
+const (
+	CacheGroupAdded           CacheEventType = "groupAdded"           // 进程组创建
+	CacheGroupDeleted         CacheEventType = "groupDeleted"         // 进程组删除
+	CacheProcessStatusChanged CacheEventType = "processStatusChanged" // 进程状态变化
+	CacheProcessRestarted     CacheEventType = "processRestarted"     // 进程重启
+)
+
+func (t CacheEventType) String() string {
+	return string(t)
+}
+
+// CacheEvent 缓存变更事件
+type CacheEvent struct {
+	Type         CacheEventType `json:"type"`
+	Namespace    string         `json:"namespace,omitempty"`
+	Group        string         `json:"group,omitempty"`
+	Pod          string         `json:"pod,omitempty"`
+	Process      string         `json:"process,omitempty"`
+	Status       string         `json:"status,omitempty"`
+	OldStatus    string         `json:"oldStatus,omitempty"`
+	RestartCount int32          `json:"restartCount,omitempty"`
+	Time         int64          `json:"time,omitempty"`
+}
+
+// CacheHandler 缓存变更事件订阅者
+type CacheHandler func(event *CacheEvent)
+
+type cacheOperation struct {
+	*options
+	k8s      *Kubernetes
+	mutex    sync.RWMutex
+	caches   map[string]*namespaceCache
+	handlers map[int]CacheHandler
+	seq      int
+}
+
+// namespaceCache 单个命名空间（空字符串表示全部命名空间）的 informer 缓存
+type namespaceCache struct {
+	namespace    string
+	factory      informers.SharedInformerFactory
+	pods         corelisters.PodLister
+	deployments  appslisters.DeploymentLister
+	statefulSets appslisters.StatefulSetLister
+	daemonSets   appslisters.DaemonSetLister
+	services     corelisters.ServiceLister
+	storages     corelisters.PersistentVolumeClaimLister
+	autoscalers  autoscalinglisters.HorizontalPodAutoscalerLister
+	synced       []cache.InformerSynced
+	stop         chan struct{}
+}
+
+// Start 启动命名空间的 informer 缓存并等待首次同步，不传命名空间时缓存全部命名空间
+func (o *cacheOperation) Start(ctx context.Context, namespaces ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, ns := range namespaces {
+		c, started := o.start(ns)
+		if started {
+			continue
+		}
+		syncCtx, cancel := context.WithTimeout(ctx, DefaultCacheSyncTimeout)
+		ok := cache.WaitForCacheSync(syncCtx.Done(), c.synced...)
+		cancel()
+		if !ok {
+			o.Stop(ns)
+			return uerrors.NewKubernetesError(ctx, "启动缓存", "同步超时", fmt.Sprintf("namespace=%s", ns))
+		}
+		logger.Debugf(ctx, "[cache] synced, namespace=%s", ns)
+	}
+	return nil
+}
+
+func (o *cacheOperation) start(namespace string) (*namespaceCache, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if c, ok := o.caches[namespace]; ok {
+		return c, true
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(o.api, DefaultCacheResync,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *v1.ListOptions) {
+			opts.LabelSelector = types.LabelGroup.String()
+		}))
+	pods := factory.Core().V1().Pods()
+	deployments := factory.Apps().V1().Deployments()
+	statefulSets := factory.Apps().V1().StatefulSets()
+	daemonSets := factory.Apps().V1().DaemonSets()
+	services := factory.Core().V1().Services()
+	storages := factory.Core().V1().PersistentVolumeClaims()
+	autoscalers := factory.Autoscaling().V2().HorizontalPodAutoscalers()
+	c := &namespaceCache{
+		namespace:    namespace,
+		factory:      factory,
+		pods:         pods.Lister(),
+		deployments:  deployments.Lister(),
+		statefulSets: statefulSets.Lister(),
+		daemonSets:   daemonSets.Lister(),
+		services:     services.Lister(),
+		storages:     storages.Lister(),
+		autoscalers:  autoscalers.Lister(),
+		synced: []cache.InformerSynced{
+			pods.Informer().HasSynced,
+			deployments.Informer().HasSynced,
+			statefulSets.Informer().HasSynced,
+			daemonSets.Informer().HasSynced,
+			services.Informer().HasSynced,
+			storages.Informer().HasSynced,
+			autoscalers.Informer().HasSynced,
+		},
+		stop: make(chan struct{}),
+	}
+	// 首次同步时列出的已有资源不是变更，不发布事件
+	_, _ = pods.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				o.onPod(nil, obj)
+			}
+		},
+		UpdateFunc: o.onPod,
+		DeleteFunc: func(obj interface{}) { o.onPodDeleted(obj) },
+	})
+	groupHandler := cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				o.onGroup(CacheGroupAdded, obj)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { o.onGroup(CacheGroupDeleted, obj) },
+	}
+	_, _ = deployments.Informer().AddEventHandler(groupHandler)
+	_, _ = statefulSets.Informer().AddEventHandler(groupHandler)
+	_, _ = daemonSets.Informer().AddEventHandler(groupHandler)
+	o.caches[namespace] = c
+	factory.Start(c.stop)
+	return c, false
+}
+
+// Stop 停止命名空间的缓存，不传命名空间时停止全部
+func (o *cacheOperation) Stop(namespaces ...string) {
+	o.mutex.Lock()
+	if len(namespaces) == 0 {
+		for ns := range o.caches {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	stopped := make([]*namespaceCache, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if c, ok := o.caches[ns]; ok {
+			stopped = append(stopped, c)
+			delete(o.caches, ns)
+		}
+	}
+	o.mutex.Unlock()
+	// 事件回调中会读取订阅者，需在锁外等待 informer 退出
+	for _, c := range stopped {
+		close(c.stop)
+		c.factory.Shutdown()
+	}
+}
+
+// Synced 命名空间的缓存是否已完成同步
+func (o *cacheOperation) Synced(namespace string) bool {
+	return o.get(namespace) != nil
+}
+
+// Subscribe 订阅缓存变更事件，返回取消订阅的函数
+func (o *cacheOperation) Subscribe(handler CacheHandler) func() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.seq++
+	id := o.seq
+	o.handlers[id] = handler
+	return func() {
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		delete(o.handlers, id)
+	}
+}
+
+// Pods 从缓存读取进程组实例
+func (o *cacheOperation) Pods(ctx context.Context, namespace string, groups ...string) ([]*Pod, error) {
+	c, err := o.require(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*Pod, 0)
+	for _, selector := range toGroupSelectors(groups) {
+		var items []*corev1.Pod
+		if len(namespace) > 0 {
+			items, err = c.pods.Pods(namespace).List(selector)
+		} else {
+			items, err = c.pods.List(selector)
+		}
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取Pod缓存: namespace=%s", namespace))
+		}
+		list := &corev1.PodList{Items: make([]corev1.Pod, 0, len(items))}
+		for _, p := range items {
+			list.Items = append(list.Items, *p.DeepCopy())
+		}
+		pods = append(pods, o.k8s.pods.toPods(namespace, list)...)
+	}
+	return pods, nil
+}
+
+// Services 从缓存读取进程组服务
+func (o *cacheOperation) Services(ctx context.Context, namespace string, groups ...string) ([]*Service, error) {
+	c, err := o.require(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]*Service, 0)
+	for _, selector := range toGroupSelectors(groups) {
+		var items []*corev1.Service
+		if len(namespace) > 0 {
+			items, err = c.services.Services(namespace).List(selector)
+		} else {
+			items, err = c.services.List(selector)
+		}
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取Service缓存: namespace=%s", namespace))
+		}
+		list := &corev1.ServiceList{Items: make([]corev1.Service, 0, len(items))}
+		for _, s := range items {
+			list.Items = append(list.Items, *s.DeepCopy())
+		}
+		services = append(services, o.k8s.service.toServices(namespace, list)...)
+	}
+	return services, nil
+}
+
+// Deployments 从缓存读取进程组定义
+func (o *cacheOperation) Deployments(ctx context.Context, namespace string, groups ...string) ([]*Pod, error) {
+	c, err := o.require(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*Pod, 0)
+	for _, selector := range toGroupSelectors(groups) {
+		var items []*appsv1.Deployment
+		if len(namespace) > 0 {
+			items, err = c.deployments.Deployments(namespace).List(selector)
+		} else {
+			items, err = c.deployments.List(selector)
+		}
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取Deployment缓存: namespace=%s", namespace))
+		}
+		for _, d := range items {
+			pods = append(pods, toDeploymentPod(*d.DeepCopy()))
+		}
+	}
+	return pods, nil
+}
+
+// Workloads 从缓存读取进程组的 Deployment、StatefulSet 和 DaemonSet 定义
+func (o *cacheOperation) Workloads(ctx context.Context, namespace string, groups ...string) ([]*Pod, error) {
+	pods, err := o.Deployments(ctx, namespace, groups...)
+	if err != nil {
+		return nil, err
+	}
+	c, err := o.require(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, selector := range toGroupSelectors(groups) {
+		var sts []*appsv1.StatefulSet
+		if len(namespace) > 0 {
+			sts, err = c.statefulSets.StatefulSets(namespace).List(selector)
+		} else {
+			sts, err = c.statefulSets.List(selector)
+		}
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取StatefulSet缓存: namespace=%s", namespace))
+		}
+		for _, s := range sts {
+			pods = append(pods, toStatefulSetPod(*s.DeepCopy()))
+		}
+		var ds []*appsv1.DaemonSet
+		if len(namespace) > 0 {
+			ds, err = c.daemonSets.DaemonSets(namespace).List(selector)
+		} else {
+			ds, err = c.daemonSets.List(selector)
+		}
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取DaemonSet缓存: namespace=%s", namespace))
+		}
+		for _, d := range ds {
+			pods = append(pods, toDaemonSetPod(*d.DeepCopy()))
+		}
+	}
+	return pods, nil
+}
+
+// Storages 从缓存读取进程组存储
+func (o *cacheOperation) Storages(ctx context.Context, namespace string, groups ...string) ([]*types.Storage, error) {
+	c, err := o.require(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	storages := make([]*types.Storage, 0)
+	for _, selector := range toGroupSelectors(groups) {
+		var items []*corev1.PersistentVolumeClaim
+		if len(namespace) > 0 {
+			items, err = c.storages.PersistentVolumeClaims(namespace).List(selector)
+		} else {
+			items, err = c.storages.List(selector)
+		}
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取Storage缓存: namespace=%s", namespace))
+		}
+		for _, pvc := range items {
+			storages = append(storages, o.k8s.storage.toStorage(pvc.DeepCopy()))
+		}
+	}
+	return storages, nil
+}
+
+// Autoscalers 从缓存读取进程组的 HPA
+func (o *cacheOperation) Autoscalers(ctx context.Context, namespace string, groups ...string) ([]*Autoscaler, error) {
+	c, err := o.require(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	autoscalers := make([]*Autoscaler, 0)
+	for _, selector := range toGroupSelectors(groups) {
+		var items []*autoscalingv2.HorizontalPodAutoscaler
+		if len(namespace) > 0 {
+			items, err = c.autoscalers.HorizontalPodAutoscalers(namespace).List(selector)
+		} else {
+			items, err = c.autoscalers.List(selector)
+		}
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取HPA缓存: namespace=%s", namespace))
+		}
+		for _, hpa := range items {
+			autoscalers = append(autoscalers, toAutoscaler(*hpa.DeepCopy()))
+		}
+	}
+	return autoscalers, nil
+}
+
+// Health 根据缓存中的实例状态计算进程组健康状态
+func (o *cacheOperation) Health(ctx context.Context, namespace, group string) (health.Health, error) {
+	pods, err := o.Pods(ctx, namespace, group)
+	if err != nil {
+		return health.UNKNOWN, err
+	}
+	if len(pods) == 0 {
+		return health.DOWN, nil
+	}
+	result := health.UP
+	for _, p := range pods {
+		switch h := Status(p.Status); h {
+		case health.UP:
+		case health.DOWN:
+			return health.DOWN, nil
+		default:
+			result = h
+		}
+	}
+	return result, nil
+}
+
+func (o *cacheOperation) get(namespace string) *namespaceCache {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	for _, ns := range []string{namespace, ""} {
+		c, ok := o.caches[ns]
+		if !ok {
+			continue
+		}
+		for _, synced := range c.synced {
+			if !synced() {
+				return nil
+			}
+		}
+		return c
+	}
+	return nil
+}
+
+func (o *cacheOperation) require(ctx context.Context, namespace string) (*namespaceCache, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	c := o.get(namespace)
+	if c == nil {
+		return nil, uerrors.NewKubernetesError(ctx, "读取缓存", "缓存未启动或未同步", fmt.Sprintf("namespace=%s", namespace))
+	}
+	return c, nil
+}
+
+func toGroupSelectors(groups []string) []labels.Selector {
+	if len(groups) == 0 {
+		return []labels.Selector{labels.Everything()}
+	}
+	selectors := make([]labels.Selector, 0, len(groups))
+	for _, g := range groups {
+		if len(g) < 1 {
+			continue
+		}
+		selectors = append(selectors, labels.SelectorFromSet(labels.Set{types.LabelGroup.String(): g}))
+	}
+	if len(selectors) == 0 {
+		// 与 toGroupListOptions("") 一致，没有有效的进程组名称时返回全部
+		return []labels.Selector{labels.Everything()}
+	}
+	return selectors
+}
+
+func (o *cacheOperation) publish(event *CacheEvent) {
+	event.Time = time.Now().Unix()
+	o.mutex.RLock()
+	handlers := make([]CacheHandler, 0, len(o.handlers))
+	for _, h := range o.handlers {
+		handlers = append(handlers, h)
+	}
+	o.mutex.RUnlock()
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+func (o *cacheOperation) onGroup(t CacheEventType, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	// Deployment、StatefulSet 和 DaemonSet 都代表一个进程组
+	var m v1.Object
+	switch w := obj.(type) {
+	case *appsv1.Deployment:
+		m = w
+	case *appsv1.StatefulSet:
+		m = w
+	case *appsv1.DaemonSet:
+		m = w
+	default:
+		return
+	}
+	o.publish(&CacheEvent{Type: t, Namespace: m.GetNamespace(), Group: m.GetLabels()[types.LabelGroup.String()]})
+}
+
+func (o *cacheOperation) onPod(oldObj, newObj interface{}) {
+	p, ok := newObj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	var old map[string]corev1.ContainerStatus
+	if op, ok := oldObj.(*corev1.Pod); ok {
+		old = make(map[string]corev1.ContainerStatus, len(op.Status.ContainerStatuses))
+		for _, cs := range op.Status.ContainerStatuses {
+			old[cs.Name] = cs
+		}
+	}
+	group := p.Labels[types.LabelGroup.String()]
+	for _, cs := range p.Status.ContainerStatuses {
+		prev, has := old[cs.Name]
+		status := toProcessStatus(cs)
+		if has && cs.RestartCount > prev.RestartCount {
+			o.publish(&CacheEvent{Type: CacheProcessRestarted, Namespace: p.Namespace, Group: group, Pod: p.Name,
+				Process: cs.Name, Status: status, RestartCount: cs.RestartCount})
+		}
+		oldStatus := ""
+		if has {
+			oldStatus = toProcessStatus(prev)
+		}
+		if status != oldStatus {
+			o.publish(&CacheEvent{Type: CacheProcessStatusChanged, Namespace: p.Namespace, Group: group, Pod: p.Name,
+				Process: cs.Name, Status: status, OldStatus: oldStatus, RestartCount: cs.RestartCount})
+		}
+	}
+}
+
+func (o *cacheOperation) onPodDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	p, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	group := p.Labels[types.LabelGroup.String()]
+	for _, cs := range p.Status.ContainerStatuses {
+		o.publish(&CacheEvent{Type: CacheProcessStatusChanged, Namespace: p.Namespace, Group: group, Pod: p.Name,
+			Process: cs.Name, Status: Terminating, OldStatus: toProcessStatus(cs), RestartCount: cs.RestartCount})
+	}
+}
+
+// toProcessStatus 进程状态，运行中为 running，等待或退出时为对应的原因
+func toProcessStatus(cs corev1.ContainerStatus) string {
+	switch {
+	case cs.State.Running != nil:
+		if cs.Ready {
+			return Running
+		}
+		return ContainersNotReady
+	case cs.State.Waiting != nil:
+		return gstr.ToLower(cs.State.Waiting.Reason)
+	case cs.State.Terminated != nil:
+		return gstr.ToLower(cs.State.Terminated.Reason)
+	default:
+		return Unknown
+	}
+}
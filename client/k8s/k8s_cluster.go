@@ -0,0 +1,258 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hosgf/element/model/process"
+	"github.com/hosgf/element/model/resource"
+	"github.com/hosgf/element/uerrors"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// DefaultClusterTimeout 多集群查询时单个集群的超时时间
+const DefaultClusterTimeout = 30 * time.Second
+
+// ClusterConfig 集群的连接配置，依次按 InCluster、Host、Kubeconfig 选择连接方式
+type ClusterConfig struct {
+	Region     string  `json:"region"`               // 区域，集群的唯一标识
+	InCluster  bool    `json:"inCluster,omitempty"`  // 使用所在 Pod 的 ServiceAccount
+	Host       string  `json:"host,omitempty"`       // API Server 地址，与 Token 配合使用
+	Token      string  `json:"token,omitempty"`      // 访问令牌
+	CAData     string  `json:"caData,omitempty"`     // CA 证书内容，PEM 格式
+	CAFile     string  `json:"caFile,omitempty"`     // CA 证书文件
+	Insecure   bool    `json:"insecure,omitempty"`   // 是否跳过证书校验
+	Kubeconfig string  `json:"kubeconfig,omitempty"` // kubeconfig 文件路径，为空时使用 ~/.kube/config
+	Context    string  `json:"context,omitempty"`    // kubeconfig 中的上下文，为空时使用当前上下文
+	QPS        float32 `json:"qps,omitempty"`
+	Burst      int     `json:"burst,omitempty"`
+}
+
+func (c *ClusterConfig) toRestConfig() (*rest.Config, error) {
+	var (
+		config *rest.Config
+		err    error
+	)
+	switch {
+	case c.InCluster:
+		config, err = rest.InClusterConfig()
+	case len(c.Host) > 0:
+		if len(c.Token) < 1 {
+			return nil, uerrors.NewValidationError("token", fmt.Sprintf("请传入集群的访问令牌: region=%s", c.Region))
+		}
+		config = &rest.Config{
+			Host:        c.Host,
+			BearerToken: c.Token,
+			TLSClientConfig: rest.TLSClientConfig{
+				CAData:   []byte(c.CAData),
+				CAFile:   c.CAFile,
+				Insecure: c.Insecure,
+			},
+		}
+	default:
+		kubeconfig := c.Kubeconfig
+		if len(kubeconfig) < 1 {
+			kubeconfig = kubeconfigPath("")
+		}
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: c.Context},
+		).ClientConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+	config.QPS = c.QPS
+	config.Burst = c.Burst
+	return config, nil
+}
+
+// Clusters 多集群注册表，每个集群以区域为键
+type Clusters struct {
+	isDebug  bool
+	timeout  time.Duration
+	mu       sync.RWMutex
+	clusters map[string]*Kubernetes
+}
+
+func NewClusters(isDebug bool) *Clusters {
+	return &Clusters{isDebug: isDebug, timeout: DefaultClusterTimeout, clusters: make(map[string]*Kubernetes)}
+}
+
+// WithTimeout 设置多集群查询时单个集群的超时时间，避免不可达的集群拖慢整体查询
+func (c *Clusters) WithTimeout(timeout time.Duration) *Clusters {
+	if timeout > 0 {
+		c.timeout = timeout
+	}
+	return c
+}
+
+// Register 按连接配置创建集群客户端并注册，同一区域重复注册时替换
+func (c *Clusters) Register(config ClusterConfig) (*Kubernetes, error) {
+	ctx := context.Background()
+	if len(config.Region) < 1 {
+		return nil, uerrors.NewValidationError("region", "请传入集群所属的区域")
+	}
+	restConfig, err := config.toRestConfig()
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("初始化集群配置: region=%s", config.Region))
+	}
+	k := New(c.isDebug, false).WithRegion(config.Region)
+	if err := k.InitWithConfig(restConfig); err != nil {
+		return nil, err
+	}
+	c.Add(config.Region, k)
+	return k, nil
+}
+
+// LoadKubeconfig 将 kubeconfig 中的上下文注册为集群，区域为上下文名称
+// contexts 为空时注册文件中的所有上下文
+func (c *Clusters) LoadKubeconfig(path string, contexts ...string) ([]string, error) {
+	ctx := context.Background()
+	if len(path) < 1 {
+		path = kubeconfigPath("")
+	}
+	raw, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("读取kubeconfig: %s", path))
+	}
+	if len(contexts) < 1 {
+		for name := range raw.Contexts {
+			contexts = append(contexts, name)
+		}
+		sort.Strings(contexts)
+	}
+	for _, name := range contexts {
+		if _, ok := raw.Contexts[name]; !ok {
+			return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+				fmt.Sprintf("kubeconfig中没有上下文: path=%s, context=%s", path, name))
+		}
+	}
+	for _, name := range contexts {
+		if _, err := c.Register(ClusterConfig{Region: name, Kubeconfig: path, Context: name}); err != nil {
+			return nil, err
+		}
+	}
+	return contexts, nil
+}
+
+// Add 注册已创建的集群客户端，并设置其区域，替换时停止旧客户端的缓存
+func (c *Clusters) Add(region string, k *Kubernetes) {
+	k.WithRegion(region)
+	c.mu.Lock()
+	old, ok := c.clusters[region]
+	c.clusters[region] = k
+	c.mu.Unlock()
+	if ok && old != k {
+		old.Cache().Stop()
+	}
+}
+
+// Remove 移除集群并停止其缓存
+func (c *Clusters) Remove(region string) {
+	c.mu.Lock()
+	old, ok := c.clusters[region]
+	delete(c.clusters, region)
+	c.mu.Unlock()
+	if ok {
+		old.Cache().Stop()
+	}
+}
+
+func (c *Clusters) Get(region string) (*Kubernetes, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	k, ok := c.clusters[region]
+	if !ok {
+		return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound, fmt.Sprintf("没有注册的集群: region=%s", region))
+	}
+	return k, nil
+}
+
+// Regions 已注册的区域，按名称排序
+func (c *Clusters) Regions() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	regions := make([]string, 0, len(c.clusters))
+	for region := range c.clusters {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// each 并发在所有集群上执行，每个集群单独超时，返回失败的集群的错误
+func (c *Clusters) each(ctx context.Context, fn func(ctx context.Context, region string, k *Kubernetes) error) error {
+	c.mu.RLock()
+	clusters := make(map[string]*Kubernetes, len(c.clusters))
+	for region, k := range c.clusters {
+		clusters[region] = k
+	}
+	c.mu.RUnlock()
+	errs := make([]error, 0, len(clusters))
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for region, k := range clusters {
+		wg.Add(1)
+		go func(region string, k *Kubernetes) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			if err := fn(ctx, region, k); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("region=%s: %w", region, err))
+				mu.Unlock()
+			}
+		}(region, k)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// ProcessList 查询所有集群中的进程，某些集群失败时仍返回其他集群的结果
+func (c *Clusters) ProcessList(ctx context.Context, namespace string) ([]*process.Process, error) {
+	var (
+		mu   sync.Mutex
+		list = make([]*process.Process, 0)
+	)
+	err := c.each(ctx, func(ctx context.Context, region string, k *Kubernetes) error {
+		ps, err := k.Process().List(ctx, namespace)
+		mu.Lock()
+		defer mu.Unlock()
+		list = append(list, ps...)
+		return err
+	})
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Region < list[j].Region
+	})
+	return list, err
+}
+
+// Resources 查询所有集群的资源汇总，某些集群失败时仍返回其他集群的结果
+func (c *Clusters) Resources(ctx context.Context) ([]*resource.Resource, error) {
+	var (
+		mu        sync.Mutex
+		resources = make([]*resource.Resource, 0)
+	)
+	err := c.each(ctx, func(ctx context.Context, region string, k *Kubernetes) error {
+		res, err := k.Resource().Get(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		resources = append(resources, res)
+		return nil
+	})
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Region < resources[j].Region
+	})
+	return resources, err
+}
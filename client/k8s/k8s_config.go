@@ -0,0 +1,381 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/types"
+	"github.com/hosgf/element/uerrors"
+	"github.com/hosgf/element/util"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationConfigHash 进程组引用的配置内容摘要，内容变化时触发滚动重启
+const AnnotationConfigHash = "x-platform-config-hash"
+
+type configOperation struct {
+	*options
+}
+
+// ConfigMapConfig 配置文件，以 ConfigMap 形式下发
+type ConfigMapConfig struct {
+	Name  string            `json:"name,omitempty"`  // ConfigMap 名称
+	Data  map[string]string `json:"data,omitempty"`  // 文件名 -> 文件内容
+	Path  string            `json:"path,omitempty"`  // 挂载目录，为空时不以文件挂载
+	Env   bool              `json:"env,omitempty"`   // 是否将全部条目注入为环境变量
+	Scope string            `json:"scope,omitempty"` // 作用的进程，多个以逗号分隔，默认全部
+}
+
+// SecretConfig 密文配置，以 Secret 形式下发，Data 中的值使用 util.EncryptDefault 加密保存
+type SecretConfig struct {
+	Name  string            `json:"name,omitempty"`  // Secret 名称
+	Type  string            `json:"type,omitempty"`  // Secret 类型，默认 Opaque
+	Data  map[string]string `json:"data,omitempty"`  // 键 -> 加密后的值
+	Path  string            `json:"path,omitempty"`  // 挂载目录，为空时不以文件挂载
+	Env   bool              `json:"env,omitempty"`   // 是否将全部条目注入为环境变量
+	Scope string            `json:"scope,omitempty"` // 作用的进程，多个以逗号分隔，默认全部
+}
+
+// ConfigMap 命名空间下的 ConfigMap
+type ConfigMap struct {
+	Model
+	ConfigMapConfig `json:"config"` // 与 Model 存在同名字段，序列化时作为独立字段
+}
+
+// Secret 命名空间下的 Secret
+type Secret struct {
+	Model
+	SecretConfig `json:"config"` // 与 Model 存在同名字段，序列化时作为独立字段
+}
+
+func isScopeMatch(scope, name string) bool {
+	if len(scope) < 1 || gstr.Contains(scope, "*") {
+		return true
+	}
+	for _, s := range gstr.SplitAndTrim(scope, ",") {
+		if gstr.Equal(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ConfigMapConfig) IsMatch(name string) bool {
+	return isScopeMatch(c.Scope, name)
+}
+
+func (s *SecretConfig) IsMatch(name string) bool {
+	return isScopeMatch(s.Scope, name)
+}
+
+// Encrypt 加密明文的 Data，用于写入配置存储前
+func (s *SecretConfig) Encrypt() {
+	for k, v := range s.Data {
+		s.Data[k] = util.EncryptDefault(v)
+	}
+}
+
+// decrypt 解密 Data，用于创建 Secret
+func (s *SecretConfig) decrypt() (map[string][]byte, error) {
+	data := make(map[string][]byte, len(s.Data))
+	for k, v := range s.Data {
+		plain, err := util.DecryptDefault(v)
+		if err != nil {
+			return nil, uerrors.NewValidationError("secret", fmt.Sprintf("解密失败: name=%s, key=%s", s.Name, k))
+		}
+		data[k] = []byte(plain)
+	}
+	return data, nil
+}
+
+func (s *SecretConfig) secretType() corev1.SecretType {
+	if len(s.Type) < 1 {
+		return corev1.SecretTypeOpaque
+	}
+	return corev1.SecretType(s.Type)
+}
+
+func (c *ConfigMap) toConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      c.ConfigMapConfig.Name,
+			Namespace: c.Namespace,
+			Labels:    c.labels(),
+		},
+		Data: c.Data,
+	}
+}
+
+func (s *Secret) toSecret() (*corev1.Secret, error) {
+	data, err := s.decrypt()
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      s.SecretConfig.Name,
+			Namespace: s.Namespace,
+			Labels:    s.labels(),
+		},
+		Type: s.secretType(),
+		Data: data,
+	}, nil
+}
+
+// configHash 计算进程组配置内容的摘要，Secret 使用加密后的值参与计算
+func (pg *ProcessGroupConfig) configHash() string {
+	if len(pg.ConfigMaps) == 0 && len(pg.Secrets) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	write := func(kind, name string, data map[string]string) {
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		_, _ = fmt.Fprintf(h, "%s/%s\n", kind, name)
+		for _, k := range keys {
+			_, _ = fmt.Fprintf(h, "%s=%s\n", k, data[k])
+		}
+	}
+	for _, c := range pg.ConfigMaps {
+		write("configmap", c.Name, c.Data)
+	}
+	for _, s := range pg.Secrets {
+		write("secret", s.Name, s.Data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// toConfigMapVolumes ConfigMap 和 Secret 以文件挂载时的卷
+func (pod *Pod) toConfigMapVolumes() []corev1.Volume {
+	volumes := make([]corev1.Volume, 0)
+	for _, c := range pod.ConfigMaps {
+		if len(c.Name) < 1 || len(c.Path) < 1 {
+			continue
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name: c.Name,
+			VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: c.Name},
+			}},
+		})
+	}
+	for _, s := range pod.SecretConfigs {
+		if len(s.Name) < 1 || len(s.Path) < 1 {
+			continue
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name:         s.Name,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: s.Name}},
+		})
+	}
+	return volumes
+}
+
+// applyConfigHash 在 Pod 模板上记录配置摘要，摘要变化会触发工作负载滚动更新
+func (pod *Pod) applyConfigHash(meta *v1.ObjectMeta) {
+	if len(pod.ConfigHash) < 1 {
+		if meta.Annotations != nil {
+			delete(meta.Annotations, AnnotationConfigHash)
+		}
+		return
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[AnnotationConfigHash] = pod.ConfigHash
+}
+
+func (p *ProcessConfig) toConfigMapMounts(cmap map[string]string, pg *ProcessGroupConfig) {
+	for _, c := range pg.ConfigMaps {
+		if len(c.Name) < 1 || len(c.Path) < 1 || !c.IsMatch(p.Name) {
+			continue
+		}
+		if _, ok := cmap[c.Name]; ok {
+			continue
+		}
+		cmap[c.Name] = c.Path
+		p.Mounts = append(p.Mounts, types.Mount{Name: c.Name, Path: c.Path})
+	}
+	for _, s := range pg.Secrets {
+		if len(s.Name) < 1 || len(s.Path) < 1 || !s.IsMatch(p.Name) {
+			continue
+		}
+		if _, ok := cmap[s.Name]; ok {
+			continue
+		}
+		cmap[s.Name] = s.Path
+		p.Mounts = append(p.Mounts, types.Mount{Name: s.Name, Path: s.Path})
+	}
+}
+
+// toEnvFrom 进程需要整体注入为环境变量的 ConfigMap 和 Secret
+func (p *ProcessConfig) toEnvFrom(pg *ProcessGroupConfig) ([]string, []string) {
+	configMaps := make([]string, 0)
+	secrets := make([]string, 0)
+	for _, c := range pg.ConfigMaps {
+		if c.Env && len(c.Name) > 0 && c.IsMatch(p.Name) {
+			configMaps = append(configMaps, c.Name)
+		}
+	}
+	for _, s := range pg.Secrets {
+		if s.Env && len(s.Name) > 0 && s.IsMatch(p.Name) {
+			secrets = append(secrets, s.Name)
+		}
+	}
+	return configMaps, secrets
+}
+
+func (c *Container) envFrom(container *corev1.Container) {
+	for _, name := range c.ConfigMapEnv {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		})
+	}
+	for _, name := range c.SecretEnv {
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		})
+	}
+}
+
+func (c *Container) setEnvFrom(container corev1.Container) {
+	for _, e := range container.EnvFrom {
+		if e.ConfigMapRef != nil {
+			c.ConfigMapEnv = append(c.ConfigMapEnv, e.ConfigMapRef.Name)
+		}
+		if e.SecretRef != nil {
+			c.SecretEnv = append(c.SecretEnv, e.SecretRef.Name)
+		}
+	}
+}
+
+// BatchApply 创建或更新进程组的 ConfigMap 和 Secret，已存在时总是以最新内容覆盖
+func (o *configOperation) BatchApply(ctx context.Context, model Model, configMaps []ConfigMapConfig, secrets []SecretConfig) error {
+	if o.err != nil {
+		return o.err
+	}
+	for _, c := range configMaps {
+		if len(c.Name) < 1 {
+			continue
+		}
+		if err := o.ApplyConfigMap(ctx, &ConfigMap{Model: model, ConfigMapConfig: c}); err != nil {
+			return err
+		}
+	}
+	for _, s := range secrets {
+		if len(s.Name) < 1 {
+			continue
+		}
+		if err := o.ApplySecret(ctx, &Secret{Model: model, SecretConfig: s}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *configOperation) ApplyConfigMap(ctx context.Context, config *ConfigMap) error {
+	if o.err != nil {
+		return o.err
+	}
+	name := config.ConfigMapConfig.Name
+	api := o.api.CoreV1().ConfigMaps(config.Namespace)
+	data, err := api.Get(ctx, name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查ConfigMap是否存在: namespace=%s, name=%s", config.Namespace, name))
+	if err != nil {
+		return err
+	}
+	if !has {
+		if _, err := api.Create(ctx, config.toConfigMap(), v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建ConfigMap: namespace=%s, name=%s", config.Namespace, name))
+		}
+		return nil
+	}
+	if data.Labels == nil {
+		data.Labels = map[string]string{}
+	}
+	for k, v := range config.labels() {
+		data.Labels[k] = v
+	}
+	data.Data = config.Data
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新ConfigMap: namespace=%s, name=%s", config.Namespace, name))
+	}
+	return nil
+}
+
+func (o *configOperation) ApplySecret(ctx context.Context, config *Secret) error {
+	if o.err != nil {
+		return o.err
+	}
+	secret, err := config.toSecret()
+	if err != nil {
+		return err
+	}
+	name := secret.Name
+	api := o.api.CoreV1().Secrets(config.Namespace)
+	data, err := api.Get(ctx, name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查Secret是否存在: namespace=%s, name=%s", config.Namespace, name))
+	if err != nil {
+		return err
+	}
+	if !has {
+		if _, err := api.Create(ctx, secret, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建Secret: namespace=%s, name=%s", config.Namespace, name))
+		}
+		return nil
+	}
+	if data.Labels == nil {
+		data.Labels = map[string]string{}
+	}
+	for k, v := range secret.Labels {
+		data.Labels[k] = v
+	}
+	data.Data = secret.Data
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新Secret: namespace=%s, name=%s", config.Namespace, name))
+	}
+	return nil
+}
+
+// DeleteGroup 删除进程组标签下的 ConfigMap 和 Secret
+func (o *configOperation) DeleteGroup(ctx context.Context, namespace string, groups ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	var lastErr error
+	for _, group := range groups {
+		if len(group) < 1 {
+			continue
+		}
+		opts := toGroupListOptions(group)
+		configMaps, err := o.api.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+		if err != nil {
+			lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取ConfigMap列表: namespace=%s, group=%s", namespace, group))
+		} else {
+			for _, c := range configMaps.Items {
+				if err := o.api.CoreV1().ConfigMaps(namespace).Delete(ctx, c.Name, v1.DeleteOptions{}); err != nil {
+					lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除ConfigMap: namespace=%s, name=%s", namespace, c.Name))
+				}
+			}
+		}
+		secrets, err := o.api.CoreV1().Secrets(namespace).List(ctx, opts)
+		if err != nil {
+			lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Secret列表: namespace=%s, group=%s", namespace, group))
+			continue
+		}
+		for _, s := range secrets.Items {
+			if err := o.api.CoreV1().Secrets(namespace).Delete(ctx, s.Name, v1.DeleteOptions{}); err != nil {
+				lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除Secret: namespace=%s, name=%s", namespace, s.Name))
+			}
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,236 @@
+package k8s
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hosgf/element/uerrors"
+)
+
+// DefaultCopyMaxBytes 默认的单次复制大小上限
+const DefaultCopyMaxBytes int64 = 1 << 30
+
+// CopyOptions 文件复制参数
+type CopyOptions struct {
+	MaxBytes int64                        // 文件内容的大小上限，默认 1GiB
+	Progress func(progress *CopyProgress) // 进度回调，每个文件块写入后调用
+}
+
+// CopyProgress 文件复制进度
+type CopyProgress struct {
+	File  string `json:"file,omitempty"`  // 当前文件
+	Bytes int64  `json:"bytes"`           // 已复制的字节数
+	Total int64  `json:"total,omitempty"` // 总字节数，未知时为 0
+}
+
+func (c *CopyOptions) maxBytes() int64 {
+	if c.MaxBytes <= 0 {
+		return DefaultCopyMaxBytes
+	}
+	return c.MaxBytes
+}
+
+// progressWriter 统计复制的字节数并检查上限
+type progressWriter struct {
+	w        io.Writer
+	opts     *CopyOptions
+	progress CopyProgress
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	if p.progress.Bytes+int64(len(b)) > p.opts.maxBytes() {
+		return 0, uerrors.NewValidationError("maxBytes", fmt.Sprintf("复制的文件超过大小上限: %d", p.opts.maxBytes()))
+	}
+	n, err := p.w.Write(b)
+	p.progress.Bytes += int64(n)
+	if p.opts.Progress != nil {
+		progress := p.progress
+		p.opts.Progress(&progress)
+	}
+	return n, err
+}
+
+// toRemotePath 校验容器内的路径，只接受绝对路径
+func toRemotePath(remote string) (string, error) {
+	if !path.IsAbs(remote) || strings.ContainsAny(remote, "\n\r\x00") {
+		return "", uerrors.NewValidationError("remotePath", fmt.Sprintf("容器路径必须为绝对路径: %s", remote))
+	}
+	remote = path.Clean(remote)
+	if remote == "/" {
+		return "", uerrors.NewValidationError("remotePath", "不允许复制容器根目录")
+	}
+	return remote, nil
+}
+
+// CopyFrom 将容器内的文件或目录复制到本地，通过 exec 通道传输 tar 流
+func (o *podsOperation) CopyFrom(ctx context.Context, namespace, pod, process, remotePath, localPath string, opts CopyOptions) (*CopyProgress, error) {
+	remote, err := toRemotePath(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(localPath) < 1 {
+		return nil, uerrors.NewValidationError("localPath", "请传入本地路径")
+	}
+	localPath = filepath.Clean(localPath)
+	reader, writer := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		var stderr strings.Builder
+		err := o.Stream(ctx, namespace, pod, process, ExecOptions{Stdout: writer, Stderr: &stderr},
+			"tar", "cf", "-", "-C", path.Dir(remote), path.Base(remote))
+		if err != nil && stderr.Len() > 0 {
+			err = uerrors.NewKubernetesError(ctx, "复制文件", err.Error(), stderr.String())
+		}
+		_ = writer.CloseWithError(err)
+		result <- err
+	}()
+	progress, err := untar(reader, path.Base(remote), localPath, &opts)
+	_ = reader.CloseWithError(err)
+	if execErr := <-result; err == nil {
+		err = execErr
+	}
+	return progress, err
+}
+
+// untar 解压 tar 流，以 base 开头的条目解压到 dst，拒绝越出 dst 的条目
+func untar(r io.Reader, base, dst string, opts *CopyOptions) (*CopyProgress, error) {
+	tr := tar.NewReader(r)
+	counter := &progressWriter{opts: opts}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return &counter.progress, nil
+		}
+		if err != nil {
+			return &counter.progress, err
+		}
+		name := path.Clean(header.Name)
+		rel := strings.TrimPrefix(strings.TrimPrefix(name, base), "/")
+		if name != base && !strings.HasPrefix(name, base+"/") {
+			continue
+		}
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+		if target != dst && !strings.HasPrefix(target, dst+string(os.PathSeparator)) {
+			return &counter.progress, uerrors.NewValidationError("path", fmt.Sprintf("文件路径越界: %s", header.Name))
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return &counter.progress, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return &counter.progress, err
+			}
+			file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return &counter.progress, err
+			}
+			counter.w = file
+			counter.progress.File = header.Name
+			_, err = io.Copy(counter, tr)
+			_ = file.Close()
+			if err != nil {
+				return &counter.progress, err
+			}
+		default:
+			// 链接和设备文件可能指向目标目录之外，跳过
+		}
+	}
+}
+
+// CopyTo 将本地的文件或目录复制到容器内，通过 exec 通道传输 tar 流
+func (o *podsOperation) CopyTo(ctx context.Context, namespace, pod, process, localPath, remotePath string, opts CopyOptions) (*CopyProgress, error) {
+	remote, err := toRemotePath(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	total, err := localSize(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if total > opts.maxBytes() {
+		return nil, uerrors.NewValidationError("maxBytes", fmt.Sprintf("复制的文件超过大小上限: size=%d, max=%d", total, opts.maxBytes()))
+	}
+	reader, writer := io.Pipe()
+	counter := &progressWriter{opts: &opts, progress: CopyProgress{Total: total}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = writer.CloseWithError(tarLocal(filepath.Clean(localPath), path.Base(remote), writer, counter))
+	}()
+	var stderr strings.Builder
+	err = o.Stream(ctx, namespace, pod, process, ExecOptions{Stdin: reader, Stderr: &stderr},
+		"tar", "xmf", "-", "-C", path.Dir(remote))
+	// 关闭读取端使打包协程退出，等待其结束后再读取进度
+	_ = reader.Close()
+	<-done
+	if err != nil && stderr.Len() > 0 {
+		err = uerrors.NewKubernetesError(ctx, "复制文件", err.Error(), stderr.String())
+	}
+	return &counter.progress, err
+}
+
+func localSize(localPath string) (int64, error) {
+	if _, err := os.Stat(localPath); err != nil {
+		return 0, uerrors.NewValidationError("localPath", fmt.Sprintf("本地路径不存在: %s", localPath))
+	}
+	var total int64
+	err := filepath.Walk(localPath, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// tarLocal 打包本地文件，条目以 base 为根
+func tarLocal(src, base string, w io.Writer, counter *progressWriter) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(base, filepath.ToSlash(rel))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		counter.w = tw
+		counter.progress.File = header.Name
+		_, err = io.Copy(counter, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
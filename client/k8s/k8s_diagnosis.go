@@ -0,0 +1,369 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hosgf/element/health"
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultDiagnosisEvents 诊断报告保留的最近事件数
+const DefaultDiagnosisEvents = 50
+
+type diagnosisOperation struct {
+	*options
+	k8s *Kubernetes
+}
+
+// Diagnosis 进程组诊断报告
+type Diagnosis struct {
+	Namespace string                `json:"namespace,omitempty"`
+	Group     string                `json:"group,omitempty"`
+	Health    health.Health         `json:"health,omitempty"`   // 进程组整体健康状态
+	Problems  []string              `json:"problems,omitempty"` // 汇总出的问题描述
+	Rollout   *RolloutStatus        `json:"rollout,omitempty"`  // 工作负载发布状态
+	Pods      []*PodDiagnosis       `json:"pods,omitempty"`
+	Storages  []*StorageDiagnosis   `json:"storages,omitempty"`
+	Events    []*DiagnosisEvent     `json:"events,omitempty"` // 相关资源的最近事件，按时间倒序
+	Created   int64                 `json:"created,omitempty"`
+	Objects   map[string][]string   `json:"objects,omitempty"` // 参与诊断的资源 kind -> names
+	uids      map[k8stypes.UID]bool `json:"-"`
+}
+
+// PodDiagnosis 实例诊断信息
+type PodDiagnosis struct {
+	Name       string                `json:"name,omitempty"`
+	Node       string                `json:"node,omitempty"`
+	Phase      string                `json:"phase,omitempty"`
+	Status     string                `json:"status,omitempty"`
+	Scheduling string                `json:"scheduling,omitempty"` // 未调度时的原因
+	Containers []*ContainerDiagnosis `json:"containers,omitempty"`
+}
+
+// ContainerDiagnosis 进程诊断信息
+type ContainerDiagnosis struct {
+	Name           string       `json:"name,omitempty"`
+	Ready          bool         `json:"ready,omitempty"`
+	RestartCount   int32        `json:"restartCount,omitempty"`
+	State          string       `json:"state,omitempty"` // waiting running terminated
+	Reason         string       `json:"reason,omitempty"`
+	Message        string       `json:"message,omitempty"`
+	LastTerminated *Termination `json:"lastTerminated,omitempty"` // 上一次退出的信息
+}
+
+// Termination 进程退出信息
+type Termination struct {
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode int32  `json:"exitCode"`
+	Finished int64  `json:"finished,omitempty"`
+}
+
+// StorageDiagnosis 存储诊断信息
+type StorageDiagnosis struct {
+	Name         string `json:"name,omitempty"`
+	Phase        string `json:"phase,omitempty"`
+	Volume       string `json:"volume,omitempty"`
+	StorageClass string `json:"storageClass,omitempty"`
+	Problem      string `json:"problem,omitempty"`
+}
+
+// DiagnosisEvent 资源事件
+type DiagnosisEvent struct {
+	Kind     string `json:"kind,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"` // Normal Warning
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Count    int32  `json:"count,omitempty"`
+	LastSeen int64  `json:"lastSeen,omitempty"`
+}
+
+// Get 收集进程组的工作负载、实例、存储和事件，生成诊断报告
+func (o *diagnosisOperation) Get(ctx context.Context, namespace, group string) (*Diagnosis, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(group) < 1 {
+		return nil, uerrors.NewValidationError("group", "请传入进程组名称")
+	}
+	d := &Diagnosis{
+		Namespace: namespace,
+		Group:     group,
+		Health:    health.UP,
+		Created:   time.Now().Unix(),
+		Objects:   make(map[string][]string),
+		uids:      make(map[k8stypes.UID]bool),
+	}
+	if status, err := o.k8s.Rollout().Status(ctx, namespace, group); err != nil {
+		d.problem(health.DOWN, fmt.Sprintf("获取发布状态失败: %s", err.Error()))
+	} else {
+		d.Rollout = status
+		if status.IsFailed() {
+			d.problem(health.DOWN, fmt.Sprintf("发布失败: %s", status.Message))
+		} else if !status.IsComplete() {
+			d.problem(health.PENDING, fmt.Sprintf("发布中: 已更新 %d/%d, 可用 %d", status.Updated, status.Desired, status.Available))
+		}
+	}
+	if err := o.workloads(ctx, d); err != nil {
+		return nil, err
+	}
+	claims, err := o.pods(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.storages(ctx, d, claims); err != nil {
+		return nil, err
+	}
+	if err := o.events(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Diagnosis) problem(h health.Health, msg string) {
+	d.Problems = append(d.Problems, msg)
+	if d.Health == health.UP || h == health.DOWN {
+		d.Health = h
+	}
+}
+
+func (d *Diagnosis) object(kind string, meta v1.ObjectMeta) {
+	d.Objects[kind] = append(d.Objects[kind], meta.Name)
+	d.uids[meta.UID] = true
+}
+
+// workloads 记录进程组的工作负载及其 ReplicaSet，用于过滤事件
+func (o *diagnosisOperation) workloads(ctx context.Context, d *Diagnosis) error {
+	apps := o.api.AppsV1()
+	opts := toGroupListOptions(d.Group)
+	operation := fmt.Sprintf("获取工作负载列表: namespace=%s, group=%s", d.Namespace, d.Group)
+	deployments, err := apps.Deployments(d.Namespace).List(ctx, opts)
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, operation)
+	}
+	for _, i := range deployments.Items {
+		d.object(WorkloadDeployment.String(), i.ObjectMeta)
+	}
+	if len(deployments.Items) > 0 {
+		rs, err := apps.ReplicaSets(d.Namespace).List(ctx, opts)
+		if err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, operation)
+		}
+		for _, i := range rs.Items {
+			d.object("ReplicaSet", i.ObjectMeta)
+		}
+	}
+	sts, err := apps.StatefulSets(d.Namespace).List(ctx, opts)
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, operation)
+	}
+	for _, i := range sts.Items {
+		d.object(WorkloadStatefulSet.String(), i.ObjectMeta)
+	}
+	ds, err := apps.DaemonSets(d.Namespace).List(ctx, opts)
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, operation)
+	}
+	for _, i := range ds.Items {
+		d.object(WorkloadDaemonSet.String(), i.ObjectMeta)
+	}
+	return nil
+}
+
+// pods 收集实例状态，返回实例引用的 PVC 名称
+func (o *diagnosisOperation) pods(ctx context.Context, d *Diagnosis) (map[string]bool, error) {
+	pods, err := o.api.CoreV1().Pods(d.Namespace).List(ctx, toGroupListOptions(d.Group))
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Pod列表: namespace=%s, group=%s", d.Namespace, d.Group))
+	}
+	claims := make(map[string]bool)
+	if len(pods.Items) == 0 {
+		d.problem(health.DOWN, "进程组没有运行中的实例")
+	}
+	for _, p := range pods.Items {
+		d.object("Pod", p.ObjectMeta)
+		for _, v := range p.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				claims[v.PersistentVolumeClaim.ClaimName] = true
+			}
+		}
+		pd := toPodDiagnosis(p)
+		d.Pods = append(d.Pods, pd)
+		if len(pd.Scheduling) > 0 {
+			d.problem(health.DOWN, fmt.Sprintf("%s 无法调度: %s", p.Name, pd.Scheduling))
+		}
+		for _, c := range pd.Containers {
+			if isRolloutFatal(c.Reason) {
+				d.problem(health.DOWN, fmt.Sprintf("%s/%s %s: %s", p.Name, c.Name, c.Reason, c.Message))
+			} else if c.LastTerminated != nil && c.RestartCount > 0 {
+				d.problem(health.PENDING, fmt.Sprintf("%s/%s 已重启 %d 次, 上次退出 %s(exitCode=%d)",
+					p.Name, c.Name, c.RestartCount, c.LastTerminated.Reason, c.LastTerminated.ExitCode))
+			}
+		}
+	}
+	return claims, nil
+}
+
+func toPodDiagnosis(p corev1.Pod) *PodDiagnosis {
+	pd := &PodDiagnosis{
+		Name:   p.Name,
+		Node:   p.Spec.NodeName,
+		Phase:  string(p.Status.Phase),
+		Status: p.Status.Reason,
+	}
+	if p.DeletionTimestamp != nil {
+		pd.Status = Terminating
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+			pd.Scheduling = fmt.Sprintf("%s %s", c.Reason, c.Message)
+		}
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		pd.Containers = append(pd.Containers, toContainerDiagnosis(cs))
+	}
+	return pd
+}
+
+func toContainerDiagnosis(cs corev1.ContainerStatus) *ContainerDiagnosis {
+	c := &ContainerDiagnosis{
+		Name:         cs.Name,
+		Ready:        cs.Ready,
+		RestartCount: cs.RestartCount,
+	}
+	switch {
+	case cs.State.Waiting != nil:
+		c.State, c.Reason, c.Message = "waiting", cs.State.Waiting.Reason, cs.State.Waiting.Message
+	case cs.State.Terminated != nil:
+		c.State, c.Reason, c.Message = "terminated", cs.State.Terminated.Reason, cs.State.Terminated.Message
+	case cs.State.Running != nil:
+		c.State = "running"
+	}
+	if t := cs.LastTerminationState.Terminated; t != nil {
+		c.LastTerminated = &Termination{
+			Reason:   t.Reason,
+			Message:  t.Message,
+			ExitCode: t.ExitCode,
+			Finished: t.FinishedAt.Unix(),
+		}
+	}
+	return c
+}
+
+// storages 检查进程组标签下以及实例引用的 PVC 绑定情况
+func (o *diagnosisOperation) storages(ctx context.Context, d *Diagnosis, claims map[string]bool) error {
+	list, err := o.api.CoreV1().PersistentVolumeClaims(d.Namespace).List(ctx, toGroupListOptions(d.Group))
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Storage列表: namespace=%s, group=%s", d.Namespace, d.Group))
+	}
+	pvcs := list.Items
+	for _, pvc := range pvcs {
+		delete(claims, pvc.Name)
+	}
+	for name := range claims {
+		pvc, err := o.api.CoreV1().PersistentVolumeClaims(d.Namespace).Get(ctx, name, v1.GetOptions{})
+		if errors.IsNotFound(err) {
+			d.Storages = append(d.Storages, &StorageDiagnosis{Name: name, Problem: "PVC不存在"})
+			d.problem(health.DOWN, fmt.Sprintf("PVC %s 不存在", name))
+			continue
+		}
+		if err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Storage: namespace=%s, name=%s", d.Namespace, name))
+		}
+		pvcs = append(pvcs, *pvc)
+	}
+	for _, pvc := range pvcs {
+		d.object("PersistentVolumeClaim", pvc.ObjectMeta)
+		sd := &StorageDiagnosis{
+			Name:   pvc.Name,
+			Phase:  string(pvc.Status.Phase),
+			Volume: pvc.Spec.VolumeName,
+		}
+		if pvc.Spec.StorageClassName != nil {
+			sd.StorageClass = *pvc.Spec.StorageClassName
+		}
+		switch {
+		case pvc.DeletionTimestamp != nil:
+			sd.Problem = "PVC正在删除"
+		case pvc.Status.Phase == corev1.ClaimPending:
+			sd.Problem = "PVC未绑定"
+		case pvc.Status.Phase == corev1.ClaimLost:
+			sd.Problem = "PVC绑定的存储卷已丢失"
+		}
+		if len(sd.Problem) > 0 {
+			d.problem(health.DOWN, fmt.Sprintf("PVC %s: %s", pvc.Name, sd.Problem))
+		}
+		d.Storages = append(d.Storages, sd)
+	}
+	return nil
+}
+
+// events 按参与诊断的资源逐个查询最近事件，避免读取整个命名空间的事件
+func (o *diagnosisOperation) events(ctx context.Context, d *Diagnosis) error {
+	kinds := make([]string, 0, len(d.Objects))
+	for kind := range d.Objects {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	var (
+		items = make([]corev1.Event, 0)
+		seen  = make(map[string]bool)
+	)
+	for _, kind := range kinds {
+		for _, name := range d.Objects[kind] {
+			list, err := o.api.CoreV1().Events(d.Namespace).List(ctx, v1.ListOptions{
+				FieldSelector: fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s,involvedObject.namespace=%s", kind, name, d.Namespace),
+			})
+			if err != nil {
+				return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取事件列表: namespace=%s, %s=%s", d.Namespace, kind, name))
+			}
+			for _, e := range list.Items {
+				if seen[e.Name] || !(d.uids[e.InvolvedObject.UID] || d.owns(e.InvolvedObject)) {
+					continue
+				}
+				seen[e.Name] = true
+				items = append(items, e)
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(items[i]).After(eventTime(items[j]))
+	})
+	if len(items) > DefaultDiagnosisEvents {
+		items = items[:DefaultDiagnosisEvents]
+	}
+	for _, e := range items {
+		d.Events = append(d.Events, &DiagnosisEvent{
+			Kind:     e.InvolvedObject.Kind,
+			Name:     e.InvolvedObject.Name,
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  e.Message,
+			Count:    e.Count,
+			LastSeen: eventTime(e).Unix(),
+		})
+	}
+	return nil
+}
+
+// owns 事件未记录 UID 时按 kind 和名称匹配
+func (d *Diagnosis) owns(ref corev1.ObjectReference) bool {
+	if len(ref.UID) > 0 {
+		return false
+	}
+	for _, name := range d.Objects[ref.Kind] {
+		if name == ref.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+)
+
+// 终端消息类型
+const (
+	TerminalStdin  = "stdin"
+	TerminalStdout = "stdout"
+	TerminalResize = "resize"
+)
+
+// ExecOptions 命令执行参数，输入输出由调用方提供
+type ExecOptions struct {
+	Stdin  io.Reader                       // 标准输入，可为空
+	Stdout io.Writer                       // 标准输出，可为空
+	Stderr io.Writer                       // 标准错误，TTY 模式下合并到标准输出
+	TTY    bool                            // 是否分配终端
+	Resize remotecommand.TerminalSizeQueue // 终端尺寸变化，TTY 模式下有效
+}
+
+// CommandResult 单个实例的命令执行结果
+type CommandResult struct {
+	Pod      string `json:"pod,omitempty"`
+	Process  string `json:"process,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+}
+
+// TerminalMessage 浏览器终端与服务端之间的消息
+type TerminalMessage struct {
+	Op   string `json:"op"`             // stdin stdout resize
+	Data string `json:"data,omitempty"` // stdin stdout 的内容
+	Rows uint16 `json:"rows,omitempty"` // resize 的行数
+	Cols uint16 `json:"cols,omitempty"` // resize 的列数
+}
+
+// TerminalSession 将消息收发适配为终端的输入输出和尺寸队列，便于接入 WebSocket
+// read 读取一条客户端消息，write 发送一条服务端消息，均由调用方基于具体的 WebSocket 实现提供
+type TerminalSession struct {
+	read    func() ([]byte, error)
+	write   func([]byte) error
+	sizes   chan remotecommand.TerminalSize
+	pending []byte
+	mu      sync.Mutex
+	once    sync.Once
+	done    chan struct{}
+}
+
+func NewTerminalSession(read func() ([]byte, error), write func([]byte) error) *TerminalSession {
+	return &TerminalSession{
+		read:  read,
+		write: write,
+		sizes: make(chan remotecommand.TerminalSize, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// Read 读取客户端输入，resize 消息转入尺寸队列
+func (t *TerminalSession) Read(p []byte) (int, error) {
+	for len(t.pending) < 1 {
+		data, err := t.read()
+		if err != nil {
+			t.Close()
+			return 0, err
+		}
+		var msg TerminalMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return 0, fmt.Errorf("终端消息格式错误: %w", err)
+		}
+		switch msg.Op {
+		case TerminalStdin:
+			t.pending = []byte(msg.Data)
+		case TerminalResize:
+			select {
+			case <-t.sizes:
+			default:
+			}
+			t.sizes <- remotecommand.TerminalSize{Width: msg.Cols, Height: msg.Rows}
+		}
+	}
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// Write 将命令输出发送给客户端
+func (t *TerminalSession) Write(p []byte) (int, error) {
+	data, err := json.Marshal(TerminalMessage{Op: TerminalStdout, Data: string(p)})
+	if err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.write(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Next 返回下一次终端尺寸，会话结束时返回 nil
+func (t *TerminalSession) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-t.sizes:
+		return &size
+	case <-t.done:
+		return nil
+	}
+}
+
+func (t *TerminalSession) Close() {
+	t.once.Do(func() {
+		close(t.done)
+	})
+}
+
+// Options 以会话作为输入输出的 TTY 执行参数
+func (t *TerminalSession) Options() ExecOptions {
+	return ExecOptions{Stdin: t, Stdout: t, TTY: true, Resize: t}
+}
+
+// Stream 在实例中执行命令并流式传输输入输出，ctx 取消时结束执行
+func (o *podsOperation) Stream(ctx context.Context, namespace, pod, process string, opts ExecOptions, cmd ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(pod) < 1 {
+		return uerrors.NewValidationError("pod", "请传入进程组ID")
+	}
+	if len(process) < 1 {
+		return uerrors.NewValidationError("process", "请传入进程名称")
+	}
+	if cmd == nil || len(cmd) < 1 {
+		return uerrors.NewValidationError("cmd", "请传入要执行的命令")
+	}
+	if o.c == nil {
+		return uerrors.NewKubernetesError(ctx, "执行命令", "未初始化REST配置", fmt.Sprintf("namespace=%s, pod=%s", namespace, pod))
+	}
+	req := o.api.CoreV1().RESTClient().
+		Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: process,
+			Command:   cmd,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    opts.Stdout != nil,
+			Stderr:    opts.Stderr != nil && !opts.TTY,
+			TTY:       opts.TTY,
+		}, runtime.NewParameterCodec(scheme.Scheme))
+	executor, err := remotecommand.NewSPDYExecutor(o.c, "POST", req.URL())
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err,
+			fmt.Sprintf("创建命令执行器: namespace=%s, pod=%s, process=%s", namespace, pod, process))
+	}
+	stream := remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: opts.Stdout,
+		Tty:    opts.TTY,
+	}
+	if opts.TTY {
+		stream.TerminalSizeQueue = opts.Resize
+	} else {
+		stream.Stderr = opts.Stderr
+	}
+	if err := executor.StreamWithContext(ctx, stream); err != nil {
+		var exitErr exec.CodeExitError
+		if errors.As(err, &exitErr) {
+			return exitErr
+		}
+		return uerrors.WrapKubernetesError(ctx, err,
+			fmt.Sprintf("执行命令: namespace=%s, pod=%s, process=%s, cmd=%v", namespace, pod, process, cmd))
+	}
+	return nil
+}
+
+// ExitCode 获取命令的退出码，非命令退出导致的错误返回 -1
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus()
+	}
+	return -1
+}
+
+func (o *podsOperation) exec(ctx context.Context, namespace, pod, process string, cmd ...string) *CommandResult {
+	var stdout, stderr bytes.Buffer
+	err := o.Stream(ctx, namespace, pod, process, ExecOptions{Stdout: &stdout, Stderr: &stderr}, cmd...)
+	result := &CommandResult{
+		Pod:      pod,
+		Process:  process,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: ExitCode(err),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
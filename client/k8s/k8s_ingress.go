@@ -0,0 +1,388 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/uerrors"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	DefaultExposePath     = "/"
+	DefaultExposePathType = "Prefix"
+
+	// GatewayGroupVersion Gateway API 的资源组版本
+	GatewayGroupVersion = "gateway.networking.k8s.io/v1"
+)
+
+// HTTPRouteResource Gateway API 的 HTTPRoute 资源
+var HTTPRouteResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+
+type ingressOperation struct {
+	*options
+	mutex   sync.Mutex
+	gateway *bool // 集群是否支持 Gateway API 的探测结果，nil 表示尚未探测
+}
+
+// ExposeConfig 进程的 HTTP 对外暴露配置
+type ExposeConfig struct {
+	Host         string            `json:"host,omitempty"`         // 访问域名
+	Path         string            `json:"path,omitempty"`         // 访问路径，默认 /
+	PathType     string            `json:"pathType,omitempty"`     // Prefix Exact ImplementationSpecific，默认 Prefix，HTTPRoute 中 ImplementationSpecific 按 Prefix 处理
+	Port         int32             `json:"port,omitempty"`         // 转发到的服务端口，默认取进程的第一个端口
+	TLSSecret    string            `json:"tlsSecret,omitempty"`    // HTTPS 证书所在的 Secret
+	IngressClass string            `json:"ingressClass,omitempty"` // Ingress 控制器类型
+	Gateway      string            `json:"gateway,omitempty"`      // HTTPRoute 挂载的 Gateway，格式 namespace/name，集群支持 Gateway API 时优先使用
+	Annotations  map[string]string `json:"annotations,omitempty"`  // 附加的注解
+}
+
+// Ingress 进程对外暴露的 HTTP 入口，渲染为 Ingress 或 HTTPRoute
+type Ingress struct {
+	Model
+	ExposeConfig
+	Service string `json:"service,omitempty"` // 转发到的服务名
+}
+
+func (e *ExposeConfig) path() string {
+	if len(e.Path) < 1 {
+		return DefaultExposePath
+	}
+	return e.Path
+}
+
+func (e *ExposeConfig) pathType() string {
+	if len(e.PathType) < 1 {
+		return DefaultExposePathType
+	}
+	return gstr.UcFirst(e.PathType)
+}
+
+func (e *ExposeConfig) gateway(namespace string) (string, string) {
+	parts := gstr.SplitAndTrim(e.Gateway, "/")
+	if len(parts) > 1 {
+		return parts[0], parts[1]
+	}
+	return namespace, e.Gateway
+}
+
+func (pg *ProcessGroupConfig) toIngresses() []*Ingress {
+	ingresses := make([]*Ingress, 0)
+	labels := &pg.Labels
+	if len(labels.Group) < 1 {
+		labels.Group = pg.GroupName
+	}
+	for _, p := range pg.Process {
+		if p.Expose == nil || len(p.Expose.Host) < 1 || len(p.Service) < 1 {
+			continue
+		}
+		expose := *p.Expose
+		if expose.Port == 0 && len(p.Ports) > 0 {
+			expose.Port = p.Ports[0].Port
+		}
+		ing := &Ingress{
+			Model:        Model{Namespace: pg.Namespace, Name: p.Service, AllowUpdate: true},
+			ExposeConfig: expose,
+			Service:      p.Service,
+		}
+		ing.setTypesLabels(labels)
+		ingresses = append(ingresses, ing)
+	}
+	return ingresses
+}
+
+func (i *Ingress) toIngress() *networkingv1.Ingress {
+	pathType := networkingv1.PathType(i.pathType())
+	ing := &networkingv1.Ingress{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        i.Model.Name,
+			Namespace:   i.Namespace,
+			Labels:      i.labels(),
+			Annotations: i.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: i.Host,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     i.path(),
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: i.Service,
+							Port: networkingv1.ServiceBackendPort{Number: i.Port},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	if len(i.IngressClass) > 0 {
+		class := i.IngressClass
+		ing.Spec.IngressClassName = &class
+	}
+	if len(i.TLSSecret) > 0 {
+		ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{i.Host}, SecretName: i.TLSSecret}}
+	}
+	return ing
+}
+
+func (i *Ingress) toHTTPRoute() *unstructured.Unstructured {
+	ns, name := i.gateway(i.Namespace)
+	parent := map[string]interface{}{"name": name, "namespace": ns}
+	if len(i.TLSSecret) > 0 {
+		// 证书由 Gateway 的 HTTPS 监听器持有，这里只绑定到 https 监听器
+		parent["sectionName"] = "https"
+	}
+	route := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": GatewayGroupVersion,
+		"kind":       "HTTPRoute",
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{parent},
+			"hostnames":  []interface{}{i.Host},
+			"rules": []interface{}{map[string]interface{}{
+				"matches": []interface{}{map[string]interface{}{
+					"path": map[string]interface{}{"type": toHTTPRoutePathType(i.pathType()), "value": i.path()},
+				}},
+				"backendRefs": []interface{}{map[string]interface{}{
+					"name": i.Service,
+					"port": int64(i.Port),
+				}},
+			}},
+		},
+	}}
+	route.SetName(i.Model.Name)
+	route.SetNamespace(i.Namespace)
+	route.SetLabels(i.labels())
+	if len(i.Annotations) > 0 {
+		route.SetAnnotations(i.Annotations)
+	}
+	return route
+}
+
+func toHTTPRoutePathType(pathType string) string {
+	switch pathType {
+	case "Exact":
+		return "Exact"
+	default:
+		// HTTPRoute 没有与 ImplementationSpecific 对应的类型，按前缀匹配处理
+		return "PathPrefix"
+	}
+}
+
+// Apply 创建或更新 HTTP 入口，配置了 Gateway 且集群支持 Gateway API 时使用 HTTPRoute，否则使用 Ingress
+func (o *ingressOperation) Apply(ctx context.Context, ingress *Ingress) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(ingress.Host) < 1 {
+		return uerrors.NewValidationError("host", "请传入访问域名")
+	}
+	if ingress.Port == 0 {
+		return uerrors.NewValidationError("port", "请传入服务端口")
+	}
+	if ingress.useGateway(o.hasGateway()) {
+		return o.applyHTTPRoute(ctx, ingress)
+	}
+	return o.applyIngress(ctx, ingress)
+}
+
+// useGateway 是否渲染为 HTTPRoute
+func (i *Ingress) useGateway(gateway bool) bool {
+	return gateway && len(i.Gateway) > 0
+}
+
+func (o *ingressOperation) BatchApply(ctx context.Context, ingresses []*Ingress) error {
+	for _, i := range ingresses {
+		if err := o.Apply(ctx, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync 按进程组配置创建或更新 HTTP 入口，并删除该进程组下已不在配置中的 Ingress 和 HTTPRoute
+func (o *ingressOperation) Sync(ctx context.Context, namespace, group string, ingresses []*Ingress) error {
+	if o.err != nil {
+		return o.err
+	}
+	if err := o.BatchApply(ctx, ingresses); err != nil {
+		return err
+	}
+	if len(group) < 1 {
+		return nil
+	}
+	gateway := o.hasGateway()
+	keepIngresses, keepRoutes := map[string]bool{}, map[string]bool{}
+	for _, i := range ingresses {
+		if i.useGateway(gateway) {
+			keepRoutes[i.Model.Name] = true
+		} else {
+			keepIngresses[i.Model.Name] = true
+		}
+	}
+	opts := toGroupListOptions(group)
+	api := o.api.NetworkingV1().Ingresses(namespace)
+	list, err := api.List(ctx, opts)
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Ingress列表: namespace=%s, group=%s", namespace, group))
+	}
+	for _, i := range list.Items {
+		if keepIngresses[i.Name] {
+			continue
+		}
+		if err := api.Delete(ctx, i.Name, v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除Ingress: namespace=%s, name=%s", namespace, i.Name))
+		}
+	}
+	if !gateway {
+		return nil
+	}
+	routes := o.dynamicApi.Resource(HTTPRouteResource).Namespace(namespace)
+	items, err := routes.List(ctx, opts)
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取HTTPRoute列表: namespace=%s, group=%s", namespace, group))
+	}
+	for _, r := range items.Items {
+		if keepRoutes[r.GetName()] {
+			continue
+		}
+		if err := routes.Delete(ctx, r.GetName(), v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除HTTPRoute: namespace=%s, name=%s", namespace, r.GetName()))
+		}
+	}
+	return nil
+}
+
+func (o *ingressOperation) applyIngress(ctx context.Context, ingress *Ingress) error {
+	name := ingress.Model.Name
+	api := o.api.NetworkingV1().Ingresses(ingress.Namespace)
+	data, err := api.Get(ctx, name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查Ingress是否存在: namespace=%s, name=%s", ingress.Namespace, name))
+	if err != nil {
+		return err
+	}
+	ing := ingress.toIngress()
+	if !has {
+		if _, err := api.Create(ctx, ing, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建Ingress: namespace=%s, name=%s", ingress.Namespace, name))
+		}
+		return nil
+	}
+	if !ingress.AllowUpdate {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("Ingress已存在: namespace=%s, name=%s", ingress.Namespace, name))
+	}
+	data.Labels = ing.Labels
+	data.Annotations = ing.Annotations
+	data.Spec = ing.Spec
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新Ingress: namespace=%s, name=%s", ingress.Namespace, name))
+	}
+	return nil
+}
+
+func (o *ingressOperation) applyHTTPRoute(ctx context.Context, ingress *Ingress) error {
+	name := ingress.Model.Name
+	api := o.dynamicApi.Resource(HTTPRouteResource).Namespace(ingress.Namespace)
+	route := ingress.toHTTPRoute()
+	data, err := api.Get(ctx, name, v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := api.Create(ctx, route, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建HTTPRoute: namespace=%s, name=%s", ingress.Namespace, name))
+		}
+		return nil
+	}
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("检查HTTPRoute是否存在: namespace=%s, name=%s", ingress.Namespace, name))
+	}
+	if !ingress.AllowUpdate {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("HTTPRoute已存在: namespace=%s, name=%s", ingress.Namespace, name))
+	}
+	route.SetResourceVersion(data.GetResourceVersion())
+	if _, err := api.Update(ctx, route, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新HTTPRoute: namespace=%s, name=%s", ingress.Namespace, name))
+	}
+	return nil
+}
+
+// DeleteGroup 删除进程组标签下的 Ingress 和 HTTPRoute
+func (o *ingressOperation) DeleteGroup(ctx context.Context, namespace string, groups ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	var lastErr error
+	gateway := o.hasGateway()
+	for _, group := range groups {
+		if len(group) < 1 {
+			continue
+		}
+		opts := toGroupListOptions(group)
+		api := o.api.NetworkingV1().Ingresses(namespace)
+		ingresses, err := api.List(ctx, opts)
+		if err != nil {
+			lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Ingress列表: namespace=%s, group=%s", namespace, group))
+		} else {
+			for _, i := range ingresses.Items {
+				if err := api.Delete(ctx, i.Name, v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+					lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除Ingress: namespace=%s, name=%s", namespace, i.Name))
+				}
+			}
+		}
+		if !gateway {
+			continue
+		}
+		routes := o.dynamicApi.Resource(HTTPRouteResource).Namespace(namespace)
+		list, err := routes.List(ctx, opts)
+		if err != nil {
+			lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取HTTPRoute列表: namespace=%s, group=%s", namespace, group))
+			continue
+		}
+		for _, r := range list.Items {
+			if err := routes.Delete(ctx, r.GetName(), v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除HTTPRoute: namespace=%s, name=%s", namespace, r.GetName()))
+			}
+		}
+	}
+	return lastErr
+}
+
+// hasGateway 集群是否安装了 Gateway API，探测结果按客户端缓存，探测出错时不缓存，下次重新探测
+func (o *ingressOperation) hasGateway() bool {
+	if o.dynamicApi == nil {
+		return false
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.gateway != nil {
+		return *o.gateway
+	}
+	resources, err := o.api.Discovery().ServerResourcesForGroupVersion(GatewayGroupVersion)
+	if err != nil && !errors.IsNotFound(err) {
+		return false
+	}
+	has := false
+	if err == nil && resources != nil {
+		for _, r := range resources.APIResources {
+			if r.Name == HTTPRouteResource.Resource {
+				has = true
+				break
+			}
+		}
+	}
+	o.gateway = &has
+	return has
+}
+
+// resetGateway 客户端变更后重新探测 Gateway API
+func (o *ingressOperation) resetGateway() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.gateway = nil
+}
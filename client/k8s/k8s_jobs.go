@@ -0,0 +1,590 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/types"
+	"github.com/hosgf/element/uerrors"
+	"github.com/hosgf/element/util"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	JobKind     = "Job"
+	CronJobKind = "CronJob"
+
+	// LabelJobName Job 控制器为其 Pod 添加的标签
+	LabelJobName = "batch.kubernetes.io/job-name"
+	// AnnotationInstantiate 标记手动触发的 Job，与 kubectl create job --from 一致
+	AnnotationInstantiate = "cronjob.kubernetes.io/instantiate"
+)
+
+type jobsOperation struct {
+	*options
+	k8s *Kubernetes
+}
+
+// JobConfig 任务配置，容器、配置、存储和环境变量的定义与进程组一致
+type JobConfig struct {
+	Namespace                  string            `json:"namespace,omitempty"`                  // 运行任务的资源空间
+	Name                       string            `json:"name,omitempty"`                       // 任务名称
+	Labels                     types.Labels      `json:"labels,omitempty"`                     // 任务标签
+	Schedule                   string            `json:"schedule,omitempty"`                   // cron 表达式，为空时创建一次性 Job，否则创建 CronJob
+	TimeZone                   string            `json:"timeZone,omitempty"`                   // CronJob 时区，如 Asia/Shanghai
+	Suspend                    bool              `json:"suspend,omitempty"`                    // 暂停 CronJob 调度
+	ConcurrencyPolicy          string            `json:"concurrencyPolicy,omitempty"`          // Allow Forbid Replace，默认 Forbid
+	SuccessfulJobsHistoryLimit *int32            `json:"successfulJobsHistoryLimit,omitempty"` // 保留成功的历史任务数
+	FailedJobsHistoryLimit     *int32            `json:"failedJobsHistoryLimit,omitempty"`     // 保留失败的历史任务数
+	Completions                *int32            `json:"completions,omitempty"`                // 需要成功完成的 Pod 数
+	Parallelism                *int32            `json:"parallelism,omitempty"`                // 并行运行的 Pod 数
+	BackoffLimit               *int32            `json:"backoffLimit,omitempty"`               // 失败重试次数
+	ActiveDeadlineSeconds      *int64            `json:"activeDeadlineSeconds,omitempty"`      // 最长运行时间
+	TTLSecondsAfterFinished    *int32            `json:"ttlSecondsAfterFinished,omitempty"`    // 结束后自动清理的时间
+	RestartPolicy              string            `json:"restartPolicy,omitempty"`              // OnFailure Never，默认 OnFailure
+	AllowUpdate                bool              `json:"allowUpdate,omitempty"`                // 是否允许更新，任务存在则更新（Job 会被重建）
+	Secret                     string            `json:"secret,omitempty"`                     // pull镜像时使用的secret,多个以逗号分隔
+	Scheduling                 *SchedulingConfig `json:"scheduling,omitempty"`                 // 调度约束,可为空
+	Config                     []types.Config    `json:"config,omitempty"`                     // 配置信息
+	Storage                    []types.Storage   `json:"storage,omitempty"`                    // 存储
+	Process                    []ProcessConfig   `json:"process,omitempty"`                    // 任务中运行的进程
+}
+
+// Job 任务运行状态
+type Job struct {
+	Model
+	Kind             string       `json:"kind,omitempty"`     // Job CronJob
+	Schedule         string       `json:"schedule,omitempty"` // CronJob 的 cron 表达式
+	Suspend          bool         `json:"suspend,omitempty"`
+	Status           string       `json:"status,omitempty"` // pending running succeeded failed suspended
+	Active           int32        `json:"active,omitempty"`
+	Succeeded        int32        `json:"succeeded,omitempty"`
+	Failed           int32        `json:"failed,omitempty"`
+	Message          string       `json:"message,omitempty"` // 失败原因
+	StartTime        int64        `json:"startTime,omitempty"`
+	CompletionTime   int64        `json:"completionTime,omitempty"`
+	LastScheduleTime int64        `json:"lastScheduleTime,omitempty"`
+	Containers       []*Container `json:"containers,omitempty"`
+}
+
+// IsFinished 一次性任务是否已结束
+func (j *Job) IsFinished() bool {
+	return j.Status == Succeeded || j.Status == Failed
+}
+
+func (jc *JobConfig) toGroup() *ProcessGroupConfig {
+	return &ProcessGroupConfig{
+		Namespace:   jc.Namespace,
+		GroupName:   jc.Name,
+		Labels:      jc.Labels,
+		AllowUpdate: jc.AllowUpdate,
+		Secret:      jc.Secret,
+		Scheduling:  jc.Scheduling,
+		Config:      jc.Config,
+		Storage:     jc.Storage,
+		Process:     jc.Process,
+	}
+}
+
+func (jc *JobConfig) isCron() bool {
+	return len(jc.Schedule) > 0
+}
+
+func (jc *JobConfig) toPodTemplate(pod *Pod) corev1.PodTemplateSpec {
+	restartPolicy := corev1.RestartPolicyOnFailure
+	if len(jc.RestartPolicy) > 0 {
+		restartPolicy = corev1.RestartPolicy(jc.RestartPolicy)
+	}
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{Labels: pod.labels()},
+		Spec: corev1.PodSpec{
+			Containers:    pod.containers(),
+			Volumes:       pod.toVolumes(),
+			RestartPolicy: restartPolicy,
+		},
+	}
+	pod.applyScheduling(&template.Spec)
+	return template
+}
+
+func (jc *JobConfig) toJobSpec(pod *Pod) batchv1.JobSpec {
+	return batchv1.JobSpec{
+		Completions:             jc.Completions,
+		Parallelism:             jc.Parallelism,
+		BackoffLimit:            jc.BackoffLimit,
+		ActiveDeadlineSeconds:   jc.ActiveDeadlineSeconds,
+		TTLSecondsAfterFinished: jc.TTLSecondsAfterFinished,
+		Template:                jc.toPodTemplate(pod),
+	}
+}
+
+func (jc *JobConfig) toBatchJob(pod *Pod) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: v1.ObjectMeta{Name: jc.Name, Namespace: jc.Namespace, Labels: pod.labels()},
+		Spec:       jc.toJobSpec(pod),
+	}
+}
+
+func (jc *JobConfig) toCronJobSpec(pod *Pod) batchv1.CronJobSpec {
+	policy := batchv1.ForbidConcurrent
+	if len(jc.ConcurrencyPolicy) > 0 {
+		policy = batchv1.ConcurrencyPolicy(gstr.UcFirst(jc.ConcurrencyPolicy))
+	}
+	spec := batchv1.CronJobSpec{
+		Schedule:                   jc.Schedule,
+		Suspend:                    &jc.Suspend,
+		ConcurrencyPolicy:          policy,
+		SuccessfulJobsHistoryLimit: jc.SuccessfulJobsHistoryLimit,
+		FailedJobsHistoryLimit:     jc.FailedJobsHistoryLimit,
+		JobTemplate: batchv1.JobTemplateSpec{
+			ObjectMeta: v1.ObjectMeta{Labels: pod.labels()},
+			Spec:       jc.toJobSpec(pod),
+		},
+	}
+	if len(jc.TimeZone) > 0 {
+		spec.TimeZone = &jc.TimeZone
+	}
+	return spec
+}
+
+func (jc *JobConfig) toCronJob(pod *Pod) *batchv1.CronJob {
+	return &batchv1.CronJob{
+		ObjectMeta: v1.ObjectMeta{Name: jc.Name, Namespace: jc.Namespace, Labels: pod.labels()},
+		Spec:       jc.toCronJobSpec(pod),
+	}
+}
+
+func toJob(data batchv1.Job) *Job {
+	job := &Job{
+		Model:      Model{Namespace: data.Namespace, Name: data.Name},
+		Kind:       JobKind,
+		Active:     data.Status.Active,
+		Succeeded:  data.Status.Succeeded,
+		Failed:     data.Status.Failed,
+		Containers: make([]*Container, 0),
+	}
+	job.setLabels(data.Labels)
+	if data.Spec.Suspend != nil {
+		job.Suspend = *data.Spec.Suspend
+	}
+	if t := data.Status.StartTime; t != nil {
+		job.StartTime = t.Unix()
+	}
+	if t := data.Status.CompletionTime; t != nil {
+		job.CompletionTime = t.Unix()
+	}
+	job.Status = Pending
+	if job.Active > 0 {
+		job.Status = Running
+	}
+	for _, c := range data.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			job.Status = Succeeded
+		case batchv1.JobFailed:
+			job.Status = Failed
+			job.Message = gstr.Join([]string{c.Reason, c.Message}, ": ")
+		case batchv1.JobSuspended:
+			job.Status = Suspended
+		}
+	}
+	job.setContainers(data.Spec.Template.Spec.Containers)
+	return job
+}
+
+func toCronJob(data batchv1.CronJob) *Job {
+	job := &Job{
+		Model:      Model{Namespace: data.Namespace, Name: data.Name},
+		Kind:       CronJobKind,
+		Schedule:   data.Spec.Schedule,
+		Active:     int32(len(data.Status.Active)),
+		Containers: make([]*Container, 0),
+	}
+	job.setLabels(data.Labels)
+	if data.Spec.Suspend != nil {
+		job.Suspend = *data.Spec.Suspend
+	}
+	if t := data.Status.LastScheduleTime; t != nil {
+		job.LastScheduleTime = t.Unix()
+	}
+	if t := data.Status.LastSuccessfulTime; t != nil {
+		job.CompletionTime = t.Unix()
+	}
+	switch {
+	case job.Suspend:
+		job.Status = Suspended
+	case job.Active > 0:
+		job.Status = Running
+	default:
+		job.Status = Active
+	}
+	job.setContainers(data.Spec.JobTemplate.Spec.Template.Spec.Containers)
+	return job
+}
+
+func (j *Job) setContainers(cs []corev1.Container) {
+	pod := &Pod{}
+	for _, c := range cs {
+		pod.toContainer(c)
+	}
+	j.Containers = pod.Containers
+}
+
+// Apply 创建或更新任务，Schedule 非空时为 CronJob
+func (o *jobsOperation) Apply(ctx context.Context, config *JobConfig) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(config.Name) < 1 {
+		return uerrors.NewValidationError("name", "请传入任务名称")
+	}
+	group := config.toGroup()
+	pod := group.toPod()
+	if pod == nil {
+		return uerrors.NewValidationError("process", "请传入任务中运行的进程")
+	}
+	// 与进程组一致，先创建挂载所需的 PV 和 PVC
+	if err := o.k8s.StorageResource().BatchApply(ctx, group.toModel(), group.Storage); err != nil {
+		return err
+	}
+	if err := o.k8s.Storage().BatchApply(ctx, group.toModel(), group.Storage); err != nil {
+		return err
+	}
+	if config.isCron() {
+		return o.applyCronJob(ctx, config, pod)
+	}
+	return o.applyJob(ctx, config, pod)
+}
+
+func (o *jobsOperation) applyJob(ctx context.Context, config *JobConfig, pod *Pod) error {
+	api := o.api.BatchV1().Jobs(config.Namespace)
+	data, err := api.Get(ctx, config.Name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查Job是否存在: namespace=%s, name=%s", config.Namespace, config.Name))
+	if err != nil {
+		return err
+	}
+	if has {
+		if !config.AllowUpdate {
+			return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+				fmt.Sprintf("Job已存在: namespace=%s, name=%s", config.Namespace, config.Name))
+		}
+		// Job 的 Pod 模板不可修改，只能删除后重建
+		if err := o.deleteJob(ctx, config.Namespace, config.Name); err != nil {
+			return err
+		}
+		if err := o.WaitDeleted(ctx, config.Namespace, config.Name, 60*time.Second); err != nil {
+			return err
+		}
+	}
+	if _, err := api.Create(ctx, config.toBatchJob(pod), v1.CreateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建Job: namespace=%s, name=%s", config.Namespace, config.Name))
+	}
+	return nil
+}
+
+func (o *jobsOperation) applyCronJob(ctx context.Context, config *JobConfig, pod *Pod) error {
+	api := o.api.BatchV1().CronJobs(config.Namespace)
+	data, err := api.Get(ctx, config.Name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查CronJob是否存在: namespace=%s, name=%s", config.Namespace, config.Name))
+	if err != nil {
+		return err
+	}
+	if !has {
+		if _, err := api.Create(ctx, config.toCronJob(pod), v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建CronJob: namespace=%s, name=%s", config.Namespace, config.Name))
+		}
+		return nil
+	}
+	if !config.AllowUpdate {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("CronJob已存在: namespace=%s, name=%s", config.Namespace, config.Name))
+	}
+	if data.Labels == nil {
+		data.Labels = map[string]string{}
+	}
+	for k, v := range pod.labels() {
+		data.Labels[k] = v
+	}
+	data.Spec = config.toCronJobSpec(pod)
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新CronJob: namespace=%s, name=%s", config.Namespace, config.Name))
+	}
+	return nil
+}
+
+// List 列出 Job 与 CronJob，groups 为空时列出全部
+func (o *jobsOperation) List(ctx context.Context, namespace string, groups ...string) ([]*Job, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	list := make([]*Job, 0)
+	for _, g := range groups {
+		opts := toGroupListOptions(g)
+		cronJobs, err := o.api.BatchV1().CronJobs(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取CronJob列表: namespace=%s, group=%s", namespace, g))
+		}
+		for _, c := range cronJobs.Items {
+			list = append(list, toCronJob(c))
+		}
+		jobs, err := o.api.BatchV1().Jobs(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Job列表: namespace=%s, group=%s", namespace, g))
+		}
+		for _, j := range jobs.Items {
+			list = append(list, toJob(j))
+		}
+	}
+	return list, nil
+}
+
+// Get 获取任务，同名时优先返回 Job
+func (o *jobsOperation) Get(ctx context.Context, namespace, name string) (*Job, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	job, err := o.api.BatchV1().Jobs(namespace).Get(ctx, name, v1.GetOptions{})
+	if err == nil {
+		return toJob(*job), nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Job: namespace=%s, name=%s", namespace, name))
+	}
+	cronJob, err := o.api.BatchV1().CronJobs(namespace).Get(ctx, name, v1.GetOptions{})
+	if err == nil {
+		return toCronJob(*cronJob), nil
+	}
+	if errors.IsNotFound(err) {
+		return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("任务不存在: namespace=%s, name=%s", namespace, name))
+	}
+	return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取CronJob: namespace=%s, name=%s", namespace, name))
+}
+
+// Delete 删除任务及其创建的 Pod
+func (o *jobsOperation) Delete(ctx context.Context, namespace, name string) error {
+	if o.err != nil {
+		return o.err
+	}
+	if err := o.deleteCronJob(ctx, namespace, name); err != nil {
+		return err
+	}
+	return o.deleteJob(ctx, namespace, name)
+}
+
+// Wait 等待任务结束，CronJob 等待最近一次运行的 Job，超时返回错误
+func (o *jobsOperation) Wait(ctx context.Context, namespace, name string, timeout time.Duration) (*Job, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	name, err := o.jobName(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		data, err := o.api.BatchV1().Jobs(namespace).Get(ctx, name, v1.GetOptions{})
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Job: namespace=%s, name=%s", namespace, name))
+		}
+		job := toJob(*data)
+		if job.IsFinished() {
+			return job, nil
+		}
+		if time.Now().After(deadline) {
+			return job, uerrors.NewKubernetesError(ctx, "等待Job结束", "超时",
+				fmt.Sprintf("namespace=%s, name=%s, timeout=%v", namespace, name, timeout))
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// WaitDeleted 等待 Job 删除完成，超时返回错误
+func (o *jobsOperation) WaitDeleted(ctx context.Context, namespace, name string, timeout time.Duration) error {
+	if o.err != nil {
+		return o.err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := o.api.BatchV1().Jobs(namespace).Get(ctx, name, v1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Job: namespace=%s, name=%s", namespace, name))
+		}
+		if time.Now().After(deadline) {
+			return uerrors.NewKubernetesError(ctx, "等待Job删除", "超时",
+				fmt.Sprintf("namespace=%s, name=%s, timeout=%v", namespace, name, timeout))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// Trigger 按 CronJob 的模板立即运行一次，返回创建的 Job
+func (o *jobsOperation) Trigger(ctx context.Context, namespace, name string) (*Job, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	cronJob, err := o.api.BatchV1().CronJobs(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+				fmt.Sprintf("CronJob不存在: namespace=%s, name=%s", namespace, name))
+		}
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取CronJob: namespace=%s, name=%s", namespace, name))
+	}
+	template := cronJob.Spec.JobTemplate
+	annotations := map[string]string{AnnotationInstantiate: "manual"}
+	for k, v := range template.Annotations {
+		annotations[k] = v
+	}
+	job := &batchv1.Job{
+		ObjectMeta: v1.ObjectMeta{
+			// CronJob 名称最长 52 个字符，随机后缀保证同一时刻多次触发不冲突且不超过 63 个字符
+			Name:        fmt.Sprintf("%s-%s", name, utilrand.String(5)),
+			Namespace:   namespace,
+			Labels:      template.Labels,
+			Annotations: annotations,
+			OwnerReferences: []v1.OwnerReference{
+				*v1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind(CronJobKind)),
+			},
+		},
+		Spec: template.Spec,
+	}
+	created, err := o.api.BatchV1().Jobs(namespace).Create(ctx, job, v1.CreateOptions{})
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("触发CronJob: namespace=%s, name=%s", namespace, name))
+	}
+	return toJob(*created), nil
+}
+
+// Logger 读取任务最近一次运行的 Pod 日志，CronJob 取最近一次调度的 Job
+func (o *jobsOperation) Logger(ctx context.Context, namespace, name, process string, config ProcessLogger) (io.ReadCloser, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(process) < 1 {
+		return nil, uerrors.NewValidationError("process", "请传入进程名称")
+	}
+	pod, err := o.latestPod(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	podLogOpts := &corev1.PodLogOptions{
+		Container:    process,
+		Follow:       config.Follow,
+		Previous:     config.Previous,
+		Timestamps:   config.Timestamps,
+		SinceSeconds: config.SinceSeconds,
+		TailLines:    util.Int64PtrOrDefault(config.TailLines, 100),
+		LimitBytes:   config.LimitBytes,
+		Stream:       GetOutputTypeOrDefault(config.Stream, LoggerOutputAll),
+	}
+	return o.api.CoreV1().Pods(namespace).GetLogs(pod, podLogOpts).Stream(ctx)
+}
+
+func (o *jobsOperation) latestPod(ctx context.Context, namespace, name string) (string, error) {
+	jobName, err := o.jobName(ctx, namespace, name)
+	if err != nil {
+		return "", err
+	}
+	pods, err := o.api.CoreV1().Pods(namespace).List(ctx, toLabelListOptions(LabelJobName, jobName))
+	if err != nil {
+		return "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Job的Pod列表: namespace=%s, job=%s", namespace, jobName))
+	}
+	if len(pods.Items) == 0 {
+		return "", uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("任务没有运行中的进程: namespace=%s, job=%s", namespace, jobName))
+	}
+	items := pods.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+	return items[0].Name, nil
+}
+
+// latestCronJobRun 查找 CronJob 最近一次创建的 Job
+// Job 继承了 CronJob 模板中的标签，先按标签缩小范围，再按属主确认
+// jobName 任务对应的 Job 名称，不存在同名 Job 时按 CronJob 取最近一次运行
+func (o *jobsOperation) jobName(ctx context.Context, namespace, name string) (string, error) {
+	if _, err := o.api.BatchV1().Jobs(namespace).Get(ctx, name, v1.GetOptions{}); !errors.IsNotFound(err) {
+		return name, nil
+	}
+	return o.latestCronJobRun(ctx, namespace, name)
+}
+
+func (o *jobsOperation) latestCronJobRun(ctx context.Context, namespace, name string) (string, error) {
+	cronJob, err := o.api.BatchV1().CronJobs(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return "", uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+				fmt.Sprintf("任务不存在: namespace=%s, name=%s", namespace, name))
+		}
+		return "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取CronJob: namespace=%s, name=%s", namespace, name))
+	}
+	opts := v1.ListOptions{LabelSelector: labels.SelectorFromSet(cronJob.Spec.JobTemplate.Labels).String()}
+	jobs, err := o.api.BatchV1().Jobs(namespace).List(ctx, opts)
+	if err != nil {
+		return "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Job列表: namespace=%s, cronjob=%s", namespace, name))
+	}
+	var latest *batchv1.Job
+	for i, j := range jobs.Items {
+		owned := false
+		for _, ref := range j.OwnerReferences {
+			if ref.Kind == CronJobKind && ref.Name == name && ref.UID == cronJob.UID {
+				owned = true
+				break
+			}
+		}
+		if owned && (latest == nil || latest.CreationTimestamp.Before(&j.CreationTimestamp)) {
+			latest = &jobs.Items[i]
+		}
+	}
+	if latest == nil {
+		return "", uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("任务尚未运行: namespace=%s, name=%s", namespace, name))
+	}
+	return latest.Name, nil
+}
+
+func (o *jobsOperation) deleteJob(ctx context.Context, namespace, name string) error {
+	policy := v1.DeletePropagationBackground
+	err := o.api.BatchV1().Jobs(namespace).Delete(ctx, name, v1.DeleteOptions{PropagationPolicy: &policy})
+	if err != nil && !errors.IsNotFound(err) {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除Job: namespace=%s, name=%s", namespace, name))
+	}
+	return nil
+}
+
+func (o *jobsOperation) deleteCronJob(ctx context.Context, namespace, name string) error {
+	policy := v1.DeletePropagationBackground
+	err := o.api.BatchV1().CronJobs(namespace).Delete(ctx, name, v1.DeleteOptions{PropagationPolicy: &policy})
+	if err != nil && !errors.IsNotFound(err) {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除CronJob: namespace=%s, name=%s", namespace, name))
+	}
+	return nil
+}
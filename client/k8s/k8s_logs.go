@@ -0,0 +1,261 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/hosgf/element/uerrors"
+	"github.com/hosgf/element/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// DefaultLoggerRewatchInterval 监听中断后重新监听实例的间隔
+const DefaultLoggerRewatchInterval = time.Second
+
+// LoggerFormat 进程组日志的输出格式
+type LoggerFormat string
+
+const (
+	LoggerFormatText LoggerFormat = "text" // 每行以 [pod/process] 为前缀
+	LoggerFormatJSON LoggerFormat = "json" // 每行一个 JSON 对象
+)
+
+// LoggerLine 结构化的日志行
+type LoggerLine struct {
+	Pod     string `json:"pod"`
+	Process string `json:"process"`
+	Line    string `json:"line"`
+	Time    int64  `json:"time"` // 采集时间，毫秒
+}
+
+// groupLogger 合并进程组内所有实例的日志流
+type groupLogger struct {
+	api       *podsOperation
+	namespace string
+	group     string
+	process   string
+	config    ProcessLogger
+	grep      *regexp.Regexp
+	writer    *io.PipeWriter
+	wmu       sync.Mutex
+	mu        sync.Mutex
+	started   map[string]int32 // 已开启日志流的进程及其重启次数
+	wg        sync.WaitGroup
+}
+
+type loggerReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *loggerReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+func (c *ProcessLogger) toPodLogOptions(process string, tail bool) *corev1.PodLogOptions {
+	opts := &corev1.PodLogOptions{
+		Container:    process,
+		Follow:       c.Follow,
+		Previous:     c.Previous,
+		Timestamps:   c.Timestamps,
+		SinceSeconds: c.SinceSeconds,
+		LimitBytes:   c.LimitBytes,
+		Stream:       GetOutputTypeOrDefault(c.Stream, LoggerOutputAll),
+	}
+	if tail {
+		opts.TailLines = util.Int64PtrOrDefault(c.TailLines, 100)
+	}
+	return opts
+}
+
+// Logger 获取进程组所有实例的日志，每行带有实例和进程标识
+// process 为空时输出所有进程，Follow 时会跟随发布过程中新建的实例
+func (o *podsOperation) Logger(ctx context.Context, namespace, group, process string, config ProcessLogger) (io.ReadCloser, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(group) < 1 {
+		return nil, uerrors.NewValidationError("group", "请传入进程组名称")
+	}
+	l := &groupLogger{api: o, namespace: namespace, group: group, process: process, config: config, started: map[string]int32{}}
+	if len(config.Grep) > 0 {
+		grep, err := regexp.Compile(config.Grep)
+		if err != nil {
+			return nil, uerrors.NewValidationError("grep", fmt.Sprintf("过滤条件不合法: %s", config.Grep))
+		}
+		l.grep = grep
+	}
+	pods, err := o.list(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) < 1 && !config.Follow {
+		return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("没有查询到进程组: namespace=%s, group=%s", namespace, group))
+	}
+	var watcher watch.Interface
+	if config.Follow {
+		opts := toGroupListOptions(group)
+		opts.ResourceVersion = pods.ResourceVersion
+		if watcher, err = o.api.CoreV1().Pods(namespace).Watch(ctx, opts); err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("监听Pod: namespace=%s, group=%s", namespace, group))
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	reader, writer := io.Pipe()
+	l.writer = writer
+	for i := range pods.Items {
+		l.follow(ctx, &pods.Items[i], true)
+	}
+	go func() {
+		if watcher != nil {
+			l.watch(ctx, watcher, pods.ResourceVersion)
+		}
+		l.wg.Wait()
+		cancel()
+		_ = writer.Close()
+	}()
+	return &loggerReader{PipeReader: reader, cancel: cancel}, nil
+}
+
+// watch 跟随新建和重启的实例，监听中断时从最后的 resourceVersion 重新监听，过期则重新列出实例，直到 ctx 结束
+func (l *groupLogger) watch(ctx context.Context, watcher watch.Interface, resourceVersion string) {
+	for {
+		if watcher != nil {
+			resourceVersion = l.receive(ctx, watcher, resourceVersion)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(DefaultLoggerRewatchInterval):
+		}
+		if len(resourceVersion) < 1 {
+			pods, err := l.api.list(ctx, l.namespace, l.group)
+			if err != nil {
+				watcher = nil
+				continue
+			}
+			for i := range pods.Items {
+				l.follow(ctx, &pods.Items[i], false)
+			}
+			resourceVersion = pods.ResourceVersion
+		}
+		opts := toGroupListOptions(l.group)
+		opts.ResourceVersion = resourceVersion
+		var err error
+		if watcher, err = l.api.api.CoreV1().Pods(l.namespace).Watch(ctx, opts); err != nil {
+			if isExpired(err) {
+				resourceVersion = ""
+			}
+			watcher = nil
+		}
+	}
+}
+
+// receive 处理监听事件直到监听中断，返回最后的 resourceVersion，已过期时返回空
+func (l *groupLogger) receive(ctx context.Context, watcher watch.Interface, resourceVersion string) string {
+	defer watcher.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return resourceVersion
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return resourceVersion
+			}
+			if event.Type == watch.Error {
+				if isExpired(errors.FromObject(event.Object)) {
+					return ""
+				}
+				return resourceVersion
+			}
+			pod, ok := event.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			resourceVersion = pod.ResourceVersion
+			if event.Type == watch.Added || event.Type == watch.Modified {
+				l.follow(ctx, pod, false)
+			}
+		}
+	}
+}
+
+func isExpired(err error) bool {
+	return errors.IsResourceExpired(err) || errors.IsGone(err)
+}
+
+// follow 为实例中已启动的进程开启日志流，进程重启后重新开启
+func (l *groupLogger) follow(ctx context.Context, pod *corev1.Pod, tail bool) {
+	if pod.Status.Phase == corev1.PodPending && len(pod.Status.ContainerStatuses) < 1 {
+		return
+	}
+	started := map[string]bool{}
+	restarts := map[string]int32{}
+	for _, s := range pod.Status.ContainerStatuses {
+		started[s.Name] = s.State.Waiting == nil
+		restarts[s.Name] = s.RestartCount
+	}
+	for _, c := range pod.Spec.Containers {
+		if len(l.process) > 0 && c.Name != l.process {
+			continue
+		}
+		if ok, has := started[c.Name]; has && !ok {
+			continue
+		}
+		key := pod.Name + "/" + c.Name
+		l.mu.Lock()
+		if count, ok := l.started[key]; ok && restarts[c.Name] <= count {
+			l.mu.Unlock()
+			continue
+		}
+		l.started[key] = restarts[c.Name]
+		l.mu.Unlock()
+		l.wg.Add(1)
+		go l.stream(ctx, pod.Name, c.Name, tail)
+	}
+}
+
+func (l *groupLogger) stream(ctx context.Context, pod, process string, tail bool) {
+	defer l.wg.Done()
+	stream, err := l.api.api.CoreV1().Pods(l.namespace).GetLogs(pod, l.config.toPodLogOptions(process, tail)).Stream(ctx)
+	if err != nil {
+		l.write(pod, process, fmt.Sprintf("获取日志失败: %v", err))
+		return
+	}
+	defer stream.Close()
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if l.grep != nil && !l.grep.MatchString(line) {
+			continue
+		}
+		if err := l.write(pod, process, line); err != nil {
+			return
+		}
+	}
+}
+
+func (l *groupLogger) write(pod, process, line string) error {
+	var data []byte
+	if l.config.Format == LoggerFormatJSON {
+		data, _ = json.Marshal(LoggerLine{Pod: pod, Process: process, Line: line, Time: time.Now().UnixMilli()})
+	} else {
+		data = []byte(fmt.Sprintf("[%s/%s] %s", pod, process, line))
+	}
+	data = append(data, '\n')
+	l.wmu.Lock()
+	defer l.wmu.Unlock()
+	_, err := l.writer.Write(data)
+	return err
+}
@@ -0,0 +1,399 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/hosgf/element/types"
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// DefaultDenyPolicyName 命名空间默认拒绝策略的名称
+	DefaultDenyPolicyName = "x-platform-default-deny"
+
+	labelNamespaceName = "kubernetes.io/metadata.name"
+	dnsPort            = 53
+)
+
+type networkPolicyOperation struct {
+	*options
+}
+
+// NetworkConfig 进程组的网络访问控制，声明了入站或出站规则后，该方向只允许规则内的流量
+// 限制出站时会自动放行 DNS
+type NetworkConfig struct {
+	Ingress []NetworkRule `json:"ingress,omitempty"` // 允许访问本进程组的来源，为空时不限制入站
+	Egress  []NetworkRule `json:"egress,omitempty"`  // 允许本进程组访问的目标，为空时不限制出站
+}
+
+// NetworkRule 一条放行规则，来源或目标之间为或的关系，均为空时表示任意地址
+type NetworkRule struct {
+	SameGroup  bool          `json:"sameGroup,omitempty"`  // 同一进程组的实例
+	SameApp    bool          `json:"sameApp,omitempty"`    // 同一应用的实例，按 x-platform-app 标签匹配
+	Namespaces []string      `json:"namespaces,omitempty"` // 指定命名空间内的所有实例
+	CIDRs      []string      `json:"cidrs,omitempty"`      // IP 段，如 10.0.0.0/8
+	Ports      []NetworkPort `json:"ports,omitempty"`      // 端口，为空时不限制
+}
+
+// NetworkPort 放行的端口，EndPort 大于 Port 时为端口范围
+type NetworkPort struct {
+	Port     int32              `json:"port,omitempty"`
+	EndPort  int32              `json:"endPort,omitempty"`
+	Protocol types.ProtocolType `json:"protocol,omitempty"` // TCP UDP SCTP，默认 TCP
+}
+
+// NetworkPolicy 进程组的网络策略
+type NetworkPolicy struct {
+	Model
+	NetworkConfig
+}
+
+func (c *NetworkConfig) validate(app string) error {
+	rules := append(append([]NetworkRule{}, c.Ingress...), c.Egress...)
+	for _, r := range rules {
+		if r.SameApp && len(app) < 1 {
+			return uerrors.NewValidationError("network.sameApp", "进程组未设置应用名称，无法按应用放行")
+		}
+		for _, cidr := range r.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return uerrors.NewValidationError("network.cidrs", fmt.Sprintf("IP段不合法: %s", cidr))
+			}
+		}
+		for _, p := range r.Ports {
+			if p.Port < 0 || p.Port > 65535 || (p.EndPort > 0 && p.EndPort < p.Port) {
+				return uerrors.NewValidationError("network.ports", fmt.Sprintf("端口不合法: port=%d, endPort=%d", p.Port, p.EndPort))
+			}
+		}
+	}
+	return nil
+}
+
+func (pg *ProcessGroupConfig) toNetworkPolicy() *NetworkPolicy {
+	if pg.Network == nil {
+		return nil
+	}
+	labels := &pg.Labels
+	if len(labels.Group) < 1 {
+		labels.Group = pg.GroupName
+	}
+	p := &NetworkPolicy{
+		Model:         Model{Namespace: pg.Namespace, Name: pg.GroupName, AllowUpdate: true},
+		NetworkConfig: *pg.Network,
+	}
+	p.setTypesLabels(labels)
+	return p
+}
+
+func (p *NetworkPolicy) toPolicy() *networkingv1.NetworkPolicy {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      p.Model.Name,
+			Namespace: p.Namespace,
+			Labels:    p.labels(),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: v1.LabelSelector{MatchLabels: p.toSelector()},
+		},
+	}
+	if len(p.Ingress) > 0 {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+		for _, r := range p.Ingress {
+			policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+				From:  p.toPeers(r),
+				Ports: toPolicyPorts(r.Ports),
+			})
+		}
+	}
+	if len(p.Egress) > 0 {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		for _, r := range p.Egress {
+			policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+				To:    p.toPeers(r),
+				Ports: toPolicyPorts(r.Ports),
+			})
+		}
+		policy.Spec.Egress = append(policy.Spec.Egress, dnsEgressRule())
+	}
+	return policy
+}
+
+func (p *NetworkPolicy) toPeers(r NetworkRule) []networkingv1.NetworkPolicyPeer {
+	peers := make([]networkingv1.NetworkPolicyPeer, 0)
+	if r.SameGroup {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector: &v1.LabelSelector{MatchLabels: p.toSelector()},
+		})
+	}
+	if r.SameApp {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector: &v1.LabelSelector{MatchLabels: map[string]string{types.LabelApp.String(): p.App}},
+		})
+	}
+	if len(r.Namespaces) > 0 {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			NamespaceSelector: &v1.LabelSelector{MatchExpressions: []v1.LabelSelectorRequirement{{
+				Key:      labelNamespaceName,
+				Operator: v1.LabelSelectorOpIn,
+				Values:   r.Namespaces,
+			}}},
+		})
+	}
+	for _, cidr := range r.CIDRs {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	return peers
+}
+
+func toPolicyPorts(ports []NetworkPort) []networkingv1.NetworkPolicyPort {
+	if len(ports) < 1 {
+		return nil
+	}
+	list := make([]networkingv1.NetworkPolicyPort, 0, len(ports))
+	for _, p := range ports {
+		protocol := corev1.Protocol(types.ProtocolTcp.String())
+		if len(p.Protocol) > 0 {
+			protocol = corev1.Protocol(p.Protocol.String())
+		}
+		port := networkingv1.NetworkPolicyPort{Protocol: &protocol}
+		if p.Port > 0 {
+			value := intstr.FromInt32(p.Port)
+			port.Port = &value
+		}
+		if p.EndPort > p.Port && p.Port > 0 {
+			end := p.EndPort
+			port.EndPort = &end
+		}
+		list = append(list, port)
+	}
+	return list
+}
+
+// dnsEgressRule 放行到任意命名空间的 DNS 查询
+func dnsEgressRule() networkingv1.NetworkPolicyEgressRule {
+	return networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &v1.LabelSelector{}}},
+		Ports: toPolicyPorts([]NetworkPort{
+			{Port: dnsPort, Protocol: types.ProtocolUdp},
+			{Port: dnsPort, Protocol: types.ProtocolTcp},
+		}),
+	}
+}
+
+func isDNSEgressRule(rule networkingv1.NetworkPolicyEgressRule) bool {
+	if len(rule.To) != 1 || rule.To[0].NamespaceSelector == nil || rule.To[0].PodSelector != nil || len(rule.Ports) != 2 {
+		return false
+	}
+	if len(rule.To[0].NamespaceSelector.MatchLabels) > 0 || len(rule.To[0].NamespaceSelector.MatchExpressions) > 0 {
+		return false
+	}
+	for _, p := range rule.Ports {
+		if p.Port == nil || p.Port.IntValue() != dnsPort {
+			return false
+		}
+	}
+	return true
+}
+
+func toNetworkPolicy(policy networkingv1.NetworkPolicy) *NetworkPolicy {
+	p := &NetworkPolicy{Model: Model{Namespace: policy.Namespace, Name: policy.Name}}
+	p.setLabels(policy.Labels)
+	for _, r := range policy.Spec.Ingress {
+		p.Ingress = append(p.Ingress, p.toRule(r.From, r.Ports))
+	}
+	for _, r := range policy.Spec.Egress {
+		if isDNSEgressRule(r) {
+			continue
+		}
+		p.Egress = append(p.Egress, p.toRule(r.To, r.Ports))
+	}
+	return p
+}
+
+func (p *NetworkPolicy) toRule(peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) NetworkRule {
+	rule := NetworkRule{}
+	for _, peer := range peers {
+		switch {
+		case peer.IPBlock != nil:
+			rule.CIDRs = append(rule.CIDRs, peer.IPBlock.CIDR)
+		case peer.NamespaceSelector != nil:
+			for _, e := range peer.NamespaceSelector.MatchExpressions {
+				if e.Key == labelNamespaceName {
+					rule.Namespaces = append(rule.Namespaces, e.Values...)
+				}
+			}
+		case peer.PodSelector != nil:
+			if _, ok := peer.PodSelector.MatchLabels[types.LabelGroup.String()]; ok {
+				rule.SameGroup = true
+			} else if _, ok := peer.PodSelector.MatchLabels[types.LabelApp.String()]; ok {
+				rule.SameApp = true
+			}
+		}
+	}
+	for _, port := range ports {
+		np := NetworkPort{}
+		if port.Protocol != nil {
+			np.Protocol = types.ProtocolType(*port.Protocol)
+		}
+		if port.Port != nil {
+			np.Port = int32(port.Port.IntValue())
+		}
+		if port.EndPort != nil {
+			np.EndPort = *port.EndPort
+		}
+		rule.Ports = append(rule.Ports, np)
+	}
+	return rule
+}
+
+func (o *networkPolicyOperation) Get(ctx context.Context, namespace, group string) (*NetworkPolicy, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	policy, err := o.api.NetworkingV1().NetworkPolicies(namespace).Get(ctx, group, v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取网络策略: namespace=%s, group=%s", namespace, group))
+	}
+	return toNetworkPolicy(*policy), nil
+}
+
+func (o *networkPolicyOperation) List(ctx context.Context, namespace string, groups ...string) ([]*NetworkPolicy, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	policies := make([]*NetworkPolicy, 0)
+	for _, g := range groups {
+		list, err := o.list(ctx, namespace, g)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range list {
+			policies = append(policies, toNetworkPolicy(p))
+		}
+	}
+	return policies, nil
+}
+
+func (o *networkPolicyOperation) list(ctx context.Context, namespace, group string) ([]networkingv1.NetworkPolicy, error) {
+	list, err := o.api.NetworkingV1().NetworkPolicies(namespace).List(ctx, toGroupListOptions(group))
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取网络策略列表: namespace=%s, group=%s", namespace, group))
+	}
+	return list.Items, nil
+}
+
+// Apply 创建或更新进程组的网络策略
+func (o *networkPolicyOperation) Apply(ctx context.Context, policy *NetworkPolicy) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(policy.Group) < 1 {
+		return uerrors.NewValidationError("group", "请传入进程组名称")
+	}
+	if err := policy.validate(policy.App); err != nil {
+		return err
+	}
+	name := policy.Model.Name
+	api := o.api.NetworkingV1().NetworkPolicies(policy.Namespace)
+	data, err := api.Get(ctx, name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查网络策略是否存在: namespace=%s, name=%s", policy.Namespace, name))
+	if err != nil {
+		return err
+	}
+	target := policy.toPolicy()
+	if !has {
+		if _, err := api.Create(ctx, target, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建网络策略: namespace=%s, name=%s", policy.Namespace, name))
+		}
+		return nil
+	}
+	data.Labels = target.Labels
+	data.Spec = target.Spec
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新网络策略: namespace=%s, name=%s", policy.Namespace, name))
+	}
+	return nil
+}
+
+func (o *networkPolicyOperation) DeleteGroup(ctx context.Context, namespace string, groups ...string) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(groups) < 1 {
+		return uerrors.NewValidationError("groups", "请传入要删除的进程组名称")
+	}
+	api := o.api.NetworkingV1().NetworkPolicies(namespace)
+	for _, group := range groups {
+		if len(group) < 1 {
+			continue
+		}
+		list, err := o.list(ctx, namespace, group)
+		if err != nil {
+			return err
+		}
+		for _, p := range list {
+			if err := api.Delete(ctx, p.Name, v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除网络策略: namespace=%s, name=%s", namespace, p.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultDeny 设置命名空间的默认拒绝策略，未被进程组网络策略放行的流量将被拒绝
+// 拒绝出站时仍放行 DNS，ingress 和 egress 均为 false 时删除默认拒绝策略
+func (o *networkPolicyOperation) DefaultDeny(ctx context.Context, namespace string, ingress, egress bool) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(namespace) < 1 {
+		return uerrors.NewValidationError("namespace", "请传入命名空间")
+	}
+	api := o.api.NetworkingV1().NetworkPolicies(namespace)
+	if !ingress && !egress {
+		if err := api.Delete(ctx, DefaultDenyPolicyName, v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除默认拒绝策略: namespace=%s", namespace))
+		}
+		return nil
+	}
+	spec := networkingv1.NetworkPolicySpec{PodSelector: v1.LabelSelector{}}
+	if ingress {
+		spec.PolicyTypes = append(spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+	}
+	if egress {
+		spec.PolicyTypes = append(spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		spec.Egress = []networkingv1.NetworkPolicyEgressRule{dnsEgressRule()}
+	}
+	data, err := api.Get(ctx, DefaultDenyPolicyName, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查默认拒绝策略是否存在: namespace=%s", namespace))
+	if err != nil {
+		return err
+	}
+	if !has {
+		policy := &networkingv1.NetworkPolicy{
+			ObjectMeta: v1.ObjectMeta{Name: DefaultDenyPolicyName, Namespace: namespace},
+			Spec:       spec,
+		}
+		if _, err := api.Create(ctx, policy, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建默认拒绝策略: namespace=%s", namespace))
+		}
+		return nil
+	}
+	data.Spec = spec
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新默认拒绝策略: namespace=%s", namespace))
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	DefaultDrainTimeout  = 5 * time.Minute
+	DefaultDrainInterval = 2 * time.Second
+
+	annotationMirrorPod = "kubernetes.io/config.mirror"
+)
+
+// 污点效果
+const (
+	TaintNoSchedule       = string(corev1.TaintEffectNoSchedule)
+	TaintPreferNoSchedule = string(corev1.TaintEffectPreferNoSchedule)
+	TaintNoExecute        = string(corev1.TaintEffectNoExecute)
+)
+
+// Taint 节点污点
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"` // NoSchedule PreferNoSchedule NoExecute
+}
+
+func (t *Taint) validate() error {
+	if len(t.Key) < 1 {
+		return uerrors.NewValidationError("taint", "请传入污点的键")
+	}
+	switch t.Effect {
+	case TaintNoSchedule, TaintPreferNoSchedule, TaintNoExecute:
+		return nil
+	default:
+		return uerrors.NewValidationError("taint", fmt.Sprintf("污点效果不合法: key=%s, effect=%s", t.Key, t.Effect))
+	}
+}
+
+func toTaints(taints []corev1.Taint) []Taint {
+	if len(taints) < 1 {
+		return nil
+	}
+	list := make([]Taint, 0, len(taints))
+	for _, t := range taints {
+		list = append(list, Taint{Key: t.Key, Value: t.Value, Effect: string(t.Effect)})
+	}
+	return list
+}
+
+// DrainOptions 节点排空参数
+type DrainOptions struct {
+	GracePeriodSeconds *int64                      // 实例的优雅退出时间，为空时使用实例自身的配置
+	Timeout            time.Duration               // 整体超时时间，默认 5 分钟
+	Interval           time.Duration               // 重试驱逐和检查实例退出的间隔，默认 2 秒
+	Force              bool                        // 是否驱逐没有控制器管理的实例，这类实例被驱逐后不会重建
+	Progress           func(progress *DrainStatus) // 进度回调
+}
+
+// DrainStatus 节点排空进度
+type DrainStatus struct {
+	Node     string   `json:"node"`
+	Total    int      `json:"total"`             // 需要驱逐的实例数
+	Evicted  []string `json:"evicted,omitempty"` // 已驱逐并退出的实例，namespace/name
+	Pending  []string `json:"pending,omitempty"` // 尚未退出的实例
+	Skipped  []string `json:"skipped,omitempty"` // 跳过的 DaemonSet、静态和已结束的实例
+	Blocked  []string `json:"blocked,omitempty"` // 当前被 PodDisruptionBudget 阻止驱逐的实例
+	Message  string   `json:"message,omitempty"` // 最近一次的说明
+	Complete bool     `json:"complete"`
+}
+
+func (s *DrainStatus) report(opts *DrainOptions) {
+	if opts.Progress == nil {
+		return
+	}
+	status := *s
+	opts.Progress(&status)
+}
+
+// Cordon 封锁节点，新的实例不会再调度到该节点
+func (o *nodesOperation) Cordon(ctx context.Context, name string) error {
+	return o.schedulable(ctx, name, false)
+}
+
+// Uncordon 解除节点封锁
+func (o *nodesOperation) Uncordon(ctx context.Context, name string) error {
+	return o.schedulable(ctx, name, true)
+}
+
+func (o *nodesOperation) schedulable(ctx context.Context, name string, schedulable bool) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(name) < 1 {
+		return uerrors.NewValidationError("name", "请传入节点名称")
+	}
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, !schedulable)
+	if _, err := o.api.CoreV1().Nodes().Patch(ctx, name, k8stypes.MergePatchType, []byte(patch), v1.PatchOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新节点调度状态: name=%s, schedulable=%t", name, schedulable))
+	}
+	return nil
+}
+
+// Drain 封锁并排空节点，通过驱逐接口逐个迁移实例，遵守 PodDisruptionBudget
+// DaemonSet、静态实例和已结束的实例会被跳过，没有控制器管理的实例需要 Force
+func (o *nodesOperation) Drain(ctx context.Context, name string, opts DrainOptions) (*DrainStatus, error) {
+	if err := o.Cordon(ctx, name); err != nil {
+		return nil, err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultDrainTimeout
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultDrainInterval
+	}
+	list, err := o.api.CoreV1().Pods("").List(ctx, v1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", name).String(),
+	})
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取节点上的实例: node=%s", name))
+	}
+	status := &DrainStatus{Node: name}
+	pods := make([]corev1.Pod, 0, len(list.Items))
+	var unmanaged []string
+	for _, pod := range list.Items {
+		key := pod.Namespace + "/" + pod.Name
+		if skipDrain(&pod) {
+			status.Skipped = append(status.Skipped, key)
+			continue
+		}
+		if v1.GetControllerOf(&pod) == nil {
+			unmanaged = append(unmanaged, key)
+		}
+		pods = append(pods, pod)
+	}
+	if len(unmanaged) > 0 && !opts.Force {
+		return status, uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("节点上存在没有控制器管理的实例，需要强制驱逐: node=%s, pods=%v", name, unmanaged))
+	}
+	status.Total = len(pods)
+	status.report(&opts)
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	requested := map[string]bool{}
+	for len(pods) > 0 {
+		remaining := make([]corev1.Pod, 0, len(pods))
+		status.Pending, status.Blocked = nil, nil
+		for _, pod := range pods {
+			key := pod.Namespace + "/" + pod.Name
+			if !requested[key] {
+				evicted, err := o.evict(ctx, &pod, opts.GracePeriodSeconds)
+				if err != nil {
+					return status, err
+				}
+				if !evicted {
+					status.Blocked = append(status.Blocked, key)
+					remaining = append(remaining, pod)
+					continue
+				}
+				requested[key] = true
+			}
+			gone, err := o.deleted(ctx, &pod)
+			if err != nil {
+				return status, err
+			}
+			if gone {
+				status.Evicted = append(status.Evicted, key)
+				continue
+			}
+			status.Pending = append(status.Pending, key)
+			remaining = append(remaining, pod)
+		}
+		pods = remaining
+		if len(pods) < 1 {
+			break
+		}
+		status.Message = fmt.Sprintf("等待实例退出: pending=%d, blocked=%d", len(status.Pending), len(status.Blocked))
+		status.report(&opts)
+		select {
+		case <-ctx.Done():
+			return status, uerrors.NewKubernetesError(ctx, "排空节点", "超时",
+				fmt.Sprintf("node=%s, timeout=%v, pending=%v, blocked=%v", name, opts.Timeout, status.Pending, status.Blocked))
+		case <-time.After(opts.Interval):
+		}
+	}
+	status.Pending, status.Blocked = nil, nil
+	status.Message = ""
+	status.Complete = true
+	status.report(&opts)
+	return status, nil
+}
+
+// skipDrain DaemonSet 的实例会被立即重建，静态实例无法驱逐，已结束的实例无需迁移
+func skipDrain(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	if _, ok := pod.Annotations[annotationMirrorPod]; ok {
+		return true
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+	if ref := v1.GetControllerOf(pod); ref != nil && ref.Kind == "DaemonSet" {
+		return true
+	}
+	return false
+}
+
+// evict 驱逐实例，被 PodDisruptionBudget 拒绝时返回 false
+func (o *nodesOperation) evict(ctx context.Context, pod *corev1.Pod, gracePeriodSeconds *int64) (bool, error) {
+	eviction := &policyv1.Eviction{
+		ObjectMeta:    v1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		DeleteOptions: &v1.DeleteOptions{GracePeriodSeconds: gracePeriodSeconds},
+	}
+	err := o.api.CoreV1().Pods(pod.Namespace).EvictV1(ctx, eviction)
+	switch {
+	case err == nil, errors.IsNotFound(err):
+		return true, nil
+	case errors.IsTooManyRequests(err):
+		return false, nil
+	default:
+		return false, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("驱逐实例: namespace=%s, pod=%s", pod.Namespace, pod.Name))
+	}
+}
+
+// deleted 实例是否已退出，同名实例被重建时以 UID 区分
+func (o *nodesOperation) deleted(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	current, err := o.api.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取实例: namespace=%s, pod=%s", pod.Namespace, pod.Name))
+	}
+	return current.UID != pod.UID, nil
+}
+
+// SetLabels 添加或更新节点标签
+func (o *nodesOperation) SetLabels(ctx context.Context, name string, labels map[string]string) error {
+	if len(labels) < 1 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(labels))
+	for k, v := range labels {
+		if len(k) < 1 {
+			return uerrors.NewValidationError("labels", "标签的键不能为空")
+		}
+		values[k] = v
+	}
+	return o.patchLabels(ctx, name, values)
+}
+
+// RemoveLabels 删除节点标签
+func (o *nodesOperation) RemoveLabels(ctx context.Context, name string, keys ...string) error {
+	if len(keys) < 1 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		values[k] = nil
+	}
+	return o.patchLabels(ctx, name, values)
+}
+
+func (o *nodesOperation) patchLabels(ctx context.Context, name string, labels map[string]interface{}) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(name) < 1 {
+		return uerrors.NewValidationError("name", "请传入节点名称")
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}})
+	if err != nil {
+		return err
+	}
+	if _, err := o.api.CoreV1().Nodes().Patch(ctx, name, k8stypes.MergePatchType, patch, v1.PatchOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新节点标签: name=%s", name))
+	}
+	return nil
+}
+
+// AddTaints 添加节点污点，键和效果相同的污点会被覆盖
+func (o *nodesOperation) AddTaints(ctx context.Context, name string, taints ...Taint) error {
+	for i := range taints {
+		if err := taints[i].validate(); err != nil {
+			return err
+		}
+	}
+	return o.updateTaints(ctx, name, func(current []corev1.Taint) []corev1.Taint {
+		for _, t := range taints {
+			taint := corev1.Taint{Key: t.Key, Value: t.Value, Effect: corev1.TaintEffect(t.Effect)}
+			replaced := false
+			for i := range current {
+				if current[i].MatchTaint(&taint) {
+					current[i] = taint
+					replaced = true
+				}
+			}
+			if !replaced {
+				current = append(current, taint)
+			}
+		}
+		return current
+	})
+}
+
+// RemoveTaints 删除节点污点，effect 为空时删除该键的所有污点
+func (o *nodesOperation) RemoveTaints(ctx context.Context, name string, taints ...Taint) error {
+	return o.updateTaints(ctx, name, func(current []corev1.Taint) []corev1.Taint {
+		kept := current[:0]
+		for _, c := range current {
+			removed := false
+			for _, t := range taints {
+				if c.Key == t.Key && (len(t.Effect) < 1 || string(c.Effect) == t.Effect) {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				kept = append(kept, c)
+			}
+		}
+		return kept
+	})
+}
+
+func (o *nodesOperation) updateTaints(ctx context.Context, name string, update func([]corev1.Taint) []corev1.Taint) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(name) < 1 {
+		return uerrors.NewValidationError("name", "请传入节点名称")
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := o.api.CoreV1().Nodes().Get(ctx, name, v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		node.Spec.Taints = update(node.Spec.Taints)
+		_, err = o.api.CoreV1().Nodes().Update(ctx, node, v1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新节点污点: name=%s", name))
+	}
+	return nil
+}
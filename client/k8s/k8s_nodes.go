@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/gogf/gf/v2/text/gstr"
@@ -15,27 +16,37 @@ import (
 )
 
 type Node struct {
-	Name       string                                       `json:"name,omitempty"`
-	Address    string                                       `json:"address,omitempty"`
-	Roles      string                                       `json:"roles,omitempty"`
-	Status     health.Health                                `json:"status,omitempty"`
-	Cpu        resource.Details                             `json:"cpu,omitempty"`
-	Memory     resource.Details                             `json:"memory,omitempty"`
-	Time       int64                                        `json:"time"`
-	Indicators map[health.Indicator]health.IndicatorDetails `json:"indicators,omitempty"` // 指标
+	Name        string                                       `json:"name,omitempty"`
+	Address     string                                       `json:"address,omitempty"`
+	Roles       string                                       `json:"roles,omitempty"`
+	Status      health.Health                                `json:"status,omitempty"`
+	Cpu         resource.Details                             `json:"cpu,omitempty"`
+	Memory      resource.Details                             `json:"memory,omitempty"`
+	Schedulable bool                                         `json:"schedulable"` // 是否可调度，封锁后为 false
+	Labels      map[string]string                            `json:"labels,omitempty"`
+	Taints      []Taint                                      `json:"taints,omitempty"`
+	Time        int64                                        `json:"time"`
+	Indicators  map[health.Indicator]health.IndicatorDetails `json:"indicators,omitempty"` // 指标
 }
 
 func (n *Node) ToNode() resource.Node {
 	node := resource.Node{
-		Name:       n.Name,
-		Status:     n.Status,
-		Roles:      n.Roles,
-		Time:       n.Time,
-		Indicators: map[string]interface{}{},
+		Name:        n.Name,
+		Status:      n.Status,
+		Roles:       n.Roles,
+		Schedulable: n.Schedulable,
+		Time:        n.Time,
+		Indicators:  map[string]interface{}{},
 		Details: map[string]interface{}{
 			"address": n.Address,
 		},
 	}
+	if len(n.Labels) > 0 {
+		node.Details["labels"] = n.Labels
+	}
+	if len(n.Taints) > 0 {
+		node.Details["taints"] = n.Taints
+	}
 	node.Indicators[types.ResourceCPU.String()] = n.Cpu
 	node.Indicators[types.ResourceMemory.String()] = n.Memory
 	for k, v := range n.Indicators {
@@ -65,71 +76,94 @@ func (o *nodesOperation) Top(ctx context.Context) ([]*Node, error) {
 	for _, v := range list.Items {
 		metricses[v.Name] = v
 	}
-	for _, n := range datas.Items {
-		node := &Node{
-			Name:       n.Name,
-			Cpu:        resource.Details{Unit: types.DefaultCpuUnit},
-			Memory:     resource.Details{},
-			Indicators: map[health.Indicator]health.IndicatorDetails{},
+	for i := range datas.Items {
+		node := toNode(&datas.Items[i])
+		// 空闲资源
+		if v, ok := metricses[node.Name]; ok {
+			usage := v.Usage
+			node.Cpu.ThroughUsageConstruction(usage.Cpu().MilliValue())
+			node.Memory.SetUsage(usage.Memory().String())
+			node.Memory.ThroughUsageConstruction(node.Memory.Usage)
 		}
-		for _, address := range n.Status.Addresses {
-			switch address.Type {
-			case corev1.NodeInternalIP:
-				node.Address = address.Address
-			}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Get 获取单个节点的状态，不包含资源使用量
+func (o *nodesOperation) Get(ctx context.Context, name string) (*Node, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(name) < 1 {
+		return nil, uerrors.NewValidationError("name", "请传入节点名称")
+	}
+	n, err := o.api.CoreV1().Nodes().Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取节点: name=%s", name))
+	}
+	return toNode(n), nil
+}
+
+func toNode(n *corev1.Node) *Node {
+	node := &Node{
+		Name:        n.Name,
+		Cpu:         resource.Details{Unit: types.DefaultCpuUnit},
+		Memory:      resource.Details{},
+		Schedulable: !n.Spec.Unschedulable,
+		Labels:      n.Labels,
+		Taints:      toTaints(n.Spec.Taints),
+		Indicators:  map[health.Indicator]health.IndicatorDetails{},
+	}
+	for _, address := range n.Status.Addresses {
+		switch address.Type {
+		case corev1.NodeInternalIP:
+			node.Address = address.Address
 		}
-		if role, ok := n.Labels["kubernetes.io/role"]; ok {
-			node.Roles = role
-		} else {
-			for k, _ := range n.Labels {
-				if gstr.HasPrefix(k, "node-role.kubernetes.io/") {
-					node.Roles = strings.TrimPrefix(k, "node-role.kubernetes.io/")
-					break
-				}
+	}
+	if role, ok := n.Labels["kubernetes.io/role"]; ok {
+		node.Roles = role
+	} else {
+		for k, _ := range n.Labels {
+			if gstr.HasPrefix(k, "node-role.kubernetes.io/") {
+				node.Roles = strings.TrimPrefix(k, "node-role.kubernetes.io/")
+				break
 			}
 		}
+	}
 
-		// 资源总量
-		for name, quantity := range n.Status.Allocatable {
-			switch name {
-			case corev1.ResourceCPU:
-				node.Cpu.SetTotalValue(quantity.MilliValue())
-			case corev1.ResourceMemory:
-				node.Memory.SetTotal(quantity.String())
-			}
+	// 资源总量
+	for name, quantity := range n.Status.Allocatable {
+		switch name {
+		case corev1.ResourceCPU:
+			node.Cpu.SetTotalValue(quantity.MilliValue())
+		case corev1.ResourceMemory:
+			node.Memory.SetTotal(quantity.String())
 		}
+	}
 
-		// 空闲资源
-		if v, ok := metricses[n.Name]; ok {
-			usage := v.Usage
-			node.Cpu.ThroughUsageConstruction(usage.Cpu().MilliValue())
-			node.Memory.SetUsage(usage.Memory().String())
-			node.Memory.ThroughUsageConstruction(node.Memory.Usage)
+	// 状态
+	for _, condition := range n.Status.Conditions {
+		status := string(condition.Status)
+		details := health.IndicatorDetails{
+			Status:  status,
+			Reason:  condition.Reason,
+			Message: condition.Message,
 		}
-		// 状态
-		for _, condition := range n.Status.Conditions {
-			status := string(condition.Status)
-			details := health.IndicatorDetails{
-				Status:  status,
-				Reason:  condition.Reason,
-				Message: condition.Message,
-			}
-			switch condition.Type {
-			case corev1.NodeReady:
-				node.Time = condition.LastTransitionTime.Unix()
-				node.Status = NodeStatus(status)
-				node.Indicators[health.IndicatorNodeStatus] = details
-			case corev1.NodeMemoryPressure:
-				node.Indicators[health.IndicatorMemoryStatus] = details
-			case corev1.NodeDiskPressure:
-				node.Indicators[health.IndicatorDiskStatus] = details
-			case corev1.NodeNetworkUnavailable:
-				node.Indicators[health.IndicatorNetworkStatus] = details
-			case corev1.NodePIDPressure:
-				node.Indicators[health.IndicatorNodePIDPressure] = details
-			}
+		switch condition.Type {
+		case corev1.NodeReady:
+			node.Time = condition.LastTransitionTime.Unix()
+			node.Status = NodeStatus(status)
+			node.Indicators[health.IndicatorNodeStatus] = details
+		case corev1.NodeMemoryPressure:
+			node.Indicators[health.IndicatorMemoryStatus] = details
+		case corev1.NodeDiskPressure:
+			node.Indicators[health.IndicatorDiskStatus] = details
+		case corev1.NodeNetworkUnavailable:
+			node.Indicators[health.IndicatorNetworkStatus] = details
+		case corev1.NodePIDPressure:
+			node.Indicators[health.IndicatorNodePIDPressure] = details
 		}
-		nodes = append(nodes, node)
 	}
-	return nodes, nil
+	return node
 }
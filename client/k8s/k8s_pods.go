@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"io"
 
 	"github.com/gogf/gf/v2/text/gstr"
 	"github.com/gogf/gf/v2/util/gconv"
@@ -12,14 +11,10 @@ import (
 	"github.com/hosgf/element/model/resource"
 	"github.com/hosgf/element/types"
 	"github.com/hosgf/element/uerrors"
-	"github.com/hosgf/element/util"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	res "k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/remotecommand"
 )
 
 type podsOperation struct {
@@ -28,12 +23,19 @@ type podsOperation struct {
 
 type Pod struct {
 	Model
-	Replicas    int32           `json:"replicas,omitempty"`
-	RunningNode string          `json:"runningNode,omitempty"`
-	Status      string          `json:"status,omitempty"`
-	Config      []types.Config  `json:"config,omitempty"`
-	Storage     []types.Storage `json:"storage,omitempty"`
-	Containers  []*Container    `json:"containers,omitempty"`
+	Kind          WorkloadKind       `json:"kind,omitempty"`
+	Replicas      int32              `json:"replicas,omitempty"`
+	Autoscaling   *AutoscalingConfig `json:"autoscaling,omitempty"`
+	RunningNode   string             `json:"runningNode,omitempty"`
+	Status        string             `json:"status,omitempty"`
+	Secrets       []string           `json:"secrets,omitempty"`
+	Scheduling    *SchedulingConfig  `json:"scheduling,omitempty"`
+	Config        []types.Config     `json:"config,omitempty"`
+	ConfigMaps    []ConfigMapConfig  `json:"configMaps,omitempty"`
+	SecretConfigs []SecretConfig     `json:"secretConfigs,omitempty"`
+	ConfigHash    string             `json:"configHash,omitempty"`
+	Storage       []types.Storage    `json:"storage,omitempty"`
+	Containers    []*Container       `json:"containers,omitempty"`
 }
 
 func (pod *Pod) updateAppsDeployment(deployment *appsv1.Deployment) *appsv1.Deployment {
@@ -41,10 +43,14 @@ func (pod *Pod) updateAppsDeployment(deployment *appsv1.Deployment) *appsv1.Depl
 		deployment.ObjectMeta.Labels[k] = v
 		deployment.Spec.Template.ObjectMeta.Labels[k] = v
 	}
-	deployment.Spec.Replicas = pod.replicas()
+	if pod.Autoscaling == nil {
+		deployment.Spec.Replicas = pod.replicas()
+	}
 	deployment.Spec.Selector.MatchLabels = pod.toSelector()
 	deployment.Spec.Template.Spec.Containers = pod.containers()
 	deployment.Spec.Template.Spec.Volumes = pod.toVolumes()
+	pod.applyScheduling(&deployment.Spec.Template.Spec)
+	pod.applyConfigHash(&deployment.Spec.Template.ObjectMeta)
 	return deployment
 }
 
@@ -54,7 +60,7 @@ func (pod *Pod) toAppsDeployment() *appsv1.Deployment {
 		Namespace: pod.Namespace,
 		Labels:    pod.labels(),
 	}
-	return &appsv1.Deployment{
+	deployment := &appsv1.Deployment{
 		ObjectMeta: metadata,
 		Spec: appsv1.DeploymentSpec{
 			Replicas: pod.replicas(),
@@ -70,6 +76,26 @@ func (pod *Pod) toAppsDeployment() *appsv1.Deployment {
 			},
 		},
 	}
+	pod.applyScheduling(&deployment.Spec.Template.Spec)
+	pod.applyConfigHash(&deployment.Spec.Template.ObjectMeta)
+	return deployment
+}
+
+// toDeploymentPod 从 Deployment 还原进程组定义
+func toDeploymentPod(d appsv1.Deployment) *Pod {
+	pod := toWorkloadPod(WorkloadDeployment, d.ObjectMeta, d.Spec.Replicas, d.Spec.Template)
+	pod.Status = deploymentStatus(d)
+	return pod
+}
+
+func deploymentStatus(d appsv1.Deployment) string {
+	if d.Spec.Replicas != nil && *d.Spec.Replicas == 0 {
+		return Succeeded
+	}
+	if d.Status.AvailableReplicas > 0 && d.Status.AvailableReplicas >= d.Status.Replicas {
+		return Running
+	}
+	return Pending
 }
 
 func (pod *Pod) setVolumes(vs []corev1.Volume) {
@@ -101,6 +127,7 @@ func (pod *Pod) setVolumes(vs []corev1.Volume) {
 
 func (pod *Pod) toVolumes() []corev1.Volume {
 	volumes := append(make([]corev1.Volume, 0), pod.toConfigVolumes()...)
+	volumes = append(volumes, pod.toConfigMapVolumes()...)
 	volumes = append(volumes, pod.toStorageVolumes()...)
 	return volumes
 }
@@ -203,6 +230,9 @@ func (pod *Pod) ToProcess(svcs []*Service, metric *Metric, now int64) []*process
 			Time:       now,
 		}
 		p.Details["runningNode"] = pod.RunningNode
+		if len(pod.Kind) > 0 {
+			p.Details["kind"] = pod.Kind.String()
+		}
 		metrics := items[c.Name]
 		for _, res := range c.Resource {
 			r := metrics[res.Type]
@@ -255,6 +285,10 @@ func (pod *Pod) replicas() *int32 {
 	if pod.Replicas < 1 {
 		pod.Replicas = 1
 	}
+	// 开启自动伸缩时以最小副本数启动，之后由 HPA 调整
+	if pod.Autoscaling != nil && pod.Replicas < pod.Autoscaling.minReplicas() {
+		pod.Replicas = pod.Autoscaling.minReplicas()
+	}
 	return &pod.Replicas
 }
 
@@ -281,22 +315,27 @@ func (pod *Pod) toContainer(c corev1.Container) {
 	container.setResource(c)
 	container.setMounts(pod.Storage, c)
 	container.setEnv(c)
+	container.setEnvFrom(c)
 	container.setPorts(c)
+	container.setProbes(c)
 	pod.Containers = append(pod.Containers, container)
 }
 
 type Container struct {
-	Name       string              `json:"name,omitempty"`
-	Image      string              `json:"image,omitempty"`
-	PullPolicy string              `json:"string,omitempty"`
-	Command    []string            `json:"command,omitempty"`
-	Args       []string            `json:"args,omitempty"`
-	Ports      []process.Port      `json:"ports,omitempty"`
-	Resource   []process.Resource  `json:"resource,omitempty"`
-	Env        map[string]string   `json:"env,omitempty"`
-	EnvConfig  []types.Environment `json:"envConfig,omitempty"`
-	Mounts     []types.Mount       `json:"mounts,omitempty"`
-	Probe      ProbeConfig         `json:"probe,omitempty"`
+	Name         string              `json:"name,omitempty"`
+	Image        string              `json:"image,omitempty"`
+	PullPolicy   string              `json:"string,omitempty"`
+	Command      []string            `json:"command,omitempty"`
+	Args         []string            `json:"args,omitempty"`
+	Ports        []process.Port      `json:"ports,omitempty"`
+	Resource     []process.Resource  `json:"resource,omitempty"`
+	Env          map[string]string   `json:"env,omitempty"`
+	EnvConfig    []types.Environment `json:"envConfig,omitempty"`
+	ConfigMapEnv []string            `json:"configMapEnv,omitempty"` // 整体注入为环境变量的 ConfigMap
+	SecretEnv    []string            `json:"secretEnv,omitempty"`    // 整体注入为环境变量的 Secret
+	Mounts       []types.Mount       `json:"mounts,omitempty"`
+	Probe        ProbeConfig         `json:"probe,omitempty"`
+	Probes       ProbesConfig        `json:"probes,omitempty"`
 }
 
 func (c *Container) toContainer() corev1.Container {
@@ -315,10 +354,13 @@ func (c *Container) toContainer() corev1.Container {
 	c.ports(container)
 	// 设置env
 	c.env(container)
+	c.envFrom(container)
 	// 设置资源
 	c.resource(container)
 	// 设置存储
 	c.mounts(container)
+	// 设置探针
+	c.probes(container)
 	return *container
 }
 
@@ -348,6 +390,30 @@ func (c *Container) setPorts(container corev1.Container) {
 	}
 }
 
+func (c *Container) probes(container *corev1.Container) {
+	liveness, readiness := c.Probes.Liveness, c.Probes.Readiness
+	if liveness == nil {
+		liveness = &c.Probe
+	}
+	if readiness == nil {
+		readiness = &c.Probe
+	}
+	container.LivenessProbe = liveness.toProbe(DefaultProbeInitialDelaySeconds, true)
+	container.ReadinessProbe = readiness.toProbe(DefaultProbeInitialDelaySeconds, false)
+	container.StartupProbe = c.Probes.Startup.toProbe(DefaultStartupProbeInitialDelaySeconds, true)
+}
+
+func (c *Container) setProbes(container corev1.Container) {
+	c.Probes = ProbesConfig{
+		Liveness:  toProbeConfig(container.LivenessProbe),
+		Readiness: toProbeConfig(container.ReadinessProbe),
+		Startup:   toProbeConfig(container.StartupProbe),
+	}
+	if c.Probes.Liveness != nil {
+		c.Probe = *c.Probes.Liveness
+	}
+}
+
 func (c *Container) resource(container *corev1.Container) {
 	var (
 		cpu = process.Resource{
@@ -475,12 +541,19 @@ func (pg *ProcessGroupConfig) toPod() *Pod {
 	}
 	pg.initConfig()
 	pod := &Pod{
-		Model:       Model{Namespace: pg.Namespace, Name: pg.GroupName, AllowUpdate: pg.AllowUpdate},
-		Replicas:    pg.Replicas,
-		RunningNode: pg.RunningNode,
-		Config:      pg.Config,
-		Storage:     pg.Storage,
-		Containers:  make([]*Container, 0),
+		Model:         Model{Namespace: pg.Namespace, Name: pg.GroupName, AllowUpdate: pg.AllowUpdate},
+		Kind:          ToWorkloadKind(pg.Kind.String()),
+		Replicas:      pg.Replicas,
+		Autoscaling:   pg.Autoscaling,
+		RunningNode:   pg.RunningNode,
+		Secrets:       gstr.SplitAndTrim(pg.Secret, ","),
+		Scheduling:    pg.Scheduling,
+		Config:        pg.Config,
+		ConfigMaps:    pg.ConfigMaps,
+		SecretConfigs: pg.Secrets,
+		ConfigHash:    pg.configHash(),
+		Storage:       pg.Storage,
+		Containers:    make([]*Container, 0),
 	}
 	pod.setTypesLabels(labels)
 	for _, p := range pg.Process {
@@ -496,18 +569,22 @@ func (pg *ProcessGroupConfig) toPod() *Pod {
 func (p *ProcessConfig) toContainer(pg *ProcessGroupConfig) *Container {
 	p.toMounts(pg)
 	env, envConfig := p.toEnvConfig()
+	configMapEnv, secretEnv := p.toEnvFrom(pg)
 	c := &Container{
-		Name:       p.Name,
-		Image:      p.Source,
-		PullPolicy: p.PullPolicy,
-		Command:    p.Command,
-		Args:       p.Args,
-		Ports:      p.Ports,
-		Resource:   p.Resource,
-		Env:        env,
-		EnvConfig:  envConfig,
-		Mounts:     p.Mounts,
-		Probe:      p.Probe,
+		Name:         p.Name,
+		Image:        p.Source,
+		PullPolicy:   p.PullPolicy,
+		Command:      p.Command,
+		Args:         p.Args,
+		Ports:        p.Ports,
+		Resource:     p.Resource,
+		Env:          env,
+		EnvConfig:    envConfig,
+		ConfigMapEnv: configMapEnv,
+		SecretEnv:    secretEnv,
+		Mounts:       p.Mounts,
+		Probe:        p.Probe,
+		Probes:       p.Probes,
 	}
 	return c
 }
@@ -542,6 +619,27 @@ func (o *podsOperation) List(ctx context.Context, namespace string, groups ...st
 	return pods, nil
 }
 
+// Deployments 按进程组读取 Deployment 定义，用于回读副本数、调度约束等配置
+func (o *podsOperation) Deployments(ctx context.Context, namespace string, groups ...string) ([]*Pod, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	pods := make([]*Pod, 0)
+	for _, g := range groups {
+		datas, err := o.api.AppsV1().Deployments(namespace).List(ctx, toGroupListOptions(g))
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Deployment列表: namespace=%s, group=%s", namespace, g))
+		}
+		for _, d := range datas.Items {
+			pods = append(pods, toDeploymentPod(d))
+		}
+	}
+	return pods, nil
+}
+
 func (o *podsOperation) Exists(ctx context.Context, namespace, pod string) (bool, error) {
 	if o.err != nil {
 		return false, o.err
@@ -554,6 +652,24 @@ func (o *podsOperation) Apply(ctx context.Context, pod *Pod) error {
 	if o.err != nil {
 		return o.err
 	}
+	// 同一进程组只能有一种工作负载，否则多个控制器会争抢相同标签的实例
+	if !o.isTest {
+		kind, err := o.workloadKind(ctx, pod.Namespace, pod.Name)
+		if err != nil {
+			return err
+		}
+		if len(kind) > 0 && kind != pod.workloadKind() {
+			return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+				fmt.Sprintf("进程组已存在其他类型的工作负载，请先停止后再切换: namespace=%s, group=%s, kind=%s, target=%s",
+					pod.Namespace, pod.Name, kind, pod.workloadKind()))
+		}
+	}
+	switch pod.workloadKind() {
+	case WorkloadStatefulSet:
+		return o.applyStatefulSet(ctx, pod)
+	case WorkloadDaemonSet:
+		return o.applyDaemonSet(ctx, pod)
+	}
 	if has, datas, err := o.deploymentExists(ctx, pod.Namespace, pod.Name); has {
 		if err != nil {
 			return err
@@ -596,6 +712,11 @@ func (o *podsOperation) DeleteGroup(ctx context.Context, namespace string, group
 			lastErr = err
 		}
 
+		// 删除 StatefulSet 和 DaemonSet
+		if err := o.deleteWorkloads(ctx, namespace, group); err != nil {
+			lastErr = err
+		}
+
 		// 删除 Pods
 		datas, err := o.list(ctx, namespace, group)
 		if err != nil {
@@ -624,7 +745,7 @@ func (o *podsOperation) RestartGroup(ctx context.Context, namespace, group strin
 	if len(group) < 1 {
 		return nil
 	}
-	has, _, err := o.deploymentExists(ctx, namespace, group)
+	has, err := o.workloadExists(ctx, namespace, group)
 	if err != nil || !has {
 		return err
 	}
@@ -640,99 +761,50 @@ func (o *podsOperation) RestartApp(ctx context.Context, namespace, appname strin
 	return err
 }
 
-func (o *podsOperation) Command(ctx context.Context, namespace, group, process string, cmd ...string) (string, error) {
+// Command 在进程组的每个实例中执行命令，返回各实例的输出和退出码
+func (o *podsOperation) Command(ctx context.Context, namespace, group, process string, cmd ...string) ([]*CommandResult, error) {
 	if len(process) < 1 {
-		return "", uerrors.NewValidationError("process", "请传入进程名称")
+		return nil, uerrors.NewValidationError("process", "请传入进程名称")
 	}
 
 	pods, err := o.list(ctx, namespace, group)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if pods == nil || len(pods.Items) == 0 {
-		return "", uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+		return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
 			fmt.Sprintf("没有查询到进程组: namespace=%s, group=%s", namespace, group))
 	}
 
 	var lastErr error
+	results := make([]*CommandResult, 0, len(pods.Items))
 	for _, pod := range pods.Items {
-		_, err := o.Exec(ctx, namespace, pod.Name, process, cmd...)
-		if err != nil {
-			lastErr = err
+		result := o.exec(ctx, namespace, pod.Name, process, cmd...)
+		if len(result.Error) > 0 {
+			lastErr = uerrors.NewKubernetesError(ctx, "执行命令", result.Error,
+				fmt.Sprintf("namespace=%s, pod=%s, process=%s, exitCode=%d", namespace, pod.Name, process, result.ExitCode))
 		}
+		results = append(results, result)
 	}
-	return "", lastErr
+	return results, lastErr
 }
 
+// Exec 在实例中执行非交互命令，返回标准输出，失败时错误信息包含标准错误
 func (o *podsOperation) Exec(ctx context.Context, namespace, pod, process string, cmd ...string) (string, error) {
-	if o.err != nil {
-		return "", o.err
-	}
-	if len(pod) < 1 {
-		return "", uerrors.NewValidationError("pod", "请传入进程组ID")
-	}
-	if len(process) < 1 {
-		return "", uerrors.NewValidationError("process", "请传入进程名称")
-	}
-	if cmd == nil || len(cmd) < 1 {
-		return "", uerrors.NewValidationError("cmd", "请传入要执行的命令")
-	}
-	req := o.api.CoreV1().RESTClient().
-		Post().
-		Resource("pods").
-		Namespace(namespace).
-		Name(pod).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: process,
-			Command:   cmd,
-			Stdin:     false,
-			Stdout:    true,
-			Stderr:    true,
-			TTY:       false,
-		}, runtime.NewParameterCodec(scheme.Scheme))
-	executor, err := remotecommand.NewSPDYExecutor(o.c, "POST", req.URL())
-	if err != nil {
-		return "", uerrors.WrapKubernetesError(ctx, err,
-			fmt.Sprintf("创建命令执行器: namespace=%s, pod=%s, process=%s", namespace, pod, process))
-	}
 	var stdout, stderr bytes.Buffer
-	err = executor.Stream(remotecommand.StreamOptions{
-		Stdout: &stdout,
-		Stderr: &stderr,
-		Tty:    false,
-	})
-	if err != nil {
-		return "", uerrors.WrapKubernetesError(ctx, err,
-			fmt.Sprintf("执行命令: namespace=%s, pod=%s, process=%s, cmd=%v", namespace, pod, process, cmd))
+	err := o.Stream(ctx, namespace, pod, process, ExecOptions{Stdout: &stdout, Stderr: &stderr}, cmd...)
+	if err == nil {
+		return stdout.String(), nil
 	}
-	return stdout.String(), err
-}
-
-func (o *podsOperation) Logger(ctx context.Context, namespace, group, process string, config ProcessLogger) (io.ReadCloser, error) {
-	if o.err != nil {
-		return nil, o.err
-	}
-	if len(group) < 1 {
-		return nil, uerrors.NewValidationError("group", "请传入进程组名称")
-	}
-	if len(process) < 1 {
-		return nil, uerrors.NewValidationError("process", "请传入进程名称")
+	if bizErr, ok := uerrors.IsBizError(err); ok && bizErr.Type == uerrors.ErrorTypeValidation {
+		return "", err
 	}
-	podLogOpts := &corev1.PodLogOptions{
-		Container:    process,
-		Follow:       config.Follow,
-		Previous:     config.Previous,
-		Timestamps:   config.Timestamps,
-		SinceSeconds: config.SinceSeconds,
-		TailLines:    util.Int64PtrOrDefault(config.TailLines, 100),
-		LimitBytes:   config.LimitBytes,
-		Stream:       GetOutputTypeOrDefault(config.Stream, LoggerOutputAll),
+	operation := fmt.Sprintf("执行命令: namespace=%s, pod=%s, process=%s, cmd=%v, exitCode=%d", namespace, pod, process, cmd, ExitCode(err))
+	if stderr.Len() > 0 {
+		operation = fmt.Sprintf("%s, stderr=%s", operation, stderr.String())
 	}
-	// 发起请求
-	req := o.api.CoreV1().Pods(namespace).GetLogs(group, podLogOpts)
-	return req.Stream(ctx)
+	return stdout.String(), uerrors.WrapKubernetesError(ctx, err, operation)
 }
 
 func (o *podsOperation) deploymentExists(ctx context.Context, namespace string, group string) (bool, []appsv1.Deployment, error) {
@@ -831,6 +903,7 @@ func (o *podsOperation) toPods(namespace string, datas *corev1.PodList) []*Pod {
 	for _, p := range datas.Items {
 		pod := &Pod{
 			Model:       Model{Namespace: namespace, Name: p.Name},
+			Kind:        toOwnerKind(p.OwnerReferences),
 			Status:      string(p.Status.Phase),
 			RunningNode: p.Spec.NodeName,
 			Containers:  make([]*Container, 0),
@@ -839,6 +912,7 @@ func (o *podsOperation) toPods(namespace string, datas *corev1.PodList) []*Pod {
 		}
 		pod.setLabels(p.Labels)
 		pod.setVolumes(p.Spec.Volumes)
+		pod.setScheduling(p.Spec)
 		for _, c := range p.Spec.Containers {
 			pod.toContainer(c)
 		}
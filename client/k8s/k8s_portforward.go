@@ -0,0 +1,157 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// ForwardPort 端口转发的本地端口与进程端口，本地端口为 0 时随机分配
+type ForwardPort struct {
+	Local  int32 `json:"local"`
+	Remote int32 `json:"remote"`
+}
+
+// PortForwarder 运行中的端口转发，ctx 取消或调用 Close 时停止
+type PortForwarder struct {
+	Namespace string        `json:"namespace,omitempty"`
+	Pod       string        `json:"pod,omitempty"`
+	Process   string        `json:"process,omitempty"`
+	Ports     []ForwardPort `json:"ports,omitempty"` // 实际监听的本地端口
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+	err       error
+}
+
+// Close 停止端口转发
+func (f *PortForwarder) Close() {
+	f.once.Do(func() {
+		close(f.stop)
+	})
+}
+
+// Done 端口转发结束时关闭
+func (f *PortForwarder) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待端口转发结束，返回结束的原因
+func (f *PortForwarder) Wait() error {
+	<-f.done
+	return f.err
+}
+
+// PortForward 将本地端口转发到进程组中一个就绪实例的进程端口，可同时转发多个端口
+// remotePort 为 0 时使用进程声明的第一个端口
+func (o *podsOperation) PortForward(ctx context.Context, namespace, group, process string, localPort, remotePort int32, ports ...ForwardPort) (*PortForwarder, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if len(process) < 1 {
+		return nil, uerrors.NewValidationError("process", "请传入进程名称")
+	}
+	pod, err := o.readyPod(ctx, namespace, group, process)
+	if err != nil {
+		return nil, err
+	}
+	if remotePort == 0 {
+		remotePort = containerPort(pod, process)
+	}
+	ports = append([]ForwardPort{{Local: localPort, Remote: remotePort}}, ports...)
+	addresses := make([]string, 0, len(ports))
+	for _, p := range ports {
+		if p.Remote <= 0 || p.Local < 0 {
+			return nil, uerrors.NewValidationError("port", fmt.Sprintf("端口不合法: local=%d, remote=%d", p.Local, p.Remote))
+		}
+		addresses = append(addresses, fmt.Sprintf("%d:%d", p.Local, p.Remote))
+	}
+	if o.c == nil {
+		return nil, uerrors.NewKubernetesError(ctx, "端口转发", "未初始化REST配置", fmt.Sprintf("namespace=%s, pod=%s", namespace, pod.Name))
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(o.c)
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, "创建端口转发通道")
+	}
+	url := o.api.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod.Name).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+	forwarder := &PortForwarder{
+		Namespace: namespace,
+		Pod:       pod.Name,
+		Process:   process,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	ready := make(chan struct{})
+	fw, err := portforward.New(dialer, addresses, forwarder.stop, ready, io.Discard, io.Discard)
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建端口转发: namespace=%s, pod=%s, ports=%v", namespace, pod.Name, addresses))
+	}
+	go func() {
+		defer close(forwarder.done)
+		forwarder.err = fw.ForwardPorts()
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			forwarder.Close()
+		case <-forwarder.done:
+		}
+	}()
+	select {
+	case <-ready:
+	case <-forwarder.done:
+		return nil, uerrors.WrapKubernetesError(ctx, forwarder.err, fmt.Sprintf("端口转发: namespace=%s, pod=%s, ports=%v", namespace, pod.Name, addresses))
+	}
+	actual, err := fw.GetPorts()
+	if err != nil {
+		forwarder.Close()
+		return nil, uerrors.WrapKubernetesError(ctx, err, "获取端口转发的本地端口")
+	}
+	for _, p := range actual {
+		forwarder.Ports = append(forwarder.Ports, ForwardPort{Local: int32(p.Local), Remote: int32(p.Remote)})
+	}
+	return forwarder, nil
+}
+
+// readyPod 选择进程组中进程已就绪的一个实例
+func (o *podsOperation) readyPod(ctx context.Context, namespace, group, process string) (*corev1.Pod, error) {
+	pods, err := o.list(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, s := range pod.Status.ContainerStatuses {
+			if s.Name == process && s.Ready {
+				return pod, nil
+			}
+		}
+	}
+	return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+		fmt.Sprintf("没有就绪的实例: namespace=%s, group=%s, process=%s", namespace, group, process))
+}
+
+func containerPort(pod *corev1.Pod, process string) int32 {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == process && len(c.Ports) > 0 {
+			return c.Ports[0].ContainerPort
+		}
+	}
+	return 0
+}
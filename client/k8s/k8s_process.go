@@ -30,8 +30,10 @@ func (o *processOperation) List(ctx context.Context, namespace string) ([]*proce
 		svcs    = make(map[string][]*Service)
 		now     = gtime.Now().Timestamp()
 	)
+	// 缓存已同步时从内存读取，避免频繁全量请求 API Server
+	cached := o.k8s.Cache().Synced(namespace)
 	// 获取SVC
-	services, err := o.k8s.Service().List(ctx, namespace)
+	services, err := o.services(ctx, namespace, cached)
 	if err != nil {
 		logger.Warningf(ctx, "---> SVC信息采集失败 err: %+v \r\n", err.Error())
 	} else {
@@ -48,7 +50,7 @@ func (o *processOperation) List(ctx context.Context, namespace string) ([]*proce
 		}
 	}
 	// 获取POD
-	pods, err := o.k8s.Pod().List(ctx, namespace)
+	pods, err := o.pods(ctx, namespace, cached)
 	if err != nil {
 		return list, err
 	}
@@ -70,13 +72,50 @@ func (o *processOperation) List(ctx context.Context, namespace string) ([]*proce
 			metrics[m.Name] = m
 		}
 	}
+	// 获取自动伸缩的副本数
+	replicas := make(map[string]*Replicas)
+	autoscalers, err := o.autoscalers(ctx, namespace, cached)
+	if err != nil {
+		logger.Warningf(ctx, "---> HPA信息采集失败 err: %+v \r\n", err.Error())
+	} else {
+		for _, a := range autoscalers {
+			replicas[a.Group] = a.Replicas()
+		}
+	}
 	for _, pod := range pods {
 		ps := pod.ToProcess(svcs[pod.Group], metrics[pod.Name], now)
+		for _, p := range ps {
+			p.Region = o.region
+			if r, ok := replicas[pod.Group]; ok {
+				p.Details["replicas"] = r
+			}
+		}
 		list = append(list, ps...)
 	}
 	return list, nil
 }
 
+func (o *processOperation) autoscalers(ctx context.Context, namespace string, cached bool) ([]*Autoscaler, error) {
+	if cached {
+		return o.k8s.Cache().Autoscalers(ctx, namespace)
+	}
+	return o.k8s.Autoscaler().List(ctx, namespace)
+}
+
+func (o *processOperation) services(ctx context.Context, namespace string, cached bool) ([]*Service, error) {
+	if cached {
+		return o.k8s.Cache().Services(ctx, namespace)
+	}
+	return o.k8s.Service().List(ctx, namespace)
+}
+
+func (o *processOperation) pods(ctx context.Context, namespace string, cached bool) ([]*Pod, error) {
+	if cached {
+		return o.k8s.Cache().Pods(ctx, namespace)
+	}
+	return o.k8s.Pod().List(ctx, namespace)
+}
+
 func (o *processOperation) Running(ctx context.Context, config *ProcessGroupConfig) error {
 	if o.err != nil {
 		return o.err
@@ -91,20 +130,54 @@ func (o *processOperation) Running(ctx context.Context, config *ProcessGroupConf
 			return err
 		}
 	}
+	if err := o.k8s.Ingress().Sync(ctx, config.Namespace, config.GroupName, config.toIngresses()); err != nil {
+		return err
+	}
 	pod := config.toPod()
 	if pod == nil {
 		return nil
 	}
-	if err := o.k8s.StorageResource().BatchApply(ctx, config.toModel(), config.Storage); err != nil {
+	storage := config.sharedStorage()
+	if err := o.k8s.StorageResource().BatchApply(ctx, config.toModel(), storage); err != nil {
 		return err
 	}
-	if err := o.k8s.Storage().BatchApply(ctx, config.toModel(), config.Storage); err != nil {
+	if err := o.k8s.Storage().BatchApply(ctx, config.toModel(), storage); err != nil {
+		return err
+	}
+	if err := o.k8s.Config().BatchApply(ctx, config.toModel(), config.ConfigMaps, config.Secrets); err != nil {
+		return err
+	}
+	if err := o.applyNetworkPolicy(ctx, config, true); err != nil {
 		return err
 	}
 	if err := o.k8s.Pod().Apply(ctx, pod); err != nil {
 		return err
 	}
-	return nil
+	return o.applyAutoscaler(ctx, config, true)
+}
+
+// applyNetworkPolicy 按配置创建或更新网络策略，prune 为 true 时未配置网络访问控制则删除已有的策略
+func (o *processOperation) applyNetworkPolicy(ctx context.Context, config *ProcessGroupConfig, prune bool) error {
+	policy := config.toNetworkPolicy()
+	if policy != nil {
+		return o.k8s.NetworkPolicy().Apply(ctx, policy)
+	}
+	if !prune {
+		return nil
+	}
+	return o.k8s.NetworkPolicy().DeleteGroup(ctx, config.Namespace, config.GroupName)
+}
+
+// applyAutoscaler 按配置创建或更新 HPA，prune 为 true 时未配置自动伸缩则删除已有的 HPA
+func (o *processOperation) applyAutoscaler(ctx context.Context, config *ProcessGroupConfig, prune bool) error {
+	autoscaler := config.toAutoscaler()
+	if autoscaler != nil {
+		return o.k8s.Autoscaler().Apply(ctx, autoscaler)
+	}
+	if !prune {
+		return nil
+	}
+	return o.k8s.Autoscaler().DeleteGroup(ctx, config.Namespace, config.GroupName)
 }
 
 func (o *processOperation) Start(ctx context.Context, config *ProcessGroupConfig) error {
@@ -143,7 +216,7 @@ func (o *processOperation) Start(ctx context.Context, config *ProcessGroupConfig
 	// 额外校验：确保即将引用的 PVC 均已存在，避免命名不一致导致的调度失败
 	if pod.Storage != nil && len(pod.Storage) > 0 {
 		for _, s := range pod.Storage {
-			if s.ToStorageType().String() != "pvc" {
+			if s.ToStorageType().String() != "pvc" || pod.isClaimTemplate(s) {
 				continue
 			}
 			if len(s.Name) < 1 {
@@ -160,11 +233,25 @@ func (o *processOperation) Start(ctx context.Context, config *ProcessGroupConfig
 		}
 	}
 
+	if err := o.k8s.Config().BatchApply(ctx, config.toModel(), config.ConfigMaps, config.Secrets); err != nil {
+		logger.Warningf(ctx, "[Start] apply config failed: %v", err)
+		return err
+	}
+
+	if err := o.applyNetworkPolicy(ctx, config, false); err != nil {
+		logger.Warningf(ctx, "[Start] apply network policy failed: %v", err)
+		return err
+	}
+
 	logger.Info(ctx, "[Start] applying Pod, ns=", pod.Namespace, ", group=", pod.Group)
 	if err := o.k8s.Pod().Apply(ctx, pod); err != nil {
 		logger.Warningf(ctx, "[Start] apply Pod failed: %v", err)
 		return err
 	}
+	if err := o.applyAutoscaler(ctx, config, false); err != nil {
+		logger.Warningf(ctx, "[Start] apply autoscaler failed: %v", err)
+		return err
+	}
 	logger.Info(ctx, "[Start] done, ns=", pod.Namespace, ", group=", pod.Group)
 	return nil
 }
@@ -198,33 +285,37 @@ func (o *processOperation) ensureServices(ctx context.Context, config *ProcessGr
 		}
 	}
 
+	if err := o.k8s.Ingress().Sync(ctx, config.Namespace, config.GroupName, config.toIngresses()); err != nil {
+		return err
+	}
 	logger.Debugf(ctx, "[ensureServices] done, namespace=%s", config.Namespace)
 	return nil
 }
 
 // ensureStorage 确保 StorageResource(PV) 和 Storage(PVC) 存在，缺失则批量创建
 func (o *processOperation) ensureStorage(ctx context.Context, config *ProcessGroupConfig) error {
-	if len(config.Storage) == 0 {
+	storage := config.sharedStorage()
+	if len(storage) == 0 {
 		return nil
 	}
-	logger.Debugf(ctx, "[ensureStorage] start, namespace=%s, items=%d", config.Namespace, len(config.Storage))
+	logger.Debugf(ctx, "[ensureStorage] start, namespace=%s, items=%d", config.Namespace, len(storage))
 
 	// 先处理 StorageResource (PV)
-	if err := o.ensureStorageResources(ctx, config); err != nil {
+	if err := o.ensureStorageResources(ctx, config, storage); err != nil {
 		return err
 	}
 	// 再处理 Storage (PVC)
-	if err := o.ensureStorages(ctx, config); err != nil {
+	if err := o.ensureStorages(ctx, config, storage); err != nil {
 		return err
 	}
 	// 最后等待所有 Storage (PVC) 就绪
-	return o.waitStoragesBound(ctx, config.Namespace, config.Storage, 60*time.Second)
+	return o.waitStoragesBound(ctx, config.Namespace, storage, 60*time.Second)
 }
 
 // ensureStorageResources 确保 StorageResource (PV) 存在，缺失则批量创建
-func (o *processOperation) ensureStorageResources(ctx context.Context, config *ProcessGroupConfig) error {
+func (o *processOperation) ensureStorageResources(ctx context.Context, config *ProcessGroupConfig, storage []types.Storage) error {
 	needStorageResourceApply := false
-	for _, s := range config.Storage {
+	for _, s := range storage {
 		if len(s.Name) < 1 {
 			continue
 		}
@@ -257,8 +348,8 @@ func (o *processOperation) ensureStorageResources(ctx context.Context, config *P
 	}
 
 	if needStorageResourceApply {
-		logger.Debugf(ctx, "[ensureStorageResources] applying StorageResource batch, items=%d", len(config.Storage))
-		if err := o.k8s.StorageResource().BatchApply(ctx, config.toModel(), config.Storage); err != nil {
+		logger.Debugf(ctx, "[ensureStorageResources] applying StorageResource batch, items=%d", len(storage))
+		if err := o.k8s.StorageResource().BatchApply(ctx, config.toModel(), storage); err != nil {
 			return err
 		}
 	}
@@ -266,9 +357,9 @@ func (o *processOperation) ensureStorageResources(ctx context.Context, config *P
 }
 
 // ensureStorages 确保 Storage (PVC) 存在，缺失则批量创建
-func (o *processOperation) ensureStorages(ctx context.Context, config *ProcessGroupConfig) error {
+func (o *processOperation) ensureStorages(ctx context.Context, config *ProcessGroupConfig, storage []types.Storage) error {
 	needStorageApply := false
-	for _, s := range config.Storage {
+	for _, s := range storage {
 		if len(s.Name) < 1 {
 			continue
 		}
@@ -301,8 +392,8 @@ func (o *processOperation) ensureStorages(ctx context.Context, config *ProcessGr
 	}
 
 	if needStorageApply {
-		logger.Debugf(ctx, "[ensureStorages] applying Storage batch, ns=%s items=%d", config.Namespace, len(config.Storage))
-		if err := o.k8s.Storage().BatchApply(ctx, config.toModel(), config.Storage); err != nil {
+		logger.Debugf(ctx, "[ensureStorages] applying Storage batch, ns=%s items=%d", config.Namespace, len(storage))
+		if err := o.k8s.Storage().BatchApply(ctx, config.toModel(), storage); err != nil {
 			return err
 		}
 	}
@@ -373,12 +464,21 @@ func (o *processOperation) Destroy(ctx context.Context, namespace string, groups
 	if err := o.k8s.Service().DeleteGroup(ctx, namespace, groups...); err != nil {
 		return err
 	}
+	if err := o.k8s.Autoscaler().DeleteGroup(ctx, namespace, groups...); err != nil {
+		return err
+	}
+	if err := o.k8s.NetworkPolicy().DeleteGroup(ctx, namespace, groups...); err != nil {
+		return err
+	}
 	if err := o.k8s.Pod().DeleteGroup(ctx, namespace, groups...); err != nil {
 		return err
 	}
 	if err := o.k8s.Storage().DeleteByGroup(ctx, true, namespace, groups...); err != nil {
 		return err
 	}
+	if err := o.k8s.Config().DeleteGroup(ctx, namespace, groups...); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -397,10 +497,58 @@ func (o *processOperation) RestartApp(ctx context.Context, namespace, group stri
 	return o.k8s.Pod().RestartApp(ctx, namespace, group)
 }
 
-func (o *processOperation) Command(ctx context.Context, namespace, group, process string, cmd ...string) (string, error) {
+func (o *processOperation) Command(ctx context.Context, namespace, group, process string, cmd ...string) ([]*CommandResult, error) {
 	return o.k8s.Pod().Command(ctx, namespace, group, process, cmd...)
 }
 
+// Stream 在实例中执行交互命令，输入输出由调用方提供，可用于浏览器终端
+func (o *processOperation) Stream(ctx context.Context, namespace, pod, process string, opts ExecOptions, cmd ...string) error {
+	return o.k8s.Pod().Stream(ctx, namespace, pod, process, opts, cmd...)
+}
+
+// PortForward 将本地端口转发到进程组的一个就绪实例
+func (o *processOperation) PortForward(ctx context.Context, namespace, group, process string, localPort, remotePort int32, ports ...ForwardPort) (*PortForwarder, error) {
+	return o.k8s.Pod().PortForward(ctx, namespace, group, process, localPort, remotePort, ports...)
+}
+
+// CopyFrom 将实例中的文件或目录复制到本地
+func (o *processOperation) CopyFrom(ctx context.Context, namespace, pod, process, remotePath, localPath string, opts CopyOptions) (*CopyProgress, error) {
+	return o.k8s.Pod().CopyFrom(ctx, namespace, pod, process, remotePath, localPath, opts)
+}
+
+// CopyTo 将本地的文件或目录复制到实例中
+func (o *processOperation) CopyTo(ctx context.Context, namespace, pod, process, localPath, remotePath string, opts CopyOptions) (*CopyProgress, error) {
+	return o.k8s.Pod().CopyTo(ctx, namespace, pod, process, localPath, remotePath, opts)
+}
+
 func (o *processOperation) Logger(ctx context.Context, namespace, group, process string, config ProcessLogger) (io.ReadCloser, error) {
 	return o.k8s.Pod().Logger(ctx, namespace, group, process, config)
 }
+
+// WaitRollout 等待进程组发布完成，Start 和 Running 只负责提交配置
+func (o *processOperation) WaitRollout(ctx context.Context, namespace, group string, opts RolloutOptions) (*RolloutStatus, error) {
+	return o.k8s.Rollout().Wait(ctx, namespace, group, opts)
+}
+
+func (o *processOperation) History(ctx context.Context, namespace, group string) ([]*Revision, error) {
+	return o.k8s.Rollout().History(ctx, namespace, group)
+}
+
+func (o *processOperation) Rollback(ctx context.Context, namespace, group string, revision int64) error {
+	return o.k8s.Rollout().Rollback(ctx, namespace, group, revision)
+}
+
+// Snapshot 为进程组的全部存储创建快照集，通常在升级前调用
+func (o *processOperation) Snapshot(ctx context.Context, namespace, group, set string) ([]*Snapshot, error) {
+	return o.k8s.Snapshot().CreateGroup(ctx, namespace, group, set, "")
+}
+
+// RestoreSnapshot 将进程组的存储恢复到指定的快照集
+func (o *processOperation) RestoreSnapshot(ctx context.Context, namespace, group, set string) error {
+	return o.k8s.Snapshot().Restore(ctx, namespace, group, set, DefaultRolloutTimeout)
+}
+
+// Scale 手动调整进程组的副本数
+func (o *processOperation) Scale(ctx context.Context, namespace, group string, replicas int32) error {
+	return o.k8s.Autoscaler().Scale(ctx, namespace, group, replicas)
+}
@@ -0,0 +1,330 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hosgf/element/model/resource"
+	"github.com/hosgf/element/types"
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	k8sresource "k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 平台管理的配额和默认限制的名称，每个命名空间各一个
+const (
+	QuotaName      = "x-platform-quota"
+	LimitRangeName = "x-platform-limits"
+)
+
+// QuotaConfig 命名空间的资源配额
+type QuotaConfig struct {
+	CPU     string           `json:"cpu,omitempty"`     // CPU 总量，同时限制 requests.cpu 和 limits.cpu
+	Memory  string           `json:"memory,omitempty"`  // 内存总量，同时限制 requests.memory 和 limits.memory
+	Storage string           `json:"storage,omitempty"` // 存储申请总量
+	Objects map[string]int64 `json:"objects,omitempty"` // 对象数量，如 pods services persistentvolumeclaims configmaps secrets
+}
+
+// LimitRangeConfig 命名空间内进程的默认资源和上下限，进程未声明资源时使用默认值
+type LimitRangeConfig struct {
+	DefaultCPU           string `json:"defaultCpu,omitempty"`           // 默认 limits.cpu
+	DefaultMemory        string `json:"defaultMemory,omitempty"`        // 默认 limits.memory
+	DefaultRequestCPU    string `json:"defaultRequestCpu,omitempty"`    // 默认 requests.cpu
+	DefaultRequestMemory string `json:"defaultRequestMemory,omitempty"` // 默认 requests.memory
+	MaxCPU               string `json:"maxCpu,omitempty"`
+	MaxMemory            string `json:"maxMemory,omitempty"`
+	MinCPU               string `json:"minCpu,omitempty"`
+	MinMemory            string `json:"minMemory,omitempty"`
+}
+
+// NamespaceQuota 命名空间的配额、默认限制和当前使用量
+type NamespaceQuota struct {
+	Namespace string            `json:"namespace"`
+	Quota     *QuotaConfig      `json:"quota,omitempty"`
+	Limits    *LimitRangeConfig `json:"limits,omitempty"`
+	Usage     *resource.Quota   `json:"usage,omitempty"`
+}
+
+func (c *QuotaConfig) toResourceList() (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	items := map[corev1.ResourceName]string{
+		corev1.ResourceRequestsCPU:     c.CPU,
+		corev1.ResourceLimitsCPU:       c.CPU,
+		corev1.ResourceRequestsMemory:  c.Memory,
+		corev1.ResourceLimitsMemory:    c.Memory,
+		corev1.ResourceRequestsStorage: c.Storage,
+	}
+	for name, value := range items {
+		if err := setQuantity(list, name, value); err != nil {
+			return nil, err
+		}
+	}
+	for name, count := range c.Objects {
+		if len(name) < 1 || count < 0 {
+			return nil, uerrors.NewValidationError("objects", fmt.Sprintf("对象数量不合法: %s=%d", name, count))
+		}
+		list[corev1.ResourceName(name)] = *k8sresource.NewQuantity(count, k8sresource.DecimalSI)
+	}
+	if len(list) < 1 {
+		return nil, uerrors.NewValidationError("quota", "请至少设置一项配额")
+	}
+	return list, nil
+}
+
+func (c *LimitRangeConfig) toLimitRangeItem() (corev1.LimitRangeItem, error) {
+	item := corev1.LimitRangeItem{
+		Type:           corev1.LimitTypeContainer,
+		Default:        corev1.ResourceList{},
+		DefaultRequest: corev1.ResourceList{},
+		Max:            corev1.ResourceList{},
+		Min:            corev1.ResourceList{},
+	}
+	items := []struct {
+		list  corev1.ResourceList
+		name  corev1.ResourceName
+		value string
+	}{
+		{item.Default, corev1.ResourceCPU, c.DefaultCPU},
+		{item.Default, corev1.ResourceMemory, c.DefaultMemory},
+		{item.DefaultRequest, corev1.ResourceCPU, c.DefaultRequestCPU},
+		{item.DefaultRequest, corev1.ResourceMemory, c.DefaultRequestMemory},
+		{item.Max, corev1.ResourceCPU, c.MaxCPU},
+		{item.Max, corev1.ResourceMemory, c.MaxMemory},
+		{item.Min, corev1.ResourceCPU, c.MinCPU},
+		{item.Min, corev1.ResourceMemory, c.MinMemory},
+	}
+	count := 0
+	for _, i := range items {
+		if err := setQuantity(i.list, i.name, i.value); err != nil {
+			return item, err
+		}
+		if len(i.value) > 0 {
+			count++
+		}
+	}
+	if count < 1 {
+		return item, uerrors.NewValidationError("limits", "请至少设置一项默认限制")
+	}
+	return item, nil
+}
+
+func setQuantity(list corev1.ResourceList, name corev1.ResourceName, value string) error {
+	if len(value) < 1 {
+		return nil
+	}
+	quantity, err := k8sresource.ParseQuantity(value)
+	if err != nil || quantity.Sign() < 0 {
+		return uerrors.NewValidationError(string(name), fmt.Sprintf("资源数量不合法: %s=%s", name, value))
+	}
+	list[name] = quantity
+	return nil
+}
+
+// ApplyQuota 创建或更新命名空间的资源配额
+func (o *namespaceOperation) ApplyQuota(ctx context.Context, namespace string, config QuotaConfig) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(namespace) < 1 {
+		return uerrors.NewValidationError("namespace", "请传入命名空间")
+	}
+	hard, err := config.toResourceList()
+	if err != nil {
+		return err
+	}
+	api := o.api.CoreV1().ResourceQuotas(namespace)
+	data, err := api.Get(ctx, QuotaName, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查资源配额是否存在: namespace=%s", namespace))
+	if err != nil {
+		return err
+	}
+	if !has {
+		quota := &corev1.ResourceQuota{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      QuotaName,
+				Namespace: namespace,
+			},
+			Spec: corev1.ResourceQuotaSpec{Hard: hard},
+		}
+		if _, err := api.Create(ctx, quota, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建资源配额: namespace=%s", namespace))
+		}
+		return nil
+	}
+	data.Spec.Hard = hard
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新资源配额: namespace=%s", namespace))
+	}
+	return nil
+}
+
+// ApplyLimitRange 创建或更新命名空间内进程的默认资源和上下限
+func (o *namespaceOperation) ApplyLimitRange(ctx context.Context, namespace string, config LimitRangeConfig) error {
+	if o.err != nil {
+		return o.err
+	}
+	if len(namespace) < 1 {
+		return uerrors.NewValidationError("namespace", "请传入命名空间")
+	}
+	item, err := config.toLimitRangeItem()
+	if err != nil {
+		return err
+	}
+	api := o.api.CoreV1().LimitRanges(namespace)
+	data, err := api.Get(ctx, LimitRangeName, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查默认限制是否存在: namespace=%s", namespace))
+	if err != nil {
+		return err
+	}
+	if !has {
+		limits := &corev1.LimitRange{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      LimitRangeName,
+				Namespace: namespace,
+			},
+			Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{item}},
+		}
+		if _, err := api.Create(ctx, limits, v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建默认限制: namespace=%s", namespace))
+		}
+		return nil
+	}
+	data.Spec.Limits = []corev1.LimitRangeItem{item}
+	if _, err := api.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新默认限制: namespace=%s", namespace))
+	}
+	return nil
+}
+
+// DeleteQuota 删除命名空间的资源配额和默认限制
+func (o *namespaceOperation) DeleteQuota(ctx context.Context, namespace string) error {
+	if o.err != nil {
+		return o.err
+	}
+	err := o.api.CoreV1().ResourceQuotas(namespace).Delete(ctx, QuotaName, v1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除资源配额: namespace=%s", namespace))
+	}
+	err = o.api.CoreV1().LimitRanges(namespace).Delete(ctx, LimitRangeName, v1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除默认限制: namespace=%s", namespace))
+	}
+	return nil
+}
+
+// Quota 获取命名空间的资源配额、默认限制和当前使用量，未设置时对应字段为空
+func (o *namespaceOperation) Quota(ctx context.Context, namespace string) (*NamespaceQuota, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	result := &NamespaceQuota{Namespace: namespace}
+	quota, err := o.api.CoreV1().ResourceQuotas(namespace).Get(ctx, QuotaName, v1.GetOptions{})
+	switch {
+	case err == nil:
+		result.Quota = toQuotaConfig(quota)
+		result.Usage = toQuota(quota)
+	case !errors.IsNotFound(err):
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取资源配额: namespace=%s", namespace))
+	}
+	limits, err := o.api.CoreV1().LimitRanges(namespace).Get(ctx, LimitRangeName, v1.GetOptions{})
+	switch {
+	case err == nil:
+		result.Limits = toLimitRangeConfig(limits)
+	case !errors.IsNotFound(err):
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取默认限制: namespace=%s", namespace))
+	}
+	return result, nil
+}
+
+// Quotas 获取所有命名空间的资源配额使用量，namespace 为空时查询全部
+func (o *namespaceOperation) Quotas(ctx context.Context, namespace string) ([]resource.Quota, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	list, err := o.api.CoreV1().ResourceQuotas(namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取资源配额列表: namespace=%s", namespace))
+	}
+	quotas := make([]resource.Quota, 0, len(list.Items))
+	for i := range list.Items {
+		quotas = append(quotas, *toQuota(&list.Items[i]))
+	}
+	return quotas, nil
+}
+
+func toQuotaConfig(quota *corev1.ResourceQuota) *QuotaConfig {
+	config := &QuotaConfig{Objects: map[string]int64{}}
+	for name, quantity := range quota.Spec.Hard {
+		switch name {
+		case corev1.ResourceRequestsCPU, corev1.ResourceLimitsCPU, corev1.ResourceCPU:
+			config.CPU = quantity.String()
+		case corev1.ResourceRequestsMemory, corev1.ResourceLimitsMemory, corev1.ResourceMemory:
+			config.Memory = quantity.String()
+		case corev1.ResourceRequestsStorage:
+			config.Storage = quantity.String()
+		default:
+			config.Objects[string(name)] = quantity.Value()
+		}
+	}
+	return config
+}
+
+func toLimitRangeConfig(limits *corev1.LimitRange) *LimitRangeConfig {
+	config := &LimitRangeConfig{}
+	for _, item := range limits.Spec.Limits {
+		if item.Type != corev1.LimitTypeContainer {
+			continue
+		}
+		config.DefaultCPU = quantityString(item.Default, corev1.ResourceCPU)
+		config.DefaultMemory = quantityString(item.Default, corev1.ResourceMemory)
+		config.DefaultRequestCPU = quantityString(item.DefaultRequest, corev1.ResourceCPU)
+		config.DefaultRequestMemory = quantityString(item.DefaultRequest, corev1.ResourceMemory)
+		config.MaxCPU = quantityString(item.Max, corev1.ResourceCPU)
+		config.MaxMemory = quantityString(item.Max, corev1.ResourceMemory)
+		config.MinCPU = quantityString(item.Min, corev1.ResourceCPU)
+		config.MinMemory = quantityString(item.Min, corev1.ResourceMemory)
+	}
+	return config
+}
+
+func quantityString(list corev1.ResourceList, name corev1.ResourceName) string {
+	if quantity, ok := list[name]; ok {
+		return quantity.String()
+	}
+	return ""
+}
+
+// toQuota 转换配额使用量，CPU 以 m 为单位，内存和存储以 Mi 为单位，对象数量无单位
+func toQuota(quota *corev1.ResourceQuota) *resource.Quota {
+	result := &resource.Quota{
+		Namespace: quota.Namespace,
+		Name:      quota.Name,
+		Items:     make(map[string]resource.Details, len(quota.Status.Hard)),
+	}
+	hard := quota.Status.Hard
+	if len(hard) < 1 {
+		hard = quota.Spec.Hard
+	}
+	for name, total := range hard {
+		used := quota.Status.Used[name]
+		details := resource.Details{}
+		switch name {
+		case corev1.ResourceRequestsCPU, corev1.ResourceLimitsCPU, corev1.ResourceCPU:
+			details.SetUnit(types.DefaultCpuUnit)
+			details.SetTotalValue(total.MilliValue())
+			details.ThroughUsageConstruction(used.MilliValue())
+		case corev1.ResourceRequestsMemory, corev1.ResourceLimitsMemory, corev1.ResourceMemory,
+			corev1.ResourceRequestsStorage, corev1.ResourceRequestsEphemeralStorage, corev1.ResourceLimitsEphemeralStorage:
+			details.SetUnit(types.DefaultMemoryUnit)
+			details.SetTotalValue(types.FormatMemoryOfBytes(total.Value(), "Mi"))
+			details.ThroughUsageConstruction(types.FormatMemoryOfBytes(used.Value(), "Mi"))
+		default:
+			details.SetTotalValue(total.Value())
+			details.ThroughUsageConstruction(used.Value())
+		}
+		result.Items[string(name)] = details
+	}
+	return result
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/gogf/gf/v2/os/gtime"
+	"github.com/hosgf/element/logger"
 	"github.com/hosgf/element/model/resource"
 )
 
@@ -21,13 +22,20 @@ func (o *resourceOperation) Get(ctx context.Context) (*resource.Resource, error)
 		return nil, err
 	}
 	res := &resource.Resource{
-		Env:   "k8s",
-		Time:  gtime.Now().Timestamp(),
-		Nodes: make([]resource.Node, 0),
+		Env:    "k8s",
+		Region: o.region,
+		Time:   gtime.Now().Timestamp(),
+		Nodes:  make([]resource.Node, 0),
 	}
 	for _, node := range nodes {
 		res.Nodes = append(res.Nodes, node.ToNode())
 	}
+	// 配额只作为补充信息，采集失败不影响节点资源的汇总
+	if quotas, err := o.k8s.Namespace().Quotas(ctx, ""); err != nil {
+		logger.Warningf(ctx, "---> 配额信息采集失败 err: %+v \r\n", err.Error())
+	} else {
+		res.Quotas = quotas
+	}
 	res.SetStatus()
 	return res, nil
 }
@@ -0,0 +1,648 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/uerrors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// AnnotationRevision Deployment 控制器写入 ReplicaSet 的版本号
+	AnnotationRevision = "deployment.kubernetes.io/revision"
+	// AnnotationChangeCause 版本变更原因
+	AnnotationChangeCause = "kubernetes.io/change-cause"
+
+	DefaultRolloutTimeout  = 5 * time.Minute
+	DefaultRolloutInterval = 2 * time.Second
+)
+
+// 发布阶段
+const (
+	RolloutProgressing = "progressing"
+	RolloutComplete    = "complete"
+	RolloutFailed      = "failed"
+)
+
+type rolloutOperation struct {
+	*options
+}
+
+// RolloutOptions 等待发布完成的参数
+type RolloutOptions struct {
+	Timeout  time.Duration               // 超时时间，默认 5 分钟
+	Interval time.Duration               // 轮询间隔，默认 2 秒
+	FailFast bool                        // 出现镜像拉取失败、崩溃重启、无法调度等错误时立即返回
+	Progress func(status *RolloutStatus) // 每次轮询后的进度回调
+}
+
+// RolloutStatus 进程组发布状态
+type RolloutStatus struct {
+	Namespace string           `json:"namespace,omitempty"`
+	Group     string           `json:"group,omitempty"`
+	Kind      WorkloadKind     `json:"kind,omitempty"`
+	Revision  int64            `json:"revision,omitempty"`  // 当前版本
+	Phase     string           `json:"phase,omitempty"`     // progressing complete failed
+	Desired   int32            `json:"desired,omitempty"`   // 期望副本数
+	Updated   int32            `json:"updated,omitempty"`   // 已更新到新版本的副本数
+	Ready     int32            `json:"ready,omitempty"`     // 就绪副本数
+	Available int32            `json:"available,omitempty"` // 可用副本数
+	Message   string           `json:"message,omitempty"`
+	Reasons   []*RolloutReason `json:"reasons,omitempty"` // 未就绪的原因
+}
+
+// RolloutReason 实例未就绪的原因，来自 Pod 状态和事件
+type RolloutReason struct {
+	Pod     string `json:"pod,omitempty"`
+	Process string `json:"process,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	Fatal   bool   `json:"fatal,omitempty"` // 需要人工介入才能恢复的错误
+}
+
+// Revision 进程组历史版本
+type Revision struct {
+	Revision    int64             `json:"revision"`
+	Name        string            `json:"name,omitempty"`        // ReplicaSet 或 ControllerRevision 名称
+	Images      map[string]string `json:"images,omitempty"`      // 进程名 -> 镜像
+	ChangeCause string            `json:"changeCause,omitempty"` // 变更原因
+	Current     bool              `json:"current,omitempty"`     // 是否为当前版本
+	Created     int64             `json:"created,omitempty"`
+}
+
+func (s *RolloutStatus) IsComplete() bool {
+	return s.Phase == RolloutComplete
+}
+
+func (s *RolloutStatus) IsFailed() bool {
+	return s.Phase == RolloutFailed
+}
+
+func (s *RolloutStatus) hasFatal() bool {
+	for _, r := range s.Reasons {
+		if r.Fatal {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *RolloutStatus) String() string {
+	msg := fmt.Sprintf("namespace=%s, group=%s, phase=%s, updated=%d/%d, ready=%d, available=%d",
+		s.Namespace, s.Group, s.Phase, s.Updated, s.Desired, s.Ready, s.Available)
+	if len(s.Message) > 0 {
+		msg = fmt.Sprintf("%s, message=%s", msg, s.Message)
+	}
+	for _, r := range s.Reasons {
+		msg = fmt.Sprintf("%s; %s/%s %s: %s", msg, r.Pod, r.Process, r.Reason, r.Message)
+	}
+	return msg
+}
+
+// Status 获取进程组当前的发布状态
+func (o *rolloutOperation) Status(ctx context.Context, namespace, group string) (*RolloutStatus, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	status, err := o.workloadStatus(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	if status.IsComplete() {
+		return status, nil
+	}
+	reasons, err := o.reasons(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	status.Reasons = reasons
+	return status, nil
+}
+
+// Wait 等待进程组发布完成，超时或失败时返回最后一次的状态和错误
+func (o *rolloutOperation) Wait(ctx context.Context, namespace, group string, opts RolloutOptions) (*RolloutStatus, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultRolloutTimeout
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultRolloutInterval
+	}
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		status, err := o.Status(ctx, namespace, group)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(status)
+		}
+		if status.IsComplete() {
+			return status, nil
+		}
+		if status.IsFailed() || (opts.FailFast && status.hasFatal()) {
+			return status, uerrors.NewKubernetesError(ctx, "等待发布完成", "发布失败", status.String())
+		}
+		if time.Now().After(deadline) {
+			return status, uerrors.NewKubernetesError(ctx, "等待发布完成", "超时",
+				fmt.Sprintf("timeout=%v, %s", opts.Timeout, status.String()))
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// History 获取进程组的历史版本，按版本号升序
+func (o *rolloutOperation) History(ctx context.Context, namespace, group string) ([]*Revision, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	kind, err := o.kind(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	var revisions []*Revision
+	if kind == WorkloadDeployment {
+		revisions, err = o.replicaSetHistory(ctx, namespace, group)
+	} else {
+		revisions, err = o.controllerHistory(ctx, namespace, group, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// Rollback 回滚进程组到指定版本，revision 为 0 时回滚到上一个版本
+func (o *rolloutOperation) Rollback(ctx context.Context, namespace, group string, revision int64) error {
+	if o.err != nil {
+		return o.err
+	}
+	kind, err := o.kind(ctx, namespace, group)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case WorkloadDeployment:
+		return o.rollbackDeployment(ctx, namespace, group, revision)
+	default:
+		return o.rollbackControllerRevision(ctx, namespace, group, kind, revision)
+	}
+}
+
+// kind 根据进程组名称判断工作负载类型
+func (o *rolloutOperation) kind(ctx context.Context, namespace, group string) (WorkloadKind, error) {
+	kind, err := o.workloadKind(ctx, namespace, group)
+	if err != nil {
+		return "", err
+	}
+	if len(kind) < 1 {
+		return "", uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("进程组不存在: namespace=%s, group=%s", namespace, group))
+	}
+	return kind, nil
+}
+
+func (o *rolloutOperation) workloadStatus(ctx context.Context, namespace, group string) (*RolloutStatus, error) {
+	apps := o.api.AppsV1()
+	if d, err := apps.Deployments(namespace).Get(ctx, group, v1.GetOptions{}); err == nil {
+		return toDeploymentRollout(d), nil
+	} else if !errors.IsNotFound(err) {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Deployment: namespace=%s, name=%s", namespace, group))
+	}
+	if sts, err := apps.StatefulSets(namespace).Get(ctx, group, v1.GetOptions{}); err == nil {
+		return toStatefulSetRollout(sts), nil
+	} else if !errors.IsNotFound(err) {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取StatefulSet: namespace=%s, name=%s", namespace, group))
+	}
+	if ds, err := apps.DaemonSets(namespace).Get(ctx, group, v1.GetOptions{}); err == nil {
+		return toDaemonSetRollout(ds), nil
+	} else if !errors.IsNotFound(err) {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取DaemonSet: namespace=%s, name=%s", namespace, group))
+	}
+	return nil, uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+		fmt.Sprintf("进程组不存在: namespace=%s, group=%s", namespace, group))
+}
+
+func toDeploymentRollout(d *appsv1.Deployment) *RolloutStatus {
+	status := &RolloutStatus{
+		Namespace: d.Namespace,
+		Group:     d.Name,
+		Kind:      WorkloadDeployment,
+		Phase:     RolloutProgressing,
+		Desired:   replicasOrDefault(d.Spec.Replicas),
+		Updated:   d.Status.UpdatedReplicas,
+		Ready:     d.Status.ReadyReplicas,
+		Available: d.Status.AvailableReplicas,
+	}
+	status.Revision, _ = strconv.ParseInt(d.Annotations[AnnotationRevision], 10, 64)
+	if d.Generation > d.Status.ObservedGeneration {
+		status.Message = "等待控制器处理最新配置"
+		return status
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			status.Phase = RolloutFailed
+			status.Message = c.Message
+			return status
+		}
+	}
+	if status.Updated >= status.Desired && d.Status.Replicas <= status.Updated && status.Available >= status.Updated {
+		status.Phase = RolloutComplete
+	}
+	return status
+}
+
+func toStatefulSetRollout(sts *appsv1.StatefulSet) *RolloutStatus {
+	status := &RolloutStatus{
+		Namespace: sts.Namespace,
+		Group:     sts.Name,
+		Kind:      WorkloadStatefulSet,
+		Phase:     RolloutProgressing,
+		Desired:   replicasOrDefault(sts.Spec.Replicas),
+		Updated:   sts.Status.UpdatedReplicas,
+		Ready:     sts.Status.ReadyReplicas,
+		Available: sts.Status.AvailableReplicas,
+	}
+	if sts.Generation > sts.Status.ObservedGeneration {
+		status.Message = "等待控制器处理最新配置"
+		return status
+	}
+	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		status.Message = "OnDelete 策略需要手动删除实例完成更新"
+	}
+	partition := int32(0)
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil {
+		partition = *ru.Partition
+	}
+	if status.Ready < status.Desired {
+		return status
+	}
+	if partition > 0 {
+		// 分批发布，只要求序号不小于 partition 的实例完成更新
+		if status.Updated >= status.Desired-partition {
+			status.Phase = RolloutComplete
+		}
+		return status
+	}
+	if sts.Status.UpdateRevision == sts.Status.CurrentRevision {
+		status.Phase = RolloutComplete
+	}
+	return status
+}
+
+func toDaemonSetRollout(ds *appsv1.DaemonSet) *RolloutStatus {
+	status := &RolloutStatus{
+		Namespace: ds.Namespace,
+		Group:     ds.Name,
+		Kind:      WorkloadDaemonSet,
+		Phase:     RolloutProgressing,
+		Desired:   ds.Status.DesiredNumberScheduled,
+		Updated:   ds.Status.UpdatedNumberScheduled,
+		Ready:     ds.Status.NumberReady,
+		Available: ds.Status.NumberAvailable,
+	}
+	if ds.Generation > ds.Status.ObservedGeneration {
+		status.Message = "等待控制器处理最新配置"
+		return status
+	}
+	if status.Updated >= status.Desired && status.Available >= status.Desired {
+		status.Phase = RolloutComplete
+	}
+	return status
+}
+
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// reasons 从进程组的 Pod 状态和告警事件中收集未就绪的原因
+func (o *rolloutOperation) reasons(ctx context.Context, namespace, group string) ([]*RolloutReason, error) {
+	pods, err := o.api.CoreV1().Pods(namespace).List(ctx, toGroupListOptions(group))
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Pod列表: namespace=%s, group=%s", namespace, group))
+	}
+	reasons := make([]*RolloutReason, 0)
+	for _, p := range pods.Items {
+		if p.DeletionTimestamp != nil {
+			continue
+		}
+		rs := toPodReasons(p)
+		if len(rs) == 0 && p.Status.Phase == corev1.PodPending {
+			rs = o.eventReasons(ctx, p)
+		}
+		reasons = append(reasons, rs...)
+	}
+	return reasons, nil
+}
+
+func toPodReasons(p corev1.Pod) []*RolloutReason {
+	reasons := make([]*RolloutReason, 0)
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && gstr.ToLower(c.Reason) == Unschedulable {
+			reasons = append(reasons, &RolloutReason{Pod: p.Name, Reason: c.Reason, Message: c.Message, Fatal: true})
+		}
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		switch {
+		case cs.State.Waiting != nil:
+			reason := cs.State.Waiting.Reason
+			if isRolloutFatal(reason) {
+				reasons = append(reasons, &RolloutReason{Pod: p.Name, Process: cs.Name, Reason: reason, Message: cs.State.Waiting.Message, Fatal: true})
+			}
+		case cs.State.Terminated != nil && cs.State.Terminated.ExitCode != 0:
+			t := cs.State.Terminated
+			reasons = append(reasons, &RolloutReason{Pod: p.Name, Process: cs.Name, Reason: t.Reason,
+				Message: fmt.Sprintf("exitCode=%d %s", t.ExitCode, t.Message)})
+		}
+	}
+	return reasons
+}
+
+// eventReasons 读取 Pod 最近的告警事件，用于解释调度、挂载等阶段的失败
+func (o *rolloutOperation) eventReasons(ctx context.Context, p corev1.Pod) []*RolloutReason {
+	events, err := o.api.CoreV1().Events(p.Namespace).List(ctx, v1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s,type=%s", p.Name, corev1.EventTypeWarning),
+	})
+	if err != nil || len(events.Items) == 0 {
+		return nil
+	}
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return eventTime(items[i]).After(eventTime(items[j]))
+	})
+	e := items[0]
+	return []*RolloutReason{{
+		Pod:     p.Name,
+		Reason:  e.Reason,
+		Message: e.Message,
+		Fatal:   e.Reason == "FailedScheduling",
+	}}
+}
+
+func eventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+func isRolloutFatal(reason string) bool {
+	switch gstr.ToLower(reason) {
+	case
+		CrashLoopBackOff,
+		ImagePullBackOff,
+		ErrImagePull,
+		InvalidImageName,
+		ErrImageNeverPull,
+		CreateContainerConfigError,
+		CreateContainerError,
+		RunContainerError:
+		return true
+	default:
+		return false
+	}
+}
+
+func (o *rolloutOperation) replicaSets(ctx context.Context, namespace, group string) (*appsv1.Deployment, []appsv1.ReplicaSet, error) {
+	d, err := o.api.AppsV1().Deployments(namespace).Get(ctx, group, v1.GetOptions{})
+	if err != nil {
+		return nil, nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Deployment: namespace=%s, name=%s", namespace, group))
+	}
+	selector, err := v1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil, nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("解析Deployment选择器: namespace=%s, name=%s", namespace, group))
+	}
+	list, err := o.api.AppsV1().ReplicaSets(namespace).List(ctx, v1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取ReplicaSet列表: namespace=%s, name=%s", namespace, group))
+	}
+	items := make([]appsv1.ReplicaSet, 0, len(list.Items))
+	for _, rs := range list.Items {
+		if isOwnedBy(rs.OwnerReferences, d.UID) {
+			items = append(items, rs)
+		}
+	}
+	return d, items, nil
+}
+
+func (o *rolloutOperation) replicaSetHistory(ctx context.Context, namespace, group string) ([]*Revision, error) {
+	d, items, err := o.replicaSets(ctx, namespace, group)
+	if err != nil {
+		return nil, err
+	}
+	current := d.Annotations[AnnotationRevision]
+	revisions := make([]*Revision, 0, len(items))
+	for _, rs := range items {
+		number, _ := strconv.ParseInt(rs.Annotations[AnnotationRevision], 10, 64)
+		revisions = append(revisions, &Revision{
+			Revision:    number,
+			Name:        rs.Name,
+			Images:      toImages(rs.Spec.Template.Spec),
+			ChangeCause: rs.Annotations[AnnotationChangeCause],
+			Current:     rs.Annotations[AnnotationRevision] == current,
+			Created:     rs.CreationTimestamp.Unix(),
+		})
+	}
+	return revisions, nil
+}
+
+func (o *rolloutOperation) rollbackDeployment(ctx context.Context, namespace, group string, revision int64) error {
+	d, items, err := o.replicaSets(ctx, namespace, group)
+	if err != nil {
+		return err
+	}
+	current, _ := strconv.ParseInt(d.Annotations[AnnotationRevision], 10, 64)
+	target := findReplicaSet(items, current, revision)
+	if target == nil {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("回滚版本不存在: namespace=%s, group=%s, revision=%d", namespace, group, revision))
+	}
+	template := target.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	d.Spec.Template = *template
+	if d.Annotations == nil {
+		d.Annotations = make(map[string]string)
+	}
+	if cause, ok := target.Annotations[AnnotationChangeCause]; ok {
+		d.Annotations[AnnotationChangeCause] = cause
+	} else {
+		delete(d.Annotations, AnnotationChangeCause)
+	}
+	if _, err := o.api.AppsV1().Deployments(namespace).Update(ctx, d, v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("回滚Deployment: namespace=%s, name=%s, revision=%d", namespace, group, revision))
+	}
+	return nil
+}
+
+// findReplicaSet 查找回滚目标，revision 为 0 时取当前版本之前最近的一个
+func findReplicaSet(items []appsv1.ReplicaSet, current, revision int64) *appsv1.ReplicaSet {
+	var (
+		target *appsv1.ReplicaSet
+		max    int64
+	)
+	for i := range items {
+		number, _ := strconv.ParseInt(items[i].Annotations[AnnotationRevision], 10, 64)
+		if revision > 0 {
+			if number == revision {
+				return &items[i]
+			}
+			continue
+		}
+		if number < current && number > max {
+			target, max = &items[i], number
+		}
+	}
+	return target
+}
+
+func (o *rolloutOperation) controllerRevisions(ctx context.Context, namespace, group string, kind WorkloadKind) ([]appsv1.ControllerRevision, string, error) {
+	var (
+		uid      k8stypes.UID
+		selector *v1.LabelSelector
+		current  string
+	)
+	apps := o.api.AppsV1()
+	switch kind {
+	case WorkloadStatefulSet:
+		sts, err := apps.StatefulSets(namespace).Get(ctx, group, v1.GetOptions{})
+		if err != nil {
+			return nil, "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取StatefulSet: namespace=%s, name=%s", namespace, group))
+		}
+		uid, selector, current = sts.UID, sts.Spec.Selector, sts.Status.UpdateRevision
+	default:
+		ds, err := apps.DaemonSets(namespace).Get(ctx, group, v1.GetOptions{})
+		if err != nil {
+			return nil, "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取DaemonSet: namespace=%s, name=%s", namespace, group))
+		}
+		uid, selector = ds.UID, ds.Spec.Selector
+	}
+	s, err := v1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("解析%s选择器: namespace=%s, name=%s", kind, namespace, group))
+	}
+	list, err := apps.ControllerRevisions(namespace).List(ctx, v1.ListOptions{LabelSelector: s.String()})
+	if err != nil {
+		return nil, "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取ControllerRevision列表: namespace=%s, name=%s", namespace, group))
+	}
+	items := make([]appsv1.ControllerRevision, 0, len(list.Items))
+	for _, r := range list.Items {
+		if isOwnedBy(r.OwnerReferences, uid) {
+			items = append(items, r)
+		}
+	}
+	if len(current) == 0 {
+		// DaemonSet 未在状态中记录当前版本，取版本号最大的一个
+		for _, r := range items {
+			if len(current) == 0 || r.Revision > revisionOf(items, current) {
+				current = r.Name
+			}
+		}
+	}
+	return items, current, nil
+}
+
+func revisionOf(items []appsv1.ControllerRevision, name string) int64 {
+	for _, r := range items {
+		if r.Name == name {
+			return r.Revision
+		}
+	}
+	return 0
+}
+
+func (o *rolloutOperation) controllerHistory(ctx context.Context, namespace, group string, kind WorkloadKind) ([]*Revision, error) {
+	items, current, err := o.controllerRevisions(ctx, namespace, group, kind)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]*Revision, 0, len(items))
+	for _, r := range items {
+		revisions = append(revisions, &Revision{
+			Revision:    r.Revision,
+			Name:        r.Name,
+			ChangeCause: r.Annotations[AnnotationChangeCause],
+			Current:     r.Name == current,
+			Created:     r.CreationTimestamp.Unix(),
+		})
+	}
+	return revisions, nil
+}
+
+// rollbackControllerRevision StatefulSet 和 DaemonSet 的历史版本保存的是模板补丁，直接打回工作负载
+func (o *rolloutOperation) rollbackControllerRevision(ctx context.Context, namespace, group string, kind WorkloadKind, revision int64) error {
+	items, current, err := o.controllerRevisions(ctx, namespace, group, kind)
+	if err != nil {
+		return err
+	}
+	var target *appsv1.ControllerRevision
+	currentRevision := revisionOf(items, current)
+	for i := range items {
+		r := &items[i]
+		if revision > 0 {
+			if r.Revision == revision {
+				target = r
+				break
+			}
+			continue
+		}
+		if r.Revision < currentRevision && (target == nil || r.Revision > target.Revision) {
+			target = r
+		}
+	}
+	if target == nil {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("回滚版本不存在: namespace=%s, group=%s, revision=%d", namespace, group, revision))
+	}
+	operation := fmt.Sprintf("回滚%s: namespace=%s, name=%s, revision=%d", kind, namespace, group, target.Revision)
+	if kind == WorkloadStatefulSet {
+		_, err = o.api.AppsV1().StatefulSets(namespace).Patch(ctx, group, k8stypes.StrategicMergePatchType, target.Data.Raw, v1.PatchOptions{})
+	} else {
+		_, err = o.api.AppsV1().DaemonSets(namespace).Patch(ctx, group, k8stypes.StrategicMergePatchType, target.Data.Raw, v1.PatchOptions{})
+	}
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, operation)
+	}
+	return nil
+}
+
+func isOwnedBy(refs []v1.OwnerReference, uid k8stypes.UID) bool {
+	for _, ref := range refs {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func toImages(spec corev1.PodSpec) map[string]string {
+	images := make(map[string]string, len(spec.Containers))
+	for _, c := range spec.Containers {
+		images[c.Name] = c.Image
+	}
+	return images
+}
@@ -16,6 +16,7 @@ import (
 
 type serviceOperation struct {
 	*options
+	k8s *Kubernetes
 }
 
 type Service struct {
@@ -279,6 +280,10 @@ func (o *serviceOperation) DeleteGroup(ctx context.Context, namespace string, gr
 			}
 		}
 	}
+	// 服务删除后，指向它的 HTTP 入口也一并清理
+	if err := o.k8s.Ingress().DeleteGroup(ctx, namespace, groups...); err != nil {
+		lastErr = err
+	}
 	return lastErr
 }
 
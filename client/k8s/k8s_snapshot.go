@@ -0,0 +1,325 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/hosgf/element/types"
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// LabelSnapshotSet 快照集名称，同一次备份的快照使用相同的快照集
+	LabelSnapshotSet = "x-platform-snapshot-set"
+	// SnapshotGroup 快照 API 的资源组
+	SnapshotGroup = "snapshot.storage.k8s.io"
+)
+
+// VolumeSnapshotResource CSI 快照资源
+var VolumeSnapshotResource = schema.GroupVersionResource{Group: SnapshotGroup, Version: "v1", Resource: "volumesnapshots"}
+
+type snapshotOperation struct {
+	*options
+	k8s *Kubernetes
+}
+
+// Snapshot 存储快照
+type Snapshot struct {
+	Model
+	Storage     string `json:"storage,omitempty"`     // 快照来源的存储名称
+	Class       string `json:"class,omitempty"`       // VolumeSnapshotClass，为空时使用默认值
+	Set         string `json:"set,omitempty"`         // 快照集名称
+	ReadyToUse  bool   `json:"readyToUse,omitempty"`  // 是否可用于恢复
+	RestoreSize string `json:"restoreSize,omitempty"` // 恢复所需的最小容量
+	Error       string `json:"error,omitempty"`
+	Created     int64  `json:"created,omitempty"`
+}
+
+func (s *Snapshot) toVolumeSnapshot() *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{"persistentVolumeClaimName": s.Storage},
+	}
+	if len(s.Class) > 0 {
+		spec["volumeSnapshotClassName"] = s.Class
+	}
+	labels := s.labels()
+	if len(s.Set) > 0 {
+		labels[LabelSnapshotSet] = s.Set
+	}
+	snapshot := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": VolumeSnapshotResource.GroupVersion().String(),
+		"kind":       "VolumeSnapshot",
+		"spec":       spec,
+	}}
+	snapshot.SetName(s.Model.Name)
+	snapshot.SetNamespace(s.Namespace)
+	snapshot.SetLabels(labels)
+	return snapshot
+}
+
+func toSnapshot(data unstructured.Unstructured) *Snapshot {
+	labels := data.GetLabels()
+	s := &Snapshot{
+		Model:   Model{Namespace: data.GetNamespace(), Name: data.GetName()},
+		Set:     labels[LabelSnapshotSet],
+		Created: data.GetCreationTimestamp().Unix(),
+	}
+	delete(labels, LabelSnapshotSet)
+	s.setLabels(labels)
+	s.Storage, _, _ = unstructured.NestedString(data.Object, "spec", "source", "persistentVolumeClaimName")
+	s.Class, _, _ = unstructured.NestedString(data.Object, "spec", "volumeSnapshotClassName")
+	s.ReadyToUse, _, _ = unstructured.NestedBool(data.Object, "status", "readyToUse")
+	s.RestoreSize, _, _ = unstructured.NestedString(data.Object, "status", "restoreSize")
+	s.Error, _, _ = unstructured.NestedString(data.Object, "status", "error", "message")
+	return s
+}
+
+// check 快照为 CRD 资源，需要动态客户端
+func (o *snapshotOperation) check() error {
+	if o.err != nil {
+		return o.err
+	}
+	if o.dynamicApi == nil {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceNotFound, "未配置动态客户端，无法使用存储快照")
+	}
+	return nil
+}
+
+// Create 为存储创建快照
+func (o *snapshotOperation) Create(ctx context.Context, snapshot *Snapshot) error {
+	if err := o.check(); err != nil {
+		return err
+	}
+	if len(snapshot.Storage) < 1 {
+		return uerrors.NewValidationError("storage", "请传入要创建快照的存储名称")
+	}
+	if len(snapshot.Model.Name) < 1 {
+		snapshot.Model.Name = fmt.Sprintf("%s-%s", snapshot.Storage, gconv.String(time.Now().Unix()))
+	}
+	api := o.dynamicApi.Resource(VolumeSnapshotResource).Namespace(snapshot.Namespace)
+	if _, err := api.Create(ctx, snapshot.toVolumeSnapshot(), v1.CreateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建快照: namespace=%s, name=%s", snapshot.Namespace, snapshot.Model.Name))
+	}
+	return nil
+}
+
+// CreateGroup 为进程组的全部存储创建同一快照集的快照
+func (o *snapshotOperation) CreateGroup(ctx context.Context, namespace, group, set, class string) ([]*Snapshot, error) {
+	if err := o.check(); err != nil {
+		return nil, err
+	}
+	if len(set) < 1 {
+		return nil, uerrors.NewValidationError("set", "请传入快照集名称")
+	}
+	pvcs, err := o.api.CoreV1().PersistentVolumeClaims(namespace).List(ctx, toGroupListOptions(group))
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Storage列表: namespace=%s, group=%s", namespace, group))
+	}
+	snapshots := make([]*Snapshot, 0, len(pvcs.Items))
+	for _, pvc := range pvcs.Items {
+		s := &Snapshot{
+			Model:   Model{Namespace: namespace, Name: fmt.Sprintf("%s-%s", pvc.Name, set)},
+			Storage: pvc.Name,
+			Class:   class,
+			Set:     set,
+		}
+		s.setLabels(pvc.Labels)
+		if err := o.Create(ctx, s); err != nil {
+			return snapshots, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
+
+func (o *snapshotOperation) Get(ctx context.Context, namespace, name string) (*Snapshot, error) {
+	if err := o.check(); err != nil {
+		return nil, err
+	}
+	data, err := o.dynamicApi.Resource(VolumeSnapshotResource).Namespace(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取快照: namespace=%s, name=%s", namespace, name))
+	}
+	return toSnapshot(*data), nil
+}
+
+// List 获取进程组的快照，set 为空时返回全部快照集
+func (o *snapshotOperation) List(ctx context.Context, namespace, group, set string) ([]*Snapshot, error) {
+	if err := o.check(); err != nil {
+		return nil, err
+	}
+	opts := toGroupListOptions(group)
+	if len(set) > 0 {
+		selector := fmt.Sprintf("%s=%s", LabelSnapshotSet, set)
+		if len(opts.LabelSelector) > 0 {
+			selector = fmt.Sprintf("%s,%s", opts.LabelSelector, selector)
+		}
+		opts.LabelSelector = selector
+	}
+	list, err := o.dynamicApi.Resource(VolumeSnapshotResource).Namespace(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取快照列表: namespace=%s, group=%s", namespace, group))
+	}
+	snapshots := make([]*Snapshot, 0, len(list.Items))
+	for _, item := range list.Items {
+		snapshots = append(snapshots, toSnapshot(item))
+	}
+	return snapshots, nil
+}
+
+func (o *snapshotOperation) Delete(ctx context.Context, namespace string, names ...string) error {
+	if err := o.check(); err != nil {
+		return err
+	}
+	api := o.dynamicApi.Resource(VolumeSnapshotResource).Namespace(namespace)
+	var lastErr error
+	for _, name := range names {
+		if err := api.Delete(ctx, name, v1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			lastErr = uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除快照: namespace=%s, name=%s", namespace, name))
+		}
+	}
+	return lastErr
+}
+
+// DeleteSet 删除进程组的一个快照集
+func (o *snapshotOperation) DeleteSet(ctx context.Context, namespace, group, set string) error {
+	if len(set) < 1 {
+		return uerrors.NewValidationError("set", "请传入快照集名称")
+	}
+	snapshots, err := o.List(ctx, namespace, group, set)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(snapshots))
+	for _, s := range snapshots {
+		names = append(names, s.Model.Name)
+	}
+	return o.Delete(ctx, namespace, names...)
+}
+
+// CreateStorage 从快照创建新的存储，容量不小于快照的恢复容量
+func (o *snapshotOperation) CreateStorage(ctx context.Context, storage *PersistentStorage, snapshot string) error {
+	if err := o.check(); err != nil {
+		return err
+	}
+	s, err := o.Get(ctx, storage.Namespace, snapshot)
+	if err != nil {
+		return err
+	}
+	if !s.ReadyToUse {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("快照尚不可用: namespace=%s, name=%s, error=%s", storage.Namespace, snapshot, s.Error))
+	}
+	if restore, err := resource.ParseQuantity(s.RestoreSize); err == nil {
+		if size, err := resource.ParseQuantity(storage.Size); err != nil || size.Cmp(restore) < 0 {
+			storage.Size = restore.String()
+		}
+	}
+	if len(storage.Size) < 1 {
+		return uerrors.NewValidationError("size", "请传入存储大小")
+	}
+	pvc := storage.toPvc()
+	group := SnapshotGroup
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{APIGroup: &group, Kind: "VolumeSnapshot", Name: snapshot}
+	pvc.Spec.DataSourceRef = &corev1.TypedObjectReference{APIGroup: &group, Kind: "VolumeSnapshot", Name: snapshot}
+	if _, err := o.api.CoreV1().PersistentVolumeClaims(storage.Namespace).Create(ctx, pvc, v1.CreateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("从快照创建Storage: namespace=%s, name=%s, snapshot=%s", storage.Namespace, storage.Storage.Name, snapshot))
+	}
+	return nil
+}
+
+// Restore 将进程组的存储恢复到快照集，恢复期间进程组副本数降为 0，完成后还原
+// 恢复失败时进程组保持停止状态，便于人工处理
+func (o *snapshotOperation) Restore(ctx context.Context, namespace, group, set string, timeout time.Duration) error {
+	snapshots, err := o.List(ctx, namespace, group, set)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) < 1 {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceNotFound,
+			fmt.Sprintf("快照集不存在: namespace=%s, group=%s, set=%s", namespace, group, set))
+	}
+	for _, s := range snapshots {
+		if !s.ReadyToUse {
+			return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+				fmt.Sprintf("快照尚不可用: namespace=%s, name=%s, error=%s", namespace, s.Model.Name, s.Error))
+		}
+	}
+	if timeout <= 0 {
+		timeout = DefaultRolloutTimeout
+	}
+	kind, err := o.k8s.Rollout().kind(ctx, namespace, group)
+	if err != nil {
+		return err
+	}
+	replicas, err := o.setReplicas(ctx, namespace, group, kind, 0)
+	if err != nil {
+		return err
+	}
+	if err := o.waitPodsDeleted(ctx, namespace, group, timeout); err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		if err := o.restoreStorage(ctx, s, timeout); err != nil {
+			return err
+		}
+	}
+	_, err = o.setReplicas(ctx, namespace, group, kind, replicas)
+	return err
+}
+
+// restoreStorage 删除原有存储，并以相同的名称、标签和规格从快照重建
+func (o *snapshotOperation) restoreStorage(ctx context.Context, snapshot *Snapshot, timeout time.Duration) error {
+	namespace, name := snapshot.Namespace, snapshot.Storage
+	storage := &PersistentStorage{Model: snapshot.Model, Storage: types.Storage{Name: name, Type: types.StoragePVC}}
+	storage.Model.Name = name
+	pvc, err := o.api.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, v1.GetOptions{})
+	if err == nil {
+		storage.Size = pvc.Spec.Resources.Requests.Storage().String()
+		if len(pvc.Spec.AccessModes) > 0 {
+			storage.AccessMode = types.AccessMode(pvc.Spec.AccessModes[0])
+		}
+		if pvc.Spec.StorageClassName != nil {
+			storage.Item = *pvc.Spec.StorageClassName
+		}
+		if err := o.k8s.Storage().delete(ctx, namespace, name); err != nil {
+			return err
+		}
+		if err := o.k8s.Storage().WaitDeleted(ctx, namespace, name, timeout); err != nil {
+			return err
+		}
+	} else if !errors.IsNotFound(err) {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Storage: namespace=%s, name=%s", namespace, name))
+	}
+	return o.CreateStorage(ctx, storage, snapshot.Model.Name)
+}
+
+func (o *snapshotOperation) waitPodsDeleted(ctx context.Context, namespace, group string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pods, err := o.api.CoreV1().Pods(namespace).List(ctx, toGroupListOptions(group))
+		if err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Pod列表: namespace=%s, group=%s", namespace, group))
+		}
+		if len(pods.Items) < 1 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return uerrors.NewKubernetesError(ctx, "等待进程组停止", "超时",
+				fmt.Sprintf("namespace=%s, group=%s, timeout=%v", namespace, group, timeout))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(DefaultRolloutInterval):
+		}
+	}
+}
@@ -48,8 +48,9 @@ func (s *PersistentStorage) toPvc() *corev1.PersistentVolumeClaim {
 	}
 }
 
+// updatePvc 只刷新标签，容量变更需通过 Resize 完成扩容检查，避免写入小于 requests 的 limits
 func (s *PersistentStorage) updatePvc(data *corev1.PersistentVolumeClaim) *corev1.PersistentVolumeClaim {
-	data.Spec.Resources.Limits = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(s.Size)}
+	data.Labels = s.labels()
 	return data
 }
 
@@ -249,10 +250,16 @@ func (o *storageOperation) toStorage(data *corev1.PersistentVolumeClaim) *types.
 	}
 	sc := data.Spec
 	s := &types.Storage{
-		Name:       data.Name,
-		AccessMode: types.AccessMode(sc.AccessModes[0]),
-		Size:       sc.Resources.Limits.Storage().String(),
-		Item:       sc.StorageClassName,
+		Name:     data.Name,
+		Size:     sc.Resources.Requests.Storage().String(),
+		Capacity: data.Status.Capacity.Storage().String(),
+		Item:     sc.StorageClassName,
+	}
+	if sc.Resources.Requests.Storage().IsZero() {
+		s.Size = sc.Resources.Limits.Storage().String()
+	}
+	if len(sc.AccessModes) > 0 {
+		s.AccessMode = types.AccessMode(sc.AccessModes[0])
 	}
 	return s
 }
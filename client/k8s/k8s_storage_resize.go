@@ -0,0 +1,197 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hosgf/element/uerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	DefaultResizeTimeout  = 5 * time.Minute
+	DefaultResizeInterval = 2 * time.Second
+)
+
+// 扩容阶段
+const (
+	ResizeProgressing       = "progressing"
+	ResizeFileSystemPending = "filesystempending" // 卷已扩容，等待节点扩展文件系统
+	ResizeComplete          = "complete"
+	ResizeFailed            = "failed"
+)
+
+// ResizeOptions 存储扩容的参数
+type ResizeOptions struct {
+	Timeout      time.Duration // 超时时间，默认 5 分钟
+	Interval     time.Duration // 轮询间隔，默认 2 秒
+	NoWait       bool          // 只提交扩容请求，不等待结果
+	AllowPending bool          // 文件系统扩容需要实例重新挂载时不再等待，直接返回
+}
+
+// ResizeStatus 存储扩容状态
+type ResizeStatus struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Requested string `json:"requested,omitempty"` // 申请的容量
+	Capacity  string `json:"capacity,omitempty"`  // 实际容量
+	Phase     string `json:"phase,omitempty"`     // progressing filesystempending complete failed
+	Message   string `json:"message,omitempty"`
+}
+
+func (s *ResizeStatus) IsComplete() bool {
+	return s.Phase == ResizeComplete
+}
+
+func (s *ResizeStatus) IsFailed() bool {
+	return s.Phase == ResizeFailed
+}
+
+func (s *ResizeStatus) String() string {
+	msg := fmt.Sprintf("namespace=%s, name=%s, phase=%s, capacity=%s/%s", s.Namespace, s.Name, s.Phase, s.Capacity, s.Requested)
+	if len(s.Message) > 0 {
+		msg = fmt.Sprintf("%s, message=%s", msg, s.Message)
+	}
+	return msg
+}
+
+// Resize 扩容存储，StorageClass 需要允许扩容，不支持缩容
+func (o *storageOperation) Resize(ctx context.Context, namespace, name, size string, opts ResizeOptions) (*ResizeStatus, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	target, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, uerrors.NewValidationError("size", fmt.Sprintf("存储大小不合法: size=%s", size))
+	}
+	pvc, err := o.api.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Storage: namespace=%s, name=%s", namespace, name))
+	}
+	current := pvc.Spec.Resources.Requests.Storage()
+	switch target.Cmp(*current) {
+	case -1:
+		return nil, uerrors.NewValidationError("size",
+			fmt.Sprintf("存储不支持缩容: namespace=%s, name=%s, current=%s, size=%s", namespace, name, current.String(), size))
+	case 0:
+		return toResizeStatus(pvc), nil
+	}
+	if err := o.checkExpansion(ctx, pvc); err != nil {
+		return nil, err
+	}
+	patch := fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":%q}}}}`, target.String())
+	if _, ok := pvc.Spec.Resources.Limits[corev1.ResourceStorage]; ok {
+		patch = fmt.Sprintf(`{"spec":{"resources":{"requests":{"storage":%q},"limits":{"storage":%q}}}}`, target.String(), target.String())
+	}
+	pvc, err = o.api.CoreV1().PersistentVolumeClaims(namespace).Patch(ctx, name, k8stypes.MergePatchType, []byte(patch), v1.PatchOptions{})
+	if err != nil {
+		return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("扩容Storage: namespace=%s, name=%s, size=%s", namespace, name, size))
+	}
+	if opts.NoWait {
+		return toResizeStatus(pvc), nil
+	}
+	return o.WaitResize(ctx, namespace, name, opts)
+}
+
+// checkExpansion 检查存储所属的 StorageClass 是否允许扩容
+func (o *storageOperation) checkExpansion(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	if pvc.Spec.StorageClassName == nil || len(*pvc.Spec.StorageClassName) < 1 {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("Storage未指定StorageClass，无法扩容: namespace=%s, name=%s", pvc.Namespace, pvc.Name))
+	}
+	class := *pvc.Spec.StorageClassName
+	sc, err := o.api.StorageV1().StorageClasses().Get(ctx, class, v1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return uerrors.NewBizLogicError(uerrors.CodeResourceNotFound, fmt.Sprintf("StorageClass不存在: name=%s", class))
+		}
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取StorageClass: name=%s", class))
+	}
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("StorageClass不允许扩容: name=%s", class))
+	}
+	return nil
+}
+
+// WaitResize 等待存储扩容完成，超时或失败时返回最后一次的状态和错误
+func (o *storageOperation) WaitResize(ctx context.Context, namespace, name string, opts ResizeOptions) (*ResizeStatus, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultResizeTimeout
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultResizeInterval
+	}
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		pvc, err := o.api.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, v1.GetOptions{})
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Storage: namespace=%s, name=%s", namespace, name))
+		}
+		status := toResizeStatus(pvc)
+		if status.IsComplete() || (opts.AllowPending && status.Phase == ResizeFileSystemPending) {
+			return status, nil
+		}
+		if status.IsFailed() {
+			return status, uerrors.NewKubernetesError(ctx, "等待存储扩容", "扩容失败", status.String())
+		}
+		if time.Now().After(deadline) {
+			return status, uerrors.NewKubernetesError(ctx, "等待存储扩容", "超时",
+				fmt.Sprintf("timeout=%v, %s", opts.Timeout, status.String()))
+		}
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+func toResizeStatus(pvc *corev1.PersistentVolumeClaim) *ResizeStatus {
+	requested := pvc.Spec.Resources.Requests.Storage()
+	capacity := pvc.Status.Capacity.Storage()
+	status := &ResizeStatus{
+		Namespace: pvc.Namespace,
+		Name:      pvc.Name,
+		Requested: requested.String(),
+		Capacity:  capacity.String(),
+		Phase:     ResizeProgressing,
+	}
+	for _, s := range pvc.Status.AllocatedResourceStatuses {
+		if s == corev1.PersistentVolumeClaimControllerResizeInfeasible || s == corev1.PersistentVolumeClaimNodeResizeInfeasible {
+			status.Phase = ResizeFailed
+			status.Message = string(s)
+			return status
+		}
+	}
+	for _, c := range pvc.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case corev1.PersistentVolumeClaimControllerResizeError, corev1.PersistentVolumeClaimNodeResizeError:
+			status.Phase = ResizeFailed
+			status.Message = c.Message
+			return status
+		case corev1.PersistentVolumeClaimFileSystemResizePending:
+			status.Phase = ResizeFileSystemPending
+			status.Message = c.Message
+			return status
+		case corev1.PersistentVolumeClaimResizing:
+			status.Message = c.Message
+			return status
+		}
+	}
+	if capacity.Cmp(*requested) >= 0 {
+		status.Phase = ResizeComplete
+	}
+	return status
+}
@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -42,6 +43,9 @@ func (o *storageResourceOperation) Apply(ctx context.Context, storage *Persisten
 	if len(res.Item) < 1 {
 		return nil
 	}
+	if err := storage.validate(); err != nil {
+		return err
+	}
 	if has, _, err := o.exists(ctx, storage.Storage.Name); has {
 		if err != nil {
 			return err
@@ -172,26 +176,49 @@ func (o *storageResourceOperation) IsDeleting(ctx context.Context, name string)
 	return pv.DeletionTimestamp != nil, nil
 }
 
-func (o *storageResourceOperation) toStorage(datas *corev1.PersistentVolume) *types.Storage {
-	if datas == nil {
+func (o *storageResourceOperation) toStorage(data *corev1.PersistentVolume) *types.Storage {
+	if data == nil {
 		return nil
 	}
-	//pods := make([]*Pod, 0, len(datas.Items))
-	//for _, p := range datas.Items {
-	//	pod := &Pod{
-	//		Model:       Model{Namespace: namespace, Name: p.Name},
-	//		Status:      string(p.Status.Phase),
-	//		Containers:  make([]*Container, 0),
-	//		RunningNode: p.Spec.NodeName,
-	//	}
-	//	pod.setLabels(p.Labels)
-	//	for _, c := range p.Spec.Containers {
-	//		pod.toContainer(c)
-	//	}
-	//	pods = append(pods, pod)
-	//}
-	//return pods
-	return &types.Storage{}
+	sc := data.Spec
+	s := &types.Storage{
+		Name: data.Name,
+		Type: types.StoragePVC,
+		Size: sc.Capacity.Storage().String(),
+		Item: sc.StorageClassName,
+	}
+	if len(sc.AccessModes) > 0 {
+		s.AccessMode = types.AccessMode(sc.AccessModes[0])
+	}
+	var item interface{}
+	switch {
+	case sc.RBD != nil:
+		s.Resource.Type = string(StorageResourceRBD)
+		item = sc.RBD
+	case sc.NFS != nil:
+		s.Resource.Type = string(StorageResourceNFS)
+		s.Path = sc.NFS.Path
+		item = sc.NFS
+	case sc.CephFS != nil:
+		s.Resource.Type = string(StorageResourceCephFS)
+		s.Path = sc.CephFS.Path
+		item = sc.CephFS
+	case sc.Local != nil:
+		s.Resource.Type = string(StorageResourceLocal)
+		s.Path = sc.Local.Path
+		item = &LocalResource{Path: sc.Local.Path, FSType: gconv.String(sc.Local.FSType), Nodes: toAffinityNodes(sc.NodeAffinity)}
+	case sc.CSI != nil:
+		s.Resource.Type = string(StorageResourceCSI)
+		item = sc.CSI
+	case sc.HostPath != nil:
+		s.Path = sc.HostPath.Path
+	}
+	if item != nil {
+		if data, err := json.Marshal(item); err == nil {
+			s.Resource.Item = string(data)
+		}
+	}
+	return s
 }
 
 type Resource struct {
@@ -203,6 +230,13 @@ type Resource struct {
 	Nodes      []string `json:"nodes,omitempty"`
 }
 
+// LocalResource 节点本地磁盘，PV 只能被调度到 Nodes 中的节点
+type LocalResource struct {
+	Path   string   `json:"path,omitempty"`   // 节点上的磁盘路径
+	FSType string   `json:"fsType,omitempty"` // 文件系统类型，可为空
+	Nodes  []string `json:"nodes,omitempty"`  // 磁盘所在的节点
+}
+
 type PersistentStorageResource struct {
 	Model
 	types.Storage
@@ -212,6 +246,47 @@ func (s *PersistentStorageResource) toStorageResourceType() StorageResourceType
 	return ToStorageResourceType(s.Resource.Type)
 }
 
+// validate 校验存储资源详情，Resource.Item 为对应 PV 来源的 JSON
+func (s *PersistentStorageResource) validate() error {
+	if len(s.Resource.Item) < 1 || s.ToStorageType() != types.StoragePVC {
+		return nil
+	}
+	if _, err := resource.ParseQuantity(s.Size); err != nil {
+		return uerrors.NewValidationError("size", fmt.Sprintf("存储大小不合法: name=%s, size=%s", s.Storage.Name, s.Size))
+	}
+	switch s.toStorageResourceType() {
+	case StorageResourceRBD:
+		rbd := s.toRBD()
+		if rbd == nil || len(rbd.CephMonitors) < 1 || len(rbd.RBDImage) < 1 {
+			return uerrors.NewValidationError("resource.item", "RBD存储需要传入monitors和image")
+		}
+	case StorageResourceNFS:
+		nfs := s.toNFS()
+		if nfs == nil || len(nfs.Server) < 1 || len(nfs.Path) < 1 {
+			return uerrors.NewValidationError("resource.item", "NFS存储需要传入server和path")
+		}
+	case StorageResourceCephFS:
+		cephfs := s.toCephFS()
+		if cephfs == nil || len(cephfs.Monitors) < 1 {
+			return uerrors.NewValidationError("resource.item", "CephFS存储需要传入monitors")
+		}
+	case StorageResourceLocal:
+		local := s.toLocal()
+		if local == nil || len(local.Path) < 1 {
+			return uerrors.NewValidationError("resource.item", "Local存储需要传入path")
+		}
+		if len(local.Nodes) < 1 {
+			return uerrors.NewValidationError("resource.item", "Local存储需要传入所在节点nodes")
+		}
+	case StorageResourceCSI:
+		csi := s.toCSI()
+		if csi == nil || len(csi.Driver) < 1 || len(csi.VolumeHandle) < 1 {
+			return uerrors.NewValidationError("resource.item", "CSI存储需要传入driver和volumeHandle")
+		}
+	}
+	return nil
+}
+
 func (s *PersistentStorageResource) toPv() *corev1.PersistentVolume {
 	spec := corev1.PersistentVolumeSpec{
 		Capacity: corev1.ResourceList{
@@ -223,16 +298,9 @@ func (s *PersistentStorageResource) toPv() *corev1.PersistentVolume {
 		PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
 		StorageClassName:              gconv.String(s.Item),
 	}
-	spec.HostPath = &corev1.HostPathVolumeSource{
-		Path: s.GetPath(),
-	}
-	switch s.ToStorageType() {
-	case types.StoragePVC:
-		switch s.toStorageResourceType() {
-		case StorageResourceRBD:
-			volumeMode := corev1.PersistentVolumeBlock
-			spec.VolumeMode = &volumeMode
-			spec.RBD = s.toRBD()
+	if !s.setSource(&spec) {
+		spec.HostPath = &corev1.HostPathVolumeSource{
+			Path: s.GetPath(),
 		}
 	}
 	return &corev1.PersistentVolume{
@@ -245,6 +313,43 @@ func (s *PersistentStorageResource) toPv() *corev1.PersistentVolume {
 	}
 }
 
+// setSource 按存储资源类型设置 PV 来源，未能识别时返回 false
+func (s *PersistentStorageResource) setSource(spec *corev1.PersistentVolumeSpec) bool {
+	if s.ToStorageType() != types.StoragePVC {
+		return false
+	}
+	switch s.toStorageResourceType() {
+	case StorageResourceRBD:
+		if spec.RBD = s.toRBD(); spec.RBD == nil {
+			return false
+		}
+		volumeMode := corev1.PersistentVolumeBlock
+		spec.VolumeMode = &volumeMode
+	case StorageResourceNFS:
+		spec.NFS = s.toNFS()
+		return spec.NFS != nil
+	case StorageResourceCephFS:
+		spec.CephFS = s.toCephFS()
+		return spec.CephFS != nil
+	case StorageResourceLocal:
+		local := s.toLocal()
+		if local == nil {
+			return false
+		}
+		spec.Local = &corev1.LocalVolumeSource{Path: local.Path}
+		if len(local.FSType) > 0 {
+			spec.Local.FSType = &local.FSType
+		}
+		spec.NodeAffinity = toVolumeNodeAffinity(local.Nodes)
+	case StorageResourceCSI:
+		spec.CSI = s.toCSI()
+		return spec.CSI != nil
+	default:
+		return false
+	}
+	return true
+}
+
 func (s *PersistentStorageResource) toRBD() *corev1.RBDPersistentVolumeSource {
 	r := s.Resource
 	if len(r.Item) < 1 {
@@ -256,3 +361,68 @@ func (s *PersistentStorageResource) toRBD() *corev1.RBDPersistentVolumeSource {
 	}
 	return &rbd
 }
+
+func (s *PersistentStorageResource) toNFS() *corev1.NFSVolumeSource {
+	var nfs corev1.NFSVolumeSource
+	if err := gconv.Struct(s.Resource.Item, &nfs); err != nil {
+		return nil
+	}
+	return &nfs
+}
+
+func (s *PersistentStorageResource) toCephFS() *corev1.CephFSPersistentVolumeSource {
+	var cephfs corev1.CephFSPersistentVolumeSource
+	if err := gconv.Struct(s.Resource.Item, &cephfs); err != nil {
+		return nil
+	}
+	return &cephfs
+}
+
+func (s *PersistentStorageResource) toLocal() *LocalResource {
+	var local LocalResource
+	if err := gconv.Struct(s.Resource.Item, &local); err != nil {
+		return nil
+	}
+	if len(local.Path) < 1 {
+		local.Path = s.Path
+	}
+	return &local
+}
+
+// toCSI 支持 driver、volumeHandle、volumeAttributes 以及各阶段的 secretRef
+func (s *PersistentStorageResource) toCSI() *corev1.CSIPersistentVolumeSource {
+	var csi corev1.CSIPersistentVolumeSource
+	if err := gconv.Struct(s.Resource.Item, &csi); err != nil {
+		return nil
+	}
+	return &csi
+}
+
+func toVolumeNodeAffinity(nodes []string) *corev1.VolumeNodeAffinity {
+	return &corev1.VolumeNodeAffinity{
+		Required: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      corev1.LabelHostname,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   nodes,
+				}},
+			}},
+		},
+	}
+}
+
+func toAffinityNodes(affinity *corev1.VolumeNodeAffinity) []string {
+	if affinity == nil || affinity.Required == nil {
+		return nil
+	}
+	nodes := make([]string, 0)
+	for _, term := range affinity.Required.NodeSelectorTerms {
+		for _, e := range term.MatchExpressions {
+			if e.Key == corev1.LabelHostname && e.Operator == corev1.NodeSelectorOpIn {
+				nodes = append(nodes, e.Values...)
+			}
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,494 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/types"
+	"github.com/hosgf/element/uerrors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// WorkloadKind 进程组的工作负载类型
+type WorkloadKind string
+
+const (
+	WorkloadDeployment  WorkloadKind = "Deployment"  // 无状态，默认
+	WorkloadStatefulSet WorkloadKind = "StatefulSet" // 有状态，副本拥有稳定标识和独立存储
+	WorkloadDaemonSet   WorkloadKind = "DaemonSet"   // 每个节点运行一个副本
+)
+
+func (k WorkloadKind) String() string {
+	return string(k)
+}
+
+func ToWorkloadKind(kind string) WorkloadKind {
+	switch gstr.ToLower(kind) {
+	case "statefulset", "sts":
+		return WorkloadStatefulSet
+	case "daemonset", "ds":
+		return WorkloadDaemonSet
+	default:
+		return WorkloadDeployment
+	}
+}
+
+func (pod *Pod) workloadKind() WorkloadKind {
+	return ToWorkloadKind(pod.Kind.String())
+}
+
+func (pod *Pod) toPodTemplate(volumes []corev1.Volume) corev1.PodTemplateSpec {
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Labels:    pod.labels(),
+		},
+		Spec: corev1.PodSpec{
+			Containers: pod.containers(),
+			Volumes:    volumes,
+		},
+	}
+	pod.applyScheduling(&template.Spec)
+	pod.applyConfigHash(&template.ObjectMeta)
+	return template
+}
+
+func (pod *Pod) updatePodTemplate(template *corev1.PodTemplateSpec, volumes []corev1.Volume) {
+	if template.ObjectMeta.Labels == nil {
+		template.ObjectMeta.Labels = map[string]string{}
+	}
+	for k, v := range pod.labels() {
+		template.ObjectMeta.Labels[k] = v
+	}
+	template.Spec.Containers = pod.containers()
+	template.Spec.Volumes = volumes
+	pod.applyScheduling(&template.Spec)
+	pod.applyConfigHash(&template.ObjectMeta)
+}
+
+func (pod *Pod) objectMeta() v1.ObjectMeta {
+	return v1.ObjectMeta{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Labels:    pod.labels(),
+	}
+}
+
+func (pod *Pod) updateObjectMeta(meta *v1.ObjectMeta) {
+	if meta.Labels == nil {
+		meta.Labels = map[string]string{}
+	}
+	for k, v := range pod.labels() {
+		meta.Labels[k] = v
+	}
+}
+
+// isClaimTemplate StatefulSet 的 PVC 存储由 volumeClaimTemplates 为每个副本单独创建
+func (pod *Pod) isClaimTemplate(s types.Storage) bool {
+	return pod.workloadKind() == WorkloadStatefulSet && s.ToStorageType() == types.StoragePVC && len(s.Size) > 0
+}
+
+// toStatefulSetVolumes 排除由 volumeClaimTemplates 提供的卷
+func (pod *Pod) toStatefulSetVolumes() []corev1.Volume {
+	claims := map[string]bool{}
+	for _, s := range pod.Storage {
+		if pod.isClaimTemplate(s) {
+			claims[s.Name] = true
+		}
+	}
+	volumes := make([]corev1.Volume, 0)
+	for _, v := range pod.toVolumes() {
+		if !claims[v.Name] {
+			volumes = append(volumes, v)
+		}
+	}
+	return volumes
+}
+
+func (pod *Pod) toClaimTemplates() []corev1.PersistentVolumeClaim {
+	claims := make([]corev1.PersistentVolumeClaim, 0)
+	for _, s := range pod.Storage {
+		if !pod.isClaimTemplate(s) {
+			continue
+		}
+		ps := &PersistentStorage{Model: pod.Model, Storage: s}
+		pvc := ps.toPvc()
+		// 模板由控制器按副本创建 PVC，不能绑定到指定的 PV，空间也由 StatefulSet 决定
+		pvc.Namespace = ""
+		pvc.Spec.VolumeName = ""
+		claims = append(claims, *pvc)
+	}
+	return claims
+}
+
+// toHeadlessService StatefulSet 的 serviceName 指向的无头 Service，为每个副本提供稳定的 DNS 记录
+// 不带进程组标签，避免出现在进程组的 Service 列表中，随 StatefulSet 一起被回收
+func (pod *Pod) toHeadlessService(sts *appsv1.StatefulSet) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      sts.Spec.ServiceName,
+			Namespace: sts.Namespace,
+			OwnerReferences: []v1.OwnerReference{
+				*v1.NewControllerRef(sts, appsv1.SchemeGroupVersion.WithKind(WorkloadStatefulSet.String())),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			Selector:                 pod.toSelector(),
+			PublishNotReadyAddresses: true,
+		},
+	}
+}
+
+func (pod *Pod) toStatefulSet() *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: pod.objectMeta(),
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:             pod.replicas(),
+			ServiceName:          pod.Name,
+			Selector:             &v1.LabelSelector{MatchLabels: pod.toSelector()},
+			Template:             pod.toPodTemplate(pod.toStatefulSetVolumes()),
+			VolumeClaimTemplates: pod.toClaimTemplates(),
+		},
+	}
+}
+
+// updateStatefulSet volumeClaimTemplates 和 selector 创建后不可修改，保持原值
+func (pod *Pod) updateStatefulSet(sts *appsv1.StatefulSet) *appsv1.StatefulSet {
+	pod.updateObjectMeta(&sts.ObjectMeta)
+	if pod.Autoscaling == nil {
+		sts.Spec.Replicas = pod.replicas()
+	}
+	pod.updatePodTemplate(&sts.Spec.Template, pod.toStatefulSetVolumes())
+	return sts
+}
+
+func (pod *Pod) toDaemonSet() *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: pod.objectMeta(),
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &v1.LabelSelector{MatchLabels: pod.toSelector()},
+			Template: pod.toPodTemplate(pod.toVolumes()),
+		},
+	}
+}
+
+func (pod *Pod) updateDaemonSet(ds *appsv1.DaemonSet) *appsv1.DaemonSet {
+	pod.updateObjectMeta(&ds.ObjectMeta)
+	pod.updatePodTemplate(&ds.Spec.Template, pod.toVolumes())
+	return ds
+}
+
+// toWorkloadPod claims 为 StatefulSet 按副本创建的存储，需在还原容器挂载前加入
+func toWorkloadPod(kind WorkloadKind, meta v1.ObjectMeta, replicas *int32, template corev1.PodTemplateSpec, claims ...types.Storage) *Pod {
+	pod := &Pod{
+		Model:      Model{Namespace: meta.Namespace, Name: meta.Name},
+		Kind:       kind,
+		Containers: make([]*Container, 0),
+		Config:     make([]types.Config, 0),
+		Storage:    make([]types.Storage, 0),
+	}
+	if replicas != nil {
+		pod.Replicas = *replicas
+	}
+	spec := template.Spec
+	pod.ConfigHash = template.Annotations[AnnotationConfigHash]
+	pod.setLabels(meta.Labels)
+	pod.setVolumes(spec.Volumes)
+	pod.Storage = append(pod.Storage, claims...)
+	pod.setScheduling(spec)
+	for _, c := range spec.Containers {
+		pod.toContainer(c)
+	}
+	return pod
+}
+
+func toStatefulSetPod(sts appsv1.StatefulSet) *Pod {
+	claims := make([]types.Storage, 0, len(sts.Spec.VolumeClaimTemplates))
+	for _, claim := range sts.Spec.VolumeClaimTemplates {
+		s := types.Storage{
+			Name: claim.Name,
+			Type: types.StoragePVC,
+			Size: claim.Spec.Resources.Requests.Storage().String(),
+		}
+		if len(claim.Spec.AccessModes) > 0 {
+			s.AccessMode = types.AccessMode(claim.Spec.AccessModes[0])
+		}
+		if claim.Spec.StorageClassName != nil {
+			s.Item = *claim.Spec.StorageClassName
+		}
+		claims = append(claims, s)
+	}
+	pod := toWorkloadPod(WorkloadStatefulSet, sts.ObjectMeta, sts.Spec.Replicas, sts.Spec.Template, claims...)
+	pod.Status = Pending
+	if sts.Status.ReadyReplicas > 0 && sts.Status.ReadyReplicas >= pod.Replicas {
+		pod.Status = Running
+	}
+	if pod.Replicas == 0 {
+		pod.Status = Succeeded
+	}
+	return pod
+}
+
+func toDaemonSetPod(ds appsv1.DaemonSet) *Pod {
+	pod := toWorkloadPod(WorkloadDaemonSet, ds.ObjectMeta, nil, ds.Spec.Template)
+	pod.Replicas = ds.Status.DesiredNumberScheduled
+	pod.Status = Pending
+	if ds.Status.NumberReady > 0 && ds.Status.NumberReady >= ds.Status.DesiredNumberScheduled {
+		pod.Status = Running
+	}
+	return pod
+}
+
+// toOwnerKind 根据 Pod 的 OwnerReferences 判断所属工作负载类型
+func toOwnerKind(refs []v1.OwnerReference) WorkloadKind {
+	for _, ref := range refs {
+		switch ref.Kind {
+		case "ReplicaSet":
+			return WorkloadDeployment
+		case WorkloadStatefulSet.String():
+			return WorkloadStatefulSet
+		case WorkloadDaemonSet.String():
+			return WorkloadDaemonSet
+		case JobKind:
+			return WorkloadKind(JobKind)
+		}
+	}
+	return ""
+}
+
+// Workloads 按进程组读取 Deployment、StatefulSet 和 DaemonSet 定义
+func (o *podsOperation) Workloads(ctx context.Context, namespace string, groups ...string) ([]*Pod, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	pods, err := o.Deployments(ctx, namespace, groups...)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		groups = []string{""}
+	}
+	for _, g := range groups {
+		opts := toGroupListOptions(g)
+		sts, err := o.api.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取StatefulSet列表: namespace=%s, group=%s", namespace, g))
+		}
+		for _, s := range sts.Items {
+			pods = append(pods, toStatefulSetPod(s))
+		}
+		ds, err := o.api.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取DaemonSet列表: namespace=%s, group=%s", namespace, g))
+		}
+		for _, d := range ds.Items {
+			pods = append(pods, toDaemonSetPod(d))
+		}
+	}
+	return pods, nil
+}
+
+// workloadExists 检查进程组是否已有任一类型的工作负载
+func (o *podsOperation) workloadExists(ctx context.Context, namespace, group string) (bool, error) {
+	if o.isTest {
+		return false, nil
+	}
+	opts := toGroupListOptions(group)
+	opts.Limit = 1
+	operation := fmt.Sprintf("获取工作负载列表: namespace=%s, group=%s", namespace, group)
+	deployments, err := o.api.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return false, uerrors.WrapKubernetesError(ctx, err, operation)
+	}
+	if len(deployments.Items) > 0 {
+		return true, nil
+	}
+	sts, err := o.api.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return false, uerrors.WrapKubernetesError(ctx, err, operation)
+	}
+	if len(sts.Items) > 0 {
+		return true, nil
+	}
+	ds, err := o.api.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return false, uerrors.WrapKubernetesError(ctx, err, operation)
+	}
+	return len(ds.Items) > 0, nil
+}
+
+func (o *podsOperation) applyStatefulSet(ctx context.Context, pod *Pod) error {
+	if o.isTest {
+		return nil
+	}
+	api := o.api.AppsV1().StatefulSets(pod.Namespace)
+	data, err := api.Get(ctx, pod.Name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查StatefulSet是否存在: namespace=%s, group=%s", pod.Namespace, pod.Group))
+	if err != nil {
+		return err
+	}
+	if !has {
+		created, err := api.Create(ctx, pod.toStatefulSet(), v1.CreateOptions{})
+		if err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建StatefulSet: namespace=%s, group=%s", pod.Namespace, pod.Group))
+		}
+		return o.ensureHeadlessService(ctx, pod, created)
+	}
+	if !pod.AllowUpdate {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("StatefulSet已存在: namespace=%s, group=%s", pod.Namespace, pod.Group))
+	}
+	updated, err := api.Update(ctx, pod.updateStatefulSet(data), v1.UpdateOptions{})
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新StatefulSet: namespace=%s, group=%s", pod.Namespace, pod.Group))
+	}
+	return o.ensureHeadlessService(ctx, pod, updated)
+}
+
+// ensureHeadlessService 确保 serviceName 对应的 Service 存在，已存在同名 Service 时保持不变
+func (o *podsOperation) ensureHeadlessService(ctx context.Context, pod *Pod, sts *appsv1.StatefulSet) error {
+	name := sts.Spec.ServiceName
+	if len(name) < 1 {
+		return nil
+	}
+	api := o.api.CoreV1().Services(sts.Namespace)
+	svc, err := api.Get(ctx, name, v1.GetOptions{})
+	has, err := o.isExist(ctx, svc, err, fmt.Sprintf("检查Service是否存在: namespace=%s, service=%s", sts.Namespace, name))
+	if err != nil || has {
+		return err
+	}
+	if _, err := api.Create(ctx, pod.toHeadlessService(sts), v1.CreateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建无头Service: namespace=%s, service=%s", sts.Namespace, name))
+	}
+	return nil
+}
+
+func (o *podsOperation) applyDaemonSet(ctx context.Context, pod *Pod) error {
+	if o.isTest {
+		return nil
+	}
+	api := o.api.AppsV1().DaemonSets(pod.Namespace)
+	data, err := api.Get(ctx, pod.Name, v1.GetOptions{})
+	has, err := o.isExist(ctx, data, err, fmt.Sprintf("检查DaemonSet是否存在: namespace=%s, group=%s", pod.Namespace, pod.Group))
+	if err != nil {
+		return err
+	}
+	if !has {
+		if _, err := api.Create(ctx, pod.toDaemonSet(), v1.CreateOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("创建DaemonSet: namespace=%s, group=%s", pod.Namespace, pod.Group))
+		}
+		return nil
+	}
+	if !pod.AllowUpdate {
+		return uerrors.NewBizLogicError(uerrors.CodeResourceConflict,
+			fmt.Sprintf("DaemonSet已存在: namespace=%s, group=%s", pod.Namespace, pod.Group))
+	}
+	if _, err := api.Update(ctx, pod.updateDaemonSet(data), v1.UpdateOptions{}); err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("更新DaemonSet: namespace=%s, group=%s", pod.Namespace, pod.Group))
+	}
+	return nil
+}
+
+// deleteWorkloads 删除进程组下的 StatefulSet 和 DaemonSet，Deployment 由 deleteDeployment 处理
+func (o *podsOperation) deleteWorkloads(ctx context.Context, namespace, group string) error {
+	opts := toGroupListOptions(group)
+	sts, err := o.api.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取StatefulSet列表: namespace=%s, group=%s", namespace, group))
+	}
+	for _, s := range sts.Items {
+		if err := o.api.AppsV1().StatefulSets(namespace).Delete(ctx, s.Name, v1.DeleteOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除StatefulSet: namespace=%s, group=%s", namespace, group))
+		}
+	}
+	ds, err := o.api.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取DaemonSet列表: namespace=%s, group=%s", namespace, group))
+	}
+	for _, d := range ds.Items {
+		if err := o.api.AppsV1().DaemonSets(namespace).Delete(ctx, d.Name, v1.DeleteOptions{}); err != nil {
+			return uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("删除DaemonSet: namespace=%s, group=%s", namespace, group))
+		}
+	}
+	return nil
+}
+
+// sharedStorage 需要预先创建 PV/PVC 的存储，StatefulSet 的 PVC 由控制器按副本创建
+func (pg *ProcessGroupConfig) sharedStorage() []types.Storage {
+	if ToWorkloadKind(pg.Kind.String()) != WorkloadStatefulSet {
+		return pg.Storage
+	}
+	pod := &Pod{Kind: WorkloadStatefulSet}
+	list := make([]types.Storage, 0, len(pg.Storage))
+	for _, s := range pg.Storage {
+		if !pod.isClaimTemplate(s) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// setReplicas 调整工作负载的副本数，返回调整前的副本数
+// workloadKind 进程组已有工作负载的类型，不存在时返回空
+func (o *options) workloadKind(ctx context.Context, namespace, group string) (WorkloadKind, error) {
+	apps := o.api.AppsV1()
+	if _, err := apps.Deployments(namespace).Get(ctx, group, v1.GetOptions{}); err == nil {
+		return WorkloadDeployment, nil
+	} else if !errors.IsNotFound(err) {
+		return "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取Deployment: namespace=%s, name=%s", namespace, group))
+	}
+	if _, err := apps.StatefulSets(namespace).Get(ctx, group, v1.GetOptions{}); err == nil {
+		return WorkloadStatefulSet, nil
+	} else if !errors.IsNotFound(err) {
+		return "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取StatefulSet: namespace=%s, name=%s", namespace, group))
+	}
+	if _, err := apps.DaemonSets(namespace).Get(ctx, group, v1.GetOptions{}); err == nil {
+		return WorkloadDaemonSet, nil
+	} else if !errors.IsNotFound(err) {
+		return "", uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("获取DaemonSet: namespace=%s, name=%s", namespace, group))
+	}
+	return "", nil
+}
+
+func (o *options) setReplicas(ctx context.Context, namespace, group string, kind WorkloadKind, replicas int32) (int32, error) {
+	var previous int32
+	apps := o.api.AppsV1()
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		switch kind {
+		case WorkloadDeployment:
+			d, err := apps.Deployments(namespace).Get(ctx, group, v1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			previous = replicasOrDefault(d.Spec.Replicas)
+			d.Spec.Replicas = &replicas
+			_, err = apps.Deployments(namespace).Update(ctx, d, v1.UpdateOptions{})
+			return err
+		case WorkloadStatefulSet:
+			sts, err := apps.StatefulSets(namespace).Get(ctx, group, v1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			previous = replicasOrDefault(sts.Spec.Replicas)
+			sts.Spec.Replicas = &replicas
+			_, err = apps.StatefulSets(namespace).Update(ctx, sts, v1.UpdateOptions{})
+			return err
+		default:
+			return uerrors.NewValidationError("kind", "DaemonSet 不支持调整副本数")
+		}
+	})
+	if err != nil {
+		if _, ok := err.(*uerrors.BizError); ok {
+			return 0, err
+		}
+		return 0, uerrors.WrapKubernetesError(ctx, err, fmt.Sprintf("调整副本数: namespace=%s, group=%s, replicas=%d", namespace, group, replicas))
+	}
+	return previous, nil
+}
@@ -5,20 +5,32 @@ import (
 	"io"
 	"time"
 
+	"github.com/hosgf/element/health"
 	"github.com/hosgf/element/types"
 
 	"github.com/hosgf/element/model/process"
 	"github.com/hosgf/element/model/resource"
+	"k8s.io/client-go/rest"
 )
 
 type operation interface {
 	Init(homePath string) error
+	InitWithConfig(config *rest.Config) error
+	Region() string
 	Version() (string, error)
 	Nodes() nodesInterface
 	Namespace() namespaceInterface
 	Service() serviceInterface
+	Ingress() ingressInterface
 	Pod() podsInterface
 	Job() jobsInterface
+	Config() configInterface
+	Rollout() rolloutInterface
+	Autoscaler() autoscalerInterface
+	NetworkPolicy() networkPolicyInterface
+	Snapshot() snapshotInterface
+	Diagnosis() diagnosisInterface
+	Cache() cacheInterface
 	Storage() storageInterface
 	StorageResource() storageResourceInterface
 	Metrics() metricsInterface
@@ -36,8 +48,18 @@ type processInterface interface {
 	Restart(ctx context.Context, namespace, group, process string, cmd ...string) error
 	RestartGroup(ctx context.Context, namespace, group string) error
 	RestartApp(ctx context.Context, namespace, appname string) error
-	Command(ctx context.Context, namespace, group, process string, cmd ...string) (string, error)
+	Command(ctx context.Context, namespace, group, process string, cmd ...string) ([]*CommandResult, error)
+	Stream(ctx context.Context, namespace, pod, process string, opts ExecOptions, cmd ...string) error
+	PortForward(ctx context.Context, namespace, group, process string, localPort, remotePort int32, ports ...ForwardPort) (*PortForwarder, error)
+	CopyFrom(ctx context.Context, namespace, pod, process, remotePath, localPath string, opts CopyOptions) (*CopyProgress, error)
+	CopyTo(ctx context.Context, namespace, pod, process, localPath, remotePath string, opts CopyOptions) (*CopyProgress, error)
 	Logger(ctx context.Context, namespace, group, process string, config ProcessLogger) (io.ReadCloser, error)
+	WaitRollout(ctx context.Context, namespace, group string, opts RolloutOptions) (*RolloutStatus, error)
+	History(ctx context.Context, namespace, group string) ([]*Revision, error)
+	Rollback(ctx context.Context, namespace, group string, revision int64) error
+	Scale(ctx context.Context, namespace, group string, replicas int32) error
+	Snapshot(ctx context.Context, namespace, group, set string) ([]*Snapshot, error)
+	RestoreSnapshot(ctx context.Context, namespace, group, set string) error
 }
 
 type resourceInterface interface {
@@ -46,6 +68,14 @@ type resourceInterface interface {
 
 type nodesInterface interface {
 	Top(ctx context.Context) ([]*Node, error)
+	Get(ctx context.Context, name string) (*Node, error)
+	Cordon(ctx context.Context, name string) error
+	Uncordon(ctx context.Context, name string) error
+	Drain(ctx context.Context, name string, opts DrainOptions) (*DrainStatus, error)
+	SetLabels(ctx context.Context, name string, labels map[string]string) error
+	RemoveLabels(ctx context.Context, name string, keys ...string) error
+	AddTaints(ctx context.Context, name string, taints ...Taint) error
+	RemoveTaints(ctx context.Context, name string, taints ...Taint) error
 }
 
 type metricsInterface interface {
@@ -57,6 +87,11 @@ type namespaceInterface interface {
 	Exists(ctx context.Context, namespace string) (bool, error)
 	Apply(ctx context.Context, namespace, label string) (bool, error)
 	Delete(ctx context.Context, namespace string) error
+	ApplyQuota(ctx context.Context, namespace string, config QuotaConfig) error
+	ApplyLimitRange(ctx context.Context, namespace string, config LimitRangeConfig) error
+	DeleteQuota(ctx context.Context, namespace string) error
+	Quota(ctx context.Context, namespace string) (*NamespaceQuota, error)
+	Quotas(ctx context.Context, namespace string) ([]resource.Quota, error)
 }
 
 type serviceInterface interface {
@@ -67,9 +102,18 @@ type serviceInterface interface {
 	DeleteGroup(ctx context.Context, namespace string, groups ...string) error
 }
 
+type ingressInterface interface {
+	Apply(ctx context.Context, ingress *Ingress) error
+	BatchApply(ctx context.Context, ingresses []*Ingress) error
+	Sync(ctx context.Context, namespace, group string, ingresses []*Ingress) error
+	DeleteGroup(ctx context.Context, namespace string, groups ...string) error
+}
+
 type podsInterface interface {
 	Get(ctx context.Context, namespace, appname string) ([]*Pod, error)
 	List(ctx context.Context, namespace string, groups ...string) ([]*Pod, error)
+	Deployments(ctx context.Context, namespace string, groups ...string) ([]*Pod, error)
+	Workloads(ctx context.Context, namespace string, groups ...string) ([]*Pod, error)
 	Exists(ctx context.Context, namespace, pod string) (bool, error)
 	Apply(ctx context.Context, pod *Pod) error
 	Delete(ctx context.Context, namespace, pod string) error
@@ -77,8 +121,12 @@ type podsInterface interface {
 	Restart(ctx context.Context, namespace, pod string) error
 	RestartGroup(ctx context.Context, namespace, group string) error
 	RestartApp(ctx context.Context, namespace, appname string) error
-	Command(ctx context.Context, namespace, group, process string, cmd ...string) (string, error)
+	Command(ctx context.Context, namespace, group, process string, cmd ...string) ([]*CommandResult, error)
 	Exec(ctx context.Context, namespace, pod, process string, cmd ...string) (string, error)
+	Stream(ctx context.Context, namespace, pod, process string, opts ExecOptions, cmd ...string) error
+	PortForward(ctx context.Context, namespace, group, process string, localPort, remotePort int32, ports ...ForwardPort) (*PortForwarder, error)
+	CopyFrom(ctx context.Context, namespace, pod, process, remotePath, localPath string, opts CopyOptions) (*CopyProgress, error)
+	CopyTo(ctx context.Context, namespace, pod, process, localPath, remotePath string, opts CopyOptions) (*CopyProgress, error)
 	Logger(ctx context.Context, namespace, group, process string, config ProcessLogger) (io.ReadCloser, error)
 }
 
@@ -91,6 +139,73 @@ type podTemplatesInterface interface {
 }
 
 type jobsInterface interface {
+	Apply(ctx context.Context, config *JobConfig) error
+	List(ctx context.Context, namespace string, groups ...string) ([]*Job, error)
+	Get(ctx context.Context, namespace, name string) (*Job, error)
+	Delete(ctx context.Context, namespace, name string) error
+	Wait(ctx context.Context, namespace, name string, timeout time.Duration) (*Job, error)
+	WaitDeleted(ctx context.Context, namespace, name string, timeout time.Duration) error
+	Trigger(ctx context.Context, namespace, name string) (*Job, error)
+	Logger(ctx context.Context, namespace, name, process string, config ProcessLogger) (io.ReadCloser, error)
+}
+
+type configInterface interface {
+	BatchApply(ctx context.Context, model Model, configMaps []ConfigMapConfig, secrets []SecretConfig) error
+	ApplyConfigMap(ctx context.Context, config *ConfigMap) error
+	ApplySecret(ctx context.Context, config *Secret) error
+	DeleteGroup(ctx context.Context, namespace string, groups ...string) error
+}
+
+type rolloutInterface interface {
+	Status(ctx context.Context, namespace, group string) (*RolloutStatus, error)
+	Wait(ctx context.Context, namespace, group string, opts RolloutOptions) (*RolloutStatus, error)
+	History(ctx context.Context, namespace, group string) ([]*Revision, error)
+	Rollback(ctx context.Context, namespace, group string, revision int64) error
+}
+
+type autoscalerInterface interface {
+	Get(ctx context.Context, namespace, group string) (*Autoscaler, error)
+	List(ctx context.Context, namespace string, groups ...string) ([]*Autoscaler, error)
+	Apply(ctx context.Context, autoscaler *Autoscaler) error
+	DeleteGroup(ctx context.Context, namespace string, groups ...string) error
+	Scale(ctx context.Context, namespace, group string, replicas int32) error
+}
+
+type networkPolicyInterface interface {
+	Get(ctx context.Context, namespace, group string) (*NetworkPolicy, error)
+	List(ctx context.Context, namespace string, groups ...string) ([]*NetworkPolicy, error)
+	Apply(ctx context.Context, policy *NetworkPolicy) error
+	DeleteGroup(ctx context.Context, namespace string, groups ...string) error
+	DefaultDeny(ctx context.Context, namespace string, ingress, egress bool) error
+}
+
+type snapshotInterface interface {
+	Create(ctx context.Context, snapshot *Snapshot) error
+	CreateGroup(ctx context.Context, namespace, group, set, class string) ([]*Snapshot, error)
+	Get(ctx context.Context, namespace, name string) (*Snapshot, error)
+	List(ctx context.Context, namespace, group, set string) ([]*Snapshot, error)
+	Delete(ctx context.Context, namespace string, names ...string) error
+	DeleteSet(ctx context.Context, namespace, group, set string) error
+	CreateStorage(ctx context.Context, storage *PersistentStorage, snapshot string) error
+	Restore(ctx context.Context, namespace, group, set string, timeout time.Duration) error
+}
+
+type diagnosisInterface interface {
+	Get(ctx context.Context, namespace, group string) (*Diagnosis, error)
+}
+
+type cacheInterface interface {
+	Start(ctx context.Context, namespaces ...string) error
+	Stop(namespaces ...string)
+	Synced(namespace string) bool
+	Subscribe(handler CacheHandler) func()
+	Pods(ctx context.Context, namespace string, groups ...string) ([]*Pod, error)
+	Services(ctx context.Context, namespace string, groups ...string) ([]*Service, error)
+	Deployments(ctx context.Context, namespace string, groups ...string) ([]*Pod, error)
+	Workloads(ctx context.Context, namespace string, groups ...string) ([]*Pod, error)
+	Storages(ctx context.Context, namespace string, groups ...string) ([]*types.Storage, error)
+	Autoscalers(ctx context.Context, namespace string, groups ...string) ([]*Autoscaler, error)
+	Health(ctx context.Context, namespace, group string) (health.Health, error)
 }
 
 type storageInterface interface {
@@ -102,6 +217,8 @@ type storageInterface interface {
 	DeleteByGroup(ctx context.Context, delRes bool, namespace string, groups ...string) error
 	WaitDeleted(ctx context.Context, namespace, name string, timeout time.Duration) error
 	IsDeleting(ctx context.Context, namespace, name string) (bool, error)
+	Resize(ctx context.Context, namespace, name, size string, opts ResizeOptions) (*ResizeStatus, error)
+	WaitResize(ctx context.Context, namespace, name string, opts ResizeOptions) (*ResizeStatus, error)
 }
 
 type storageResourceInterface interface {
@@ -0,0 +1,134 @@
+package k8s
+
+import (
+	"strings"
+
+	"github.com/gogf/gf/v2/text/gstr"
+	"github.com/hosgf/element/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// 探针类型
+const (
+	ProbeExec = "exec"
+	ProbeHttp = "http"
+	ProbeTcp  = "tcp"
+)
+
+// 探针默认值，与 ProbeConfig 字段注释保持一致
+const (
+	DefaultProbeInitialDelaySeconds        = 300
+	DefaultStartupProbeInitialDelaySeconds = 0 // 启动探针本身用于等待慢启动，不再额外延迟
+	DefaultProbeTimeoutSeconds             = 10
+	DefaultProbePeriodSeconds              = 30
+	DefaultProbeSuccessThreshold           = 1
+	DefaultProbeFailureThreshold           = 3
+
+	// ProbeNoInitialDelay InitialDelaySeconds 取该值（或任意负数）时表示立即开始探测，0 表示使用默认值
+	ProbeNoInitialDelay = -1
+)
+
+// ProbesConfig 分别配置存活、就绪、启动探针，未配置时存活和就绪探针沿用 ProcessConfig.Probe
+type ProbesConfig struct {
+	Liveness  *ProbeConfig `json:"liveness,omitempty"`  // 存活探针，失败后重启容器
+	Readiness *ProbeConfig `json:"readiness,omitempty"` // 就绪探针，失败后从服务中摘除
+	Startup   *ProbeConfig `json:"startup,omitempty"`   // 启动探针，成功前不执行其他探针
+}
+
+// toProbe 转换为 Kubernetes 探针，未启用或配置不完整时返回 nil
+// initialDelay 为未设置 InitialDelaySeconds 时的默认值
+// mustSucceedOnce 为 true 时成功阈值固定为 1（存活和启动探针的要求）
+func (p *ProbeConfig) toProbe(initialDelay int, mustSucceedOnce bool) *corev1.Probe {
+	if p == nil || !p.Enabled {
+		return nil
+	}
+	handler := p.toHandler()
+	if handler == nil {
+		return nil
+	}
+	probe := &corev1.Probe{
+		ProbeHandler:        *handler,
+		InitialDelaySeconds: int32(initialDelay),
+		TimeoutSeconds:      int32(util.GetIntOrDefault(p.TimeoutSeconds, DefaultProbeTimeoutSeconds)),
+		PeriodSeconds:       int32(util.GetIntOrDefault(p.PeriodSeconds, DefaultProbePeriodSeconds)),
+		SuccessThreshold:    int32(util.GetIntOrDefault(p.SuccessThreshold, DefaultProbeSuccessThreshold)),
+		FailureThreshold:    int32(util.GetIntOrDefault(p.FailureThreshold, DefaultProbeFailureThreshold)),
+	}
+	switch {
+	case p.InitialDelaySeconds < 0:
+		probe.InitialDelaySeconds = 0
+	case p.InitialDelaySeconds > 0:
+		probe.InitialDelaySeconds = int32(p.InitialDelaySeconds)
+	}
+	if mustSucceedOnce {
+		probe.SuccessThreshold = 1
+	}
+	return probe
+}
+
+func (p *ProbeConfig) toHandler() *corev1.ProbeHandler {
+	switch gstr.ToLower(p.ProbeType) {
+	case ProbeExec:
+		if len(p.ExecCommand) < 1 {
+			return nil
+		}
+		return &corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", p.ExecCommand}}}
+	case ProbeHttp:
+		if p.HttpGetPort < 1 {
+			return nil
+		}
+		return &corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+			Path: p.HttpGetPath,
+			Port: intstr.FromInt32(int32(p.HttpGetPort)),
+		}}
+	case ProbeTcp:
+		if p.TcpSocketPort < 1 {
+			return nil
+		}
+		return &corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(int32(p.TcpSocketPort))}}
+	default:
+		return nil
+	}
+}
+
+// toProbeConfig 从 Kubernetes 探针还原配置
+func toProbeConfig(probe *corev1.Probe) *ProbeConfig {
+	if probe == nil {
+		return nil
+	}
+	p := &ProbeConfig{
+		Enabled:             true,
+		InitialDelaySeconds: int(probe.InitialDelaySeconds),
+		TimeoutSeconds:      int(probe.TimeoutSeconds),
+		PeriodSeconds:       int(probe.PeriodSeconds),
+		SuccessThreshold:    int(probe.SuccessThreshold),
+		FailureThreshold:    int(probe.FailureThreshold),
+	}
+	if probe.InitialDelaySeconds == 0 {
+		// 还原为显式的不延迟，再次下发时不会被默认值覆盖
+		p.InitialDelaySeconds = ProbeNoInitialDelay
+	}
+	switch {
+	case probe.Exec != nil:
+		p.ProbeType = ProbeExec
+		p.ExecCommand = toExecCommand(probe.Exec.Command)
+	case probe.HTTPGet != nil:
+		p.ProbeType = ProbeHttp
+		p.HttpGetPath = probe.HTTPGet.Path
+		p.HttpGetPort = probe.HTTPGet.Port.IntValue()
+	case probe.TCPSocket != nil:
+		p.ProbeType = ProbeTcp
+		p.TcpSocketPort = probe.TCPSocket.Port.IntValue()
+	default:
+		return nil
+	}
+	return p
+}
+
+func toExecCommand(cmd []string) string {
+	if len(cmd) == 3 && (cmd[0] == "/bin/sh" || cmd[0] == "sh" || cmd[0] == "/bin/bash") && cmd[1] == "-c" {
+		return cmd[2]
+	}
+	return strings.Join(cmd, " ")
+}
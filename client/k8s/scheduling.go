@@ -0,0 +1,368 @@
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	LabelHostname      = "kubernetes.io/hostname"
+	DefaultTopologyKey = LabelHostname
+)
+
+// SchedulingConfig 进程组调度约束
+type SchedulingConfig struct {
+	NodeSelector    map[string]string `json:"nodeSelector,omitempty"`    // 节点标签选择
+	NodeAffinity    []NodeAffinity    `json:"nodeAffinity,omitempty"`    // 节点亲和性
+	PodAffinity     []PodAffinity     `json:"podAffinity,omitempty"`     // Pod 亲和性
+	PodAntiAffinity []PodAffinity     `json:"podAntiAffinity,omitempty"` // Pod 反亲和性，Labels 为空时表示同一进程组，可用于副本打散
+	Tolerations     []Toleration      `json:"tolerations,omitempty"`     // 污点容忍
+	TopologySpread  []TopologySpread  `json:"topologySpread,omitempty"`  // 拓扑分布约束
+}
+
+// NodeAffinity 节点亲和性条件
+type NodeAffinity struct {
+	Key      string   `json:"key,omitempty"`      // 节点标签
+	Operator string   `json:"operator,omitempty"` // In NotIn Exists DoesNotExist Gt Lt，默认 In
+	Values   []string `json:"values,omitempty"`   // 标签值
+	Required bool     `json:"required,omitempty"` // 是否强制，否则为优先
+	Weight   int32    `json:"weight,omitempty"`   // 优先权重 1-100，默认 100
+}
+
+// PodAffinity Pod 亲和/反亲和条件
+type PodAffinity struct {
+	Labels      map[string]string `json:"labels,omitempty"`      // 目标 Pod 标签，为空时为本进程组
+	Namespaces  []string          `json:"namespaces,omitempty"`  // 目标 Pod 所在空间，为空时为本空间
+	TopologyKey string            `json:"topologyKey,omitempty"` // 拓扑域，默认 kubernetes.io/hostname
+	Required    bool              `json:"required,omitempty"`    // 是否强制，否则为优先
+	Weight      int32             `json:"weight,omitempty"`      // 优先权重 1-100，默认 100
+}
+
+// Toleration 污点容忍
+type Toleration struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator,omitempty"` // Exists Equal，默认 Equal
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty"` // NoSchedule PreferNoSchedule NoExecute，为空表示全部
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+// TopologySpread 拓扑分布约束
+type TopologySpread struct {
+	TopologyKey       string            `json:"topologyKey,omitempty"`       // 拓扑域，默认 kubernetes.io/hostname
+	MaxSkew           int32             `json:"maxSkew,omitempty"`           // 允许的最大偏差，默认 1
+	WhenUnsatisfiable string            `json:"whenUnsatisfiable,omitempty"` // DoNotSchedule ScheduleAnyway，默认 ScheduleAnyway
+	Labels            map[string]string `json:"labels,omitempty"`            // 参与计算的 Pod 标签，为空时为本进程组
+}
+
+// applyScheduling 将运行节点、镜像拉取密钥和调度约束写入 PodSpec
+func (pod *Pod) applyScheduling(spec *corev1.PodSpec) {
+	spec.NodeSelector = pod.toNodeSelector()
+	spec.ImagePullSecrets = pod.toImagePullSecrets()
+	spec.Affinity = nil
+	spec.Tolerations = nil
+	spec.TopologySpreadConstraints = nil
+	s := pod.Scheduling
+	if s == nil {
+		return
+	}
+	spec.Affinity = s.toAffinity(pod.toSelector())
+	spec.Tolerations = s.toTolerations()
+	spec.TopologySpreadConstraints = s.toTopologySpread(pod.toSelector())
+}
+
+// setScheduling 从 PodSpec 还原运行节点、镜像拉取密钥和调度约束
+func (pod *Pod) setScheduling(spec corev1.PodSpec) {
+	for _, s := range spec.ImagePullSecrets {
+		pod.Secrets = append(pod.Secrets, s.Name)
+	}
+	nodeSelector := make(map[string]string, len(spec.NodeSelector))
+	for k, v := range spec.NodeSelector {
+		if k == LabelHostname {
+			if len(pod.RunningNode) < 1 {
+				pod.RunningNode = v
+			}
+			continue
+		}
+		nodeSelector[k] = v
+	}
+	s := &SchedulingConfig{NodeSelector: nodeSelector}
+	s.setAffinity(spec.Affinity)
+	s.setTolerations(spec.Tolerations)
+	s.setTopologySpread(spec.TopologySpreadConstraints)
+	if len(s.NodeSelector) == 0 {
+		s.NodeSelector = nil
+	}
+	if s.isEmpty() {
+		return
+	}
+	pod.Scheduling = s
+}
+
+func (pod *Pod) toNodeSelector() map[string]string {
+	selector := map[string]string{}
+	if pod.Scheduling != nil {
+		for k, v := range pod.Scheduling.NodeSelector {
+			selector[k] = v
+		}
+	}
+	if len(pod.RunningNode) > 0 {
+		selector[LabelHostname] = pod.RunningNode
+	}
+	if len(selector) == 0 {
+		return nil
+	}
+	return selector
+}
+
+func (pod *Pod) toImagePullSecrets() []corev1.LocalObjectReference {
+	if len(pod.Secrets) == 0 {
+		return nil
+	}
+	secrets := make([]corev1.LocalObjectReference, 0, len(pod.Secrets))
+	for _, name := range pod.Secrets {
+		if len(name) > 0 {
+			secrets = append(secrets, corev1.LocalObjectReference{Name: name})
+		}
+	}
+	return secrets
+}
+
+func (s *SchedulingConfig) isEmpty() bool {
+	return len(s.NodeSelector) == 0 && len(s.NodeAffinity) == 0 && len(s.PodAffinity) == 0 &&
+		len(s.PodAntiAffinity) == 0 && len(s.Tolerations) == 0 && len(s.TopologySpread) == 0
+}
+
+func (s *SchedulingConfig) toAffinity(selector map[string]string) *corev1.Affinity {
+	affinity := &corev1.Affinity{
+		NodeAffinity:    toNodeAffinity(s.NodeAffinity),
+		PodAffinity:     nil,
+		PodAntiAffinity: nil,
+	}
+	if required, preferred := toPodAffinityTerms(s.PodAffinity, selector); len(required)+len(preferred) > 0 {
+		affinity.PodAffinity = &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  required,
+			PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+		}
+	}
+	if required, preferred := toPodAffinityTerms(s.PodAntiAffinity, selector); len(required)+len(preferred) > 0 {
+		affinity.PodAntiAffinity = &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution:  required,
+			PreferredDuringSchedulingIgnoredDuringExecution: preferred,
+		}
+	}
+	if affinity.NodeAffinity == nil && affinity.PodAffinity == nil && affinity.PodAntiAffinity == nil {
+		return nil
+	}
+	return affinity
+}
+
+func (s *SchedulingConfig) setAffinity(affinity *corev1.Affinity) {
+	if affinity == nil {
+		return
+	}
+	if na := affinity.NodeAffinity; na != nil {
+		if required := na.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+			for _, term := range required.NodeSelectorTerms {
+				for _, e := range term.MatchExpressions {
+					s.NodeAffinity = append(s.NodeAffinity, NodeAffinity{
+						Key: e.Key, Operator: string(e.Operator), Values: e.Values, Required: true,
+					})
+				}
+			}
+		}
+		for _, term := range na.PreferredDuringSchedulingIgnoredDuringExecution {
+			for _, e := range term.Preference.MatchExpressions {
+				s.NodeAffinity = append(s.NodeAffinity, NodeAffinity{
+					Key: e.Key, Operator: string(e.Operator), Values: e.Values, Weight: term.Weight,
+				})
+			}
+		}
+	}
+	if pa := affinity.PodAffinity; pa != nil {
+		s.PodAffinity = toPodAffinity(pa.RequiredDuringSchedulingIgnoredDuringExecution, pa.PreferredDuringSchedulingIgnoredDuringExecution)
+	}
+	if pa := affinity.PodAntiAffinity; pa != nil {
+		s.PodAntiAffinity = toPodAffinity(pa.RequiredDuringSchedulingIgnoredDuringExecution, pa.PreferredDuringSchedulingIgnoredDuringExecution)
+	}
+}
+
+func toNodeAffinity(list []NodeAffinity) *corev1.NodeAffinity {
+	if len(list) == 0 {
+		return nil
+	}
+	var (
+		required  []corev1.NodeSelectorRequirement
+		preferred []corev1.PreferredSchedulingTerm
+	)
+	for _, a := range list {
+		if len(a.Key) < 1 {
+			continue
+		}
+		requirement := corev1.NodeSelectorRequirement{
+			Key:      a.Key,
+			Operator: corev1.NodeSelectorOperator(a.operator()),
+			Values:   a.Values,
+		}
+		if a.Required {
+			required = append(required, requirement)
+			continue
+		}
+		preferred = append(preferred, corev1.PreferredSchedulingTerm{
+			Weight:     weightOrDefault(a.Weight),
+			Preference: corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{requirement}},
+		})
+	}
+	if len(required) == 0 && len(preferred) == 0 {
+		return nil
+	}
+	na := &corev1.NodeAffinity{PreferredDuringSchedulingIgnoredDuringExecution: preferred}
+	if len(required) > 0 {
+		// 同一个 NodeSelectorTerm 内的条件为"与"关系
+		na.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: required}},
+		}
+	}
+	return na
+}
+
+func (a NodeAffinity) operator() string {
+	if len(a.Operator) < 1 {
+		return string(corev1.NodeSelectorOpIn)
+	}
+	return a.Operator
+}
+
+func toPodAffinityTerms(list []PodAffinity, selector map[string]string) ([]corev1.PodAffinityTerm, []corev1.WeightedPodAffinityTerm) {
+	var (
+		required  []corev1.PodAffinityTerm
+		preferred []corev1.WeightedPodAffinityTerm
+	)
+	for _, a := range list {
+		labels := a.Labels
+		if len(labels) == 0 {
+			labels = selector
+		}
+		term := corev1.PodAffinityTerm{
+			LabelSelector: &v1.LabelSelector{MatchLabels: labels},
+			Namespaces:    a.Namespaces,
+			TopologyKey:   topologyKeyOrDefault(a.TopologyKey),
+		}
+		if a.Required {
+			required = append(required, term)
+			continue
+		}
+		preferred = append(preferred, corev1.WeightedPodAffinityTerm{Weight: weightOrDefault(a.Weight), PodAffinityTerm: term})
+	}
+	return required, preferred
+}
+
+func toPodAffinity(required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm) []PodAffinity {
+	list := make([]PodAffinity, 0, len(required)+len(preferred))
+	for _, term := range required {
+		list = append(list, PodAffinity{
+			Labels:      matchLabels(term.LabelSelector),
+			Namespaces:  term.Namespaces,
+			TopologyKey: term.TopologyKey,
+			Required:    true,
+		})
+	}
+	for _, term := range preferred {
+		list = append(list, PodAffinity{
+			Labels:      matchLabels(term.PodAffinityTerm.LabelSelector),
+			Namespaces:  term.PodAffinityTerm.Namespaces,
+			TopologyKey: term.PodAffinityTerm.TopologyKey,
+			Weight:      term.Weight,
+		})
+	}
+	return list
+}
+
+func (s *SchedulingConfig) toTolerations() []corev1.Toleration {
+	if len(s.Tolerations) == 0 {
+		return nil
+	}
+	tolerations := make([]corev1.Toleration, 0, len(s.Tolerations))
+	for _, t := range s.Tolerations {
+		tolerations = append(tolerations, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	return tolerations
+}
+
+func (s *SchedulingConfig) setTolerations(tolerations []corev1.Toleration) {
+	for _, t := range tolerations {
+		s.Tolerations = append(s.Tolerations, Toleration{
+			Key:               t.Key,
+			Operator:          string(t.Operator),
+			Value:             t.Value,
+			Effect:            string(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+}
+
+func (s *SchedulingConfig) toTopologySpread(selector map[string]string) []corev1.TopologySpreadConstraint {
+	if len(s.TopologySpread) == 0 {
+		return nil
+	}
+	constraints := make([]corev1.TopologySpreadConstraint, 0, len(s.TopologySpread))
+	for _, t := range s.TopologySpread {
+		labels := t.Labels
+		if len(labels) == 0 {
+			labels = selector
+		}
+		maxSkew := t.MaxSkew
+		if maxSkew < 1 {
+			maxSkew = 1
+		}
+		when := corev1.ScheduleAnyway
+		if len(t.WhenUnsatisfiable) > 0 {
+			when = corev1.UnsatisfiableConstraintAction(t.WhenUnsatisfiable)
+		}
+		constraints = append(constraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           maxSkew,
+			TopologyKey:       topologyKeyOrDefault(t.TopologyKey),
+			WhenUnsatisfiable: when,
+			LabelSelector:     &v1.LabelSelector{MatchLabels: labels},
+		})
+	}
+	return constraints
+}
+
+func (s *SchedulingConfig) setTopologySpread(constraints []corev1.TopologySpreadConstraint) {
+	for _, c := range constraints {
+		s.TopologySpread = append(s.TopologySpread, TopologySpread{
+			TopologyKey:       c.TopologyKey,
+			MaxSkew:           c.MaxSkew,
+			WhenUnsatisfiable: string(c.WhenUnsatisfiable),
+			Labels:            matchLabels(c.LabelSelector),
+		})
+	}
+}
+
+func matchLabels(selector *v1.LabelSelector) map[string]string {
+	if selector == nil {
+		return nil
+	}
+	return selector.MatchLabels
+}
+
+func topologyKeyOrDefault(key string) string {
+	if len(key) < 1 {
+		return DefaultTopologyKey
+	}
+	return key
+}
+
+func weightOrDefault(weight int32) int32 {
+	if weight < 1 || weight > 100 {
+		return 100
+	}
+	return weight
+}
@@ -50,20 +50,35 @@ type ProcessLogger struct {
 	// +featureGate=PodLogsQuerySplitStreams
 	// +optional
 	Stream *LoggerOutputType `json:"stream,omitempty"`
+
+	// Format of the merged group log, "text" prefixes every line with [pod/process],
+	// "json" writes one LoggerLine object per line. Defaults to "text".
+	// +optional
+	Format LoggerFormat `json:"format,omitempty"`
+
+	// Grep only returns the lines matching the regular expression.
+	// +optional
+	Grep string `json:"grep,omitempty"`
 }
 
 // ProcessGroupConfig 进程组配置对象
 type ProcessGroupConfig struct {
-	Namespace   string          `json:"namespace,omitempty"`   // 运行进程的资源空间
-	GroupName   string          `json:"groupName,omitempty"`   // 进程组名称
-	Labels      types.Labels    `json:"labels,omitempty"`      // 进程组标签
-	RunningNode string          `json:"runningNode,omitempty"` // 运行节点,可为空
-	Replicas    int32           `json:"replicas,omitempty"`    // 节点数，以进程组为纬度 默认为 1
-	AllowUpdate bool            `json:"allowUpdate,omitempty"` // 是否允许更新,进程存在则更新
-	Secret      string          `json:"secret,omitempty"`      // pull镜像时使用的secret
-	Config      []types.Config  `json:"config,omitempty"`      // 配置信息
-	Storage     []types.Storage `json:"storage,omitempty"`     // 存储,以进程组的维度来定义,进程通过挂载与存储关联
-	Process     []ProcessConfig `json:"process,omitempty"`     // 进程组下的进程信息
+	Namespace   string             `json:"namespace,omitempty"`   // 运行进程的资源空间
+	GroupName   string             `json:"groupName,omitempty"`   // 进程组名称
+	Kind        WorkloadKind       `json:"kind,omitempty"`        // 工作负载类型 Deployment StatefulSet DaemonSet,默认 Deployment
+	Labels      types.Labels       `json:"labels,omitempty"`      // 进程组标签
+	RunningNode string             `json:"runningNode,omitempty"` // 运行节点,可为空
+	Replicas    int32              `json:"replicas,omitempty"`    // 节点数，以进程组为纬度 默认为 1
+	Autoscaling *AutoscalingConfig `json:"autoscaling,omitempty"` // 自动伸缩配置,配置后副本数由 HPA 管理
+	AllowUpdate bool               `json:"allowUpdate,omitempty"` // 是否允许更新,进程存在则更新
+	Secret      string             `json:"secret,omitempty"`      // pull镜像时使用的secret,多个以逗号分隔
+	Scheduling  *SchedulingConfig  `json:"scheduling,omitempty"`  // 调度约束,可为空
+	Network     *NetworkConfig     `json:"network,omitempty"`     // 网络访问控制,可为空
+	Config      []types.Config     `json:"config,omitempty"`      // 配置信息
+	ConfigMaps  []ConfigMapConfig  `json:"configMaps,omitempty"`  // 配置文件,以 ConfigMap 下发
+	Secrets     []SecretConfig     `json:"secrets,omitempty"`     // 密文配置,以 Secret 下发
+	Storage     []types.Storage    `json:"storage,omitempty"`     // 存储,以进程组的维度来定义,进程通过挂载与存储关联
+	Process     []ProcessConfig    `json:"process,omitempty"`     // 进程组下的进程信息
 }
 
 func (pg *ProcessGroupConfig) toModel() Model {
@@ -117,7 +132,9 @@ type ProcessConfig struct {
 	Resource    []process.Resource  `json:"resource,omitempty"`    // 进程运行所需的资源
 	Env         []types.Environment `json:"env,omitempty"`         // 环境变量
 	Mounts      []types.Mount       `json:"mounts,omitempty"`      // 卷挂载
-	Probe       ProbeConfig         `json:"probe,omitempty"`       // 探针
+	Probe       ProbeConfig         `json:"probe,omitempty"`       // 探针，作为存活和就绪探针的默认配置
+	Probes      ProbesConfig        `json:"probes,omitempty"`      // 分类型的探针配置
+	Expose      *ExposeConfig       `json:"expose,omitempty"`      // HTTP 对外暴露,可为空
 }
 
 func (p *ProcessConfig) toMounts(pg *ProcessGroupConfig) {
@@ -129,6 +146,7 @@ func (p *ProcessConfig) toMounts(pg *ProcessGroupConfig) {
 		cmap[c.Name] = c.Path
 	}
 	p.toConfigMounts(cmap, pg)
+	p.toConfigMapMounts(cmap, pg)
 	p.toStorageMounts(cmap, pg)
 }
 
@@ -228,7 +246,7 @@ type ProbeConfig struct {
 	HttpGetPath         string `json:"httpGetPath" v:"required-if:probeType,http"`  // api路径
 	HttpGetPort         int    `json:"httpGetPort" v:"required-if:probeType,http"`  // 端口号
 	TcpSocketPort       int    `json:"tcpSocketPort" v:"required-if:probeType,tcp"` // 端口号
-	InitialDelaySeconds int    `json:"initialDelaySeconds"`                         // 容器启动后多久开始探测 默认值 300
+	InitialDelaySeconds int    `json:"initialDelaySeconds"`                         // 容器启动后多久开始探测 默认值 300，启动探针默认值 0，小于 0 表示不延迟
 	TimeoutSeconds      int    `json:"timeoutSeconds"`                              // 表示容器必须在多少秒内做出相应反馈给probe，否则视为探测失败 默认值 10
 	PeriodSeconds       int    `json:"periodSeconds"`                               // 探测周期，每多少秒探测一次 默认值 30
 	SuccessThreshold    int    `json:"successThreshold"`                            // 连续探测几次成功表示成功 默认值 1
@@ -362,7 +380,11 @@ func GetOutputTypeOrDefault(a *LoggerOutputType, b LoggerOutputType) *string {
 type StorageResourceType string
 
 const (
-	StorageResourceRBD StorageResourceType = "RBD"
+	StorageResourceRBD    StorageResourceType = "RBD"
+	StorageResourceNFS    StorageResourceType = "NFS"
+	StorageResourceCephFS StorageResourceType = "CephFS"
+	StorageResourceLocal  StorageResourceType = "Local" // 节点本地磁盘，需要指定所在节点
+	StorageResourceCSI    StorageResourceType = "CSI"
 )
 
 func (t StorageResourceType) String() string {
@@ -370,8 +392,18 @@ func (t StorageResourceType) String() string {
 }
 
 func ToStorageResourceType(t string) StorageResourceType {
-	if t == "" {
+	switch strings.ToLower(t) {
+	case "", "rbd":
 		return StorageResourceRBD
+	case "nfs":
+		return StorageResourceNFS
+	case "cephfs":
+		return StorageResourceCephFS
+	case "local":
+		return StorageResourceLocal
+	case "csi":
+		return StorageResourceCSI
+	default:
+		return StorageResourceType(t)
 	}
-	return StorageResourceType(t)
 }
@@ -17,6 +17,7 @@ type Resource struct {
 	Time      int64         `json:"time,omitempty"`
 	Remark    string        `json:"remark,omitempty"`
 	Nodes     []Node        `json:"nodes,omitempty"`
+	Quotas    []Quota       `json:"quotas,omitempty"` // 命名空间的资源配额及使用量
 }
 
 func (r *Resource) ToResourceItem() Resource {
@@ -27,6 +28,7 @@ func (r *Resource) ToResourceItem() Resource {
 		Status: r.Status,
 		Time:   r.Time,
 		Nodes:  r.Nodes,
+		Quotas: r.Quotas,
 	}
 }
 
@@ -93,12 +95,20 @@ func (r *Resource) SetStatus() *Resource {
 }
 
 type Node struct {
-	Name       string                 `json:"name"`
-	Roles      string                 `json:"roles"`
-	Status     health.Health          `json:"status"`
-	Time       int64                  `json:"time"`
-	Indicators map[string]interface{} `json:"indicators"`
-	Details    map[string]interface{} `json:"details"`
+	Name        string                 `json:"name"`
+	Roles       string                 `json:"roles"`
+	Status      health.Health          `json:"status"`
+	Schedulable bool                   `json:"schedulable"` // 是否可调度，封锁后为 false
+	Time        int64                  `json:"time"`
+	Indicators  map[string]interface{} `json:"indicators"`
+	Details     map[string]interface{} `json:"details"`
+}
+
+// Quota 命名空间的资源配额，Items 的键为配额项，如 requests.cpu limits.memory pods
+type Quota struct {
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	Items     map[string]Details `json:"items,omitempty"`
 }
 
 type Details struct {
@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/util/grand"
+	"github.com/hosgf/element/config"
+)
+
+// AuthConfig 注册通道的认证配置，Token 与 Secret 二选一，同时配置时优先使用 Secret 签名
+type AuthConfig struct {
+	Enabled bool   `json:"enabled"`
+	AppCode string `json:"appCode"` // 为空时使用 config.AppCode
+	Token   string `json:"token"`   // 静态令牌
+	Secret  string `json:"secret"`  // HMAC-SHA256 签名密钥
+}
+
+// AuthPayload 认证报文内容
+type AuthPayload struct {
+	AppCode   string `json:"appCode"`
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+	Token     string `json:"token,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// AuthMessageHandler 可选接口，MessageHandler 实现后可接收服务端的认证应答
+type AuthMessageHandler interface {
+	HandleReplyAuthData(ctx context.Context, data string)
+}
+
+func (c *AuthConfig) appCode() string {
+	if len(c.AppCode) > 0 {
+		return c.AppCode
+	}
+	return config.AppCode
+}
+
+// NewAuthPayload 生成一次性的认证内容
+func (c *AuthConfig) NewAuthPayload() AuthPayload {
+	payload := AuthPayload{
+		AppCode:   c.appCode(),
+		Timestamp: time.Now().UnixMilli(),
+		Nonce:     grand.S(16),
+	}
+	if len(c.Secret) > 0 {
+		payload.Signature = Sign(c.Secret, payload.AppCode, payload.Timestamp, payload.Nonce)
+		return payload
+	}
+	payload.Token = c.Token
+	return payload
+}
+
+// Sign 计算 HMAC-SHA256(secret, appCode + timestamp + nonce) 的十六进制签名
+func Sign(secret, appCode string, timestamp int64, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(appCode))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySign 校验签名，供服务端使用
+func VerifySign(secret string, payload AuthPayload) bool {
+	expected := Sign(secret, payload.AppCode, payload.Timestamp, payload.Nonce)
+	return hmac.Equal([]byte(expected), []byte(payload.Signature))
+}
+
+func NewAuthMessage(auth *AuthConfig) Message {
+	msg := Message{
+		MagicNumber: MagicNumber,
+		MessageType: MessageTypeAUTH.ToInt32(),
+		LogId:       DefaultLogId,
+	}
+	msg.SetMessageBodyData(gjson.MustEncodeString(auth.NewAuthPayload()))
+	return msg
+}
@@ -3,12 +3,16 @@ package registry
 import (
 	"context"
 	"encoding/binary"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-netty/go-netty"
 	"github.com/go-netty/go-netty/codec/frame"
 	"github.com/go-netty/go-netty/transport"
 	"github.com/go-netty/go-netty/transport/tcp"
+	"github.com/hosgf/element/logger"
+	"github.com/hosgf/element/uerrors"
 )
 
 type Client struct {
@@ -17,6 +21,11 @@ type Client struct {
 	trigger   *triggerHandler
 	bootstrap netty.Bootstrap
 	channel   netty.Channel
+	queue     *outboundQueue
+	endpoints *endpointSelector
+	switching atomic.Bool
+	mutex     sync.RWMutex
+	err       error
 }
 
 func NewClient(ctx context.Context, config *ClientConfig) *Client {
@@ -24,7 +33,18 @@ func NewClient(ctx context.Context, config *ClientConfig) *Client {
 	if !config.Enabled {
 		return c
 	}
+	factory, err := newTransport(config)
+	if err != nil {
+		c.err = err
+		return c
+	}
 	c.trigger = newTriggerHandler(c)
+	c.queue = newOutboundQueue(config.Queue)
+	go c.queue.run(ctx, c)
+	c.endpoints = newEndpointSelector(config)
+	if len(c.endpoints.endpoints) > 1 && c.endpoints.strategy != StrategyRandom {
+		go c.failback(ctx)
+	}
 	clientInitializer := func(channel netty.Channel) {
 		pipeline := channel.Pipeline()
 		pipeline.
@@ -36,30 +56,123 @@ func NewClient(ctx context.Context, config *ClientConfig) *Client {
 			pipeline.AddLast(&config.Handler)
 		}
 	}
-	c.bootstrap = netty.NewBootstrap(netty.WithClientInitializer(clientInitializer), netty.WithTransport(tcp.New()))
+	c.bootstrap = netty.NewBootstrap(netty.WithClientInitializer(clientInitializer), netty.WithTransport(factory))
 	return c
 }
 
 func (c *Client) Run(retries bool) error {
-	ch, err := c.bootstrap.Connect(c.config.Address, transport.WithContext(c.ctx), transport.WithAttachment(c.config.Name))
-	c.channel = ch
+	if c.err != nil {
+		return c.err
+	}
+	address := c.endpoints.address()
+	if len(address) < 1 {
+		return uerrors.NewValidationError("address", "请配置注册服务地址")
+	}
+	ch, err := c.bootstrap.Connect(address, transport.WithContext(c.ctx), transport.WithAttachment(c.config.Name))
 	if err == nil {
+		c.setChannel(ch)
 		return nil
 	}
+	if next := c.endpoints.failed(); next != address {
+		logger.Warningf(c.ctx, "注册节点 %s 连接失败: %v，切换到 %s", address, err, next)
+	}
 	if retries {
 		c.trigger.retries(c.ctx)
 	}
 	return err
 }
 
+// SendData 将业务报文放入发送队列，通道可用时按顺序发送
 func (c *Client) SendData(ctx context.Context, data string) error {
-	if c.channel.IsActive() {
-		message := NewBizMessage(data)
-		return c.write(&message)
+	return c.SendDataWithCallback(ctx, data, nil)
+}
+
+// SendDataWithCallback 同 SendData，报文写入通道或被丢弃时回调 callback
+func (c *Client) SendDataWithCallback(ctx context.Context, data string, callback DeliveryCallback) error {
+	if c.err != nil {
+		return c.err
 	}
-	return nil
+	if c.queue == nil {
+		return nil
+	}
+	return c.queue.offer(ctx, &pendingMessage{message: NewBizMessage(data), callback: callback})
+}
+
+// Endpoints 返回所有注册节点的当前状态
+func (c *Client) Endpoints() []Endpoint {
+	if c.endpoints == nil {
+		return nil
+	}
+	return c.endpoints.snapshot()
+}
+
+// failback 定期探测其他节点，存在更优节点时主动断开并切换过去
+func (c *Client) failback(ctx context.Context) {
+	interval := c.config.FailbackInterval
+	if interval <= 0 {
+		interval = DefaultFailbackInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.isActive() {
+				continue
+			}
+			c.endpoints.probe(ctx, tcp.DefaultOption.Timeout)
+			index := c.endpoints.preferred()
+			if index < 0 {
+				continue
+			}
+			logger.Infof(ctx, "注册节点切换: %s -> %s", c.endpoints.address(), c.endpoints.addressOf(index))
+			c.switchTo(index)
+		}
+	}
+}
+
+func (c *Client) switchTo(index int) {
+	c.endpoints.use(index)
+	c.switching.Store(true)
+	c.mutex.RLock()
+	ch := c.channel
+	c.mutex.RUnlock()
+	if ch != nil {
+		ch.Close(nil)
+	}
+}
+
+// Metrics 返回发送队列的当前指标
+func (c *Client) Metrics() QueueMetrics {
+	if c.queue == nil {
+		return QueueMetrics{}
+	}
+	return c.queue.snapshot()
+}
+
+func (c *Client) setChannel(ch netty.Channel) {
+	c.mutex.Lock()
+	c.channel = ch
+	c.mutex.Unlock()
+	if ch != nil && ch.IsActive() {
+		c.queue.notify()
+	}
+}
+
+func (c *Client) isActive() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.channel != nil && c.channel.IsActive()
 }
 
 func (c *Client) write(data netty.Message) error {
-	return c.channel.Write(data)
+	c.mutex.RLock()
+	ch := c.channel
+	c.mutex.RUnlock()
+	if ch == nil {
+		return ErrQueueClosed
+	}
+	return ch.Write(data)
 }
@@ -12,11 +12,12 @@ import (
 const Delimiter = "@&@"
 
 func newMessageCodec(client *Client) messageCodec {
-	return messageCodec{mh: client.config.MessageHandler}
+	return messageCodec{mh: client.config.MessageHandler, client: client}
 }
 
 type messageCodec struct {
-	mh MessageHandler
+	mh     MessageHandler
+	client *Client
 }
 
 func (m messageCodec) CodecName() string {
@@ -40,6 +41,9 @@ func (m messageCodec) HandleRead(ctx netty.InboundContext, message netty.Message
 	var obj Message
 	obj.SetMessageHeadData(strs[0])
 	obj.SetMessageBodyData(strs[1])
+	if MessageType(obj.MessageType) == MessageTypeHB && m.client.endpoints != nil {
+		m.client.endpoints.pong()
+	}
 	if m.mh == nil {
 		ctx.HandleRead(obj)
 		return
@@ -50,6 +54,10 @@ func (m messageCodec) HandleRead(ctx netty.InboundContext, message netty.Message
 		m.mh.HandleReplyPingData(ctx.Channel().Context(), obj.bodyToString())
 	case MessageTypeBIZ:
 		m.mh.HandleReplyData(ctx.Channel().Context(), obj.bodyToString())
+	case MessageTypeAUTH:
+		if ah, ok := m.mh.(AuthMessageHandler); ok {
+			ah.HandleReplyAuthData(ctx.Channel().Context(), obj.bodyToString())
+		}
 	}
 }
 
@@ -0,0 +1,255 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/util/grand"
+	"github.com/hosgf/element/logger"
+	"github.com/hosgf/element/util"
+)
+
+// EndpointStrategy 多注册节点的选择策略
+type EndpointStrategy string
+
+const (
+	StrategyFailover EndpointStrategy = "failover" // 按配置顺序，优先使用第一个可用节点
+	StrategyRandom   EndpointStrategy = "random"   // 随机选择可用节点
+	StrategyLatency  EndpointStrategy = "latency"  // 选择心跳往返时间最短的节点
+)
+
+const (
+	DefaultFailbackInterval = time.Minute
+	endpointFailCooldown    = 30 * time.Second
+	latencySwitchRatio      = 0.8 // 候选节点延迟低于当前节点的 80% 时才切换，避免抖动
+)
+
+// Endpoint 注册节点状态
+type Endpoint struct {
+	Address   string        `json:"address"`
+	RTT       time.Duration `json:"rtt"` // 往返时间的平滑值，当前节点取心跳往返时间，空闲节点取 TCP 建连耗时，0 表示尚未测量
+	Failures  int           `json:"failures"`
+	LastError time.Time     `json:"lastError"`
+}
+
+func (e *Endpoint) available(now time.Time) bool {
+	return e.LastError.IsZero() || now.Sub(e.LastError) > endpointFailCooldown
+}
+
+func (e *Endpoint) observe(rtt time.Duration) {
+	if e.RTT == 0 {
+		e.RTT = rtt
+		return
+	}
+	// 指数加权平均，平滑单次网络抖动
+	e.RTT = (e.RTT*7 + rtt*3) / 10
+}
+
+type endpointSelector struct {
+	strategy  EndpointStrategy
+	endpoints []*Endpoint
+	current   int
+	pingAt    time.Time
+	mutex     sync.Mutex
+}
+
+func newEndpointSelector(config *ClientConfig) *endpointSelector {
+	addresses := config.Addresses
+	if len(config.Address) > 0 {
+		addresses = append([]string{config.Address}, addresses...)
+	}
+	addresses = util.FilterDuplicates(addresses)
+	s := &endpointSelector{strategy: config.Strategy}
+	if len(s.strategy) < 1 {
+		s.strategy = StrategyFailover
+	}
+	for _, address := range addresses {
+		s.endpoints = append(s.endpoints, &Endpoint{Address: address})
+	}
+	if s.strategy == StrategyRandom && len(s.endpoints) > 0 {
+		s.current = grand.Intn(len(s.endpoints))
+	}
+	return s
+}
+
+// address 当前使用的节点地址
+func (s *endpointSelector) address() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.endpoints) == 0 {
+		return ""
+	}
+	return s.endpoints[s.current].Address
+}
+
+func (s *endpointSelector) addressOf(index int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.endpoints[index].Address
+}
+
+// failed 标记当前节点不可用并切换到下一个节点
+func (s *endpointSelector) failed() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.endpoints) == 0 {
+		return ""
+	}
+	now := time.Now()
+	current := s.endpoints[s.current]
+	current.Failures++
+	current.LastError = now
+	s.current = s.next(now)
+	return s.endpoints[s.current].Address
+}
+
+func (s *endpointSelector) next(now time.Time) int {
+	var candidates []int
+	for i, e := range s.endpoints {
+		if i != s.current && e.available(now) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		// 所有节点都在冷却期内，按顺序轮转
+		return (s.current + 1) % len(s.endpoints)
+	}
+	switch s.strategy {
+	case StrategyRandom:
+		return candidates[grand.Intn(len(candidates))]
+	case StrategyLatency:
+		return s.fastest(candidates)
+	default:
+		return candidates[0]
+	}
+}
+
+func (s *endpointSelector) fastest(candidates []int) int {
+	best := candidates[0]
+	for _, i := range candidates[1:] {
+		rtt := s.endpoints[i].RTT
+		if rtt > 0 && (s.endpoints[best].RTT == 0 || rtt < s.endpoints[best].RTT) {
+			best = i
+		}
+	}
+	return best
+}
+
+// preferred 返回应当回切的节点，没有更优节点时返回 -1
+func (s *endpointSelector) preferred() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	switch s.strategy {
+	case StrategyFailover:
+		for i, e := range s.endpoints {
+			if i >= s.current {
+				break
+			}
+			if e.available(now) {
+				return i
+			}
+		}
+	case StrategyLatency:
+		current := s.endpoints[s.current]
+		if current.RTT == 0 {
+			return -1
+		}
+		for i, e := range s.endpoints {
+			if i != s.current && e.available(now) && e.RTT > 0 &&
+				float64(e.RTT) < float64(current.RTT)*latencySwitchRatio {
+				return s.fastestAvailable(now)
+			}
+		}
+	}
+	return -1
+}
+
+func (s *endpointSelector) fastestAvailable(now time.Time) int {
+	var candidates []int
+	for i, e := range s.endpoints {
+		if i != s.current && e.available(now) {
+			candidates = append(candidates, i)
+		}
+	}
+	return s.fastest(candidates)
+}
+
+func (s *endpointSelector) use(index int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.current = index
+}
+
+func (s *endpointSelector) ping() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pingAt = time.Now()
+}
+
+// pong 根据心跳应答更新当前节点的往返时间
+func (s *endpointSelector) pong() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.pingAt.IsZero() || len(s.endpoints) == 0 {
+		return
+	}
+	s.endpoints[s.current].observe(time.Since(s.pingAt))
+	s.pingAt = time.Time{}
+}
+
+// probe 对非当前节点做 TCP 建连探测，用于延迟比较和判断节点是否恢复
+func (s *endpointSelector) probe(ctx context.Context, timeout time.Duration) {
+	s.mutex.Lock()
+	current := s.current
+	addresses := make([]string, len(s.endpoints))
+	for i, e := range s.endpoints {
+		addresses[i] = e.Address
+	}
+	s.mutex.Unlock()
+	for i, address := range addresses {
+		if i == current {
+			continue
+		}
+		start := time.Now()
+		conn, err := (&net.Dialer{Timeout: timeout}).DialContext(ctx, "tcp", hostPort(address))
+		s.mutex.Lock()
+		e := s.endpoints[i]
+		if err != nil {
+			e.LastError = time.Now()
+		} else {
+			_ = conn.Close()
+			e.LastError = time.Time{}
+			e.observe(time.Since(start))
+		}
+		s.mutex.Unlock()
+		if err != nil {
+			logger.Debugf(ctx, "注册节点 %s 探测失败: %v", address, err)
+		}
+	}
+}
+
+func (s *endpointSelector) snapshot() []Endpoint {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	endpoints := make([]Endpoint, 0, len(s.endpoints))
+	for _, e := range s.endpoints {
+		endpoints = append(endpoints, *e)
+	}
+	return endpoints
+}
+
+// hostPort 去掉地址中可能携带的 tcp:// 等协议前缀
+func hostPort(address string) string {
+	if !strings.Contains(address, "://") {
+		return address
+	}
+	if u, err := url.Parse(address); err == nil {
+		return u.Host
+	}
+	return address
+}
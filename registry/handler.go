@@ -49,14 +49,23 @@ func (h *triggerHandler) SendData(ctx context.Context, data string) error {
 }
 
 func (h *triggerHandler) HandleActive(ctx netty.ActiveContext) {
+	if auth := h.client.config.Auth; auth != nil && auth.Enabled {
+		ctx.Write(NewAuthMessage(auth))
+	}
+	h.client.endpoints.ping()
 	ctx.Write(h.SendPingData())
-	go h.ping(ctx)
+	h.closeChan = make(chan struct{})
+	go h.ping(ctx, h.closeChan)
+	h.client.setChannel(ctx.Channel())
 	ctx.HandleActive()
 }
 
 func (h *triggerHandler) HandleInactive(ctx netty.InactiveContext, ex netty.Exception) {
 	h.stop()
-	go h.retries(ctx.Channel().Context())
+	if !h.client.switching.Swap(false) {
+		h.client.endpoints.failed()
+	}
+	go h.reconnect(ctx.Channel().Context())
 }
 
 func (h *triggerHandler) HandleException(ctx netty.ExceptionContext, ex netty.Exception) {
@@ -64,17 +73,18 @@ func (h *triggerHandler) HandleException(ctx netty.ExceptionContext, ex netty.Ex
 	ctx.Channel().Close(ex)
 }
 
-func (h *triggerHandler) ping(ctx netty.ActiveContext) {
+func (h *triggerHandler) ping(ctx netty.ActiveContext, closeChan chan struct{}) {
 	for {
 		ticker := time.NewTicker(h.nextTime())
 		defer ticker.Stop()
 		select {
-		case <-h.closeChan:
+		case <-closeChan:
 			logger.Debugf(ctx.Channel().Context(), "The channel is closed.！！！！！")
 			return
 		case <-ticker.C:
 			if ctx.Channel().IsActive() {
 				logger.Debugf(ctx.Channel().Context(), "Send heartbeat request to start execution")
+				h.client.endpoints.ping()
 				ctx.Write(h.SendPingData())
 			}
 		}
@@ -82,7 +92,11 @@ func (h *triggerHandler) ping(ctx netty.ActiveContext) {
 }
 
 func (h *triggerHandler) stop() {
-	close(h.closeChan)
+	select {
+	case <-h.closeChan:
+	default:
+		close(h.closeChan)
+	}
 }
 
 func (h *triggerHandler) nextTime() time.Duration {
@@ -90,6 +104,15 @@ func (h *triggerHandler) nextTime() time.Duration {
 	return time.Duration(second) * time.Second
 }
 
+// reconnect 先立即尝试连接选中的节点，失败后进入退避重试
+func (h *triggerHandler) reconnect(ctx context.Context) {
+	if err := h.client.Run(false); err == nil {
+		logger.Info(ctx, "注册服务重连成功")
+		return
+	}
+	h.retries(ctx)
+}
+
 func (h *triggerHandler) retries(ctx context.Context) {
 	for {
 		nextTime := h.nextTime()
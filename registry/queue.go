@@ -0,0 +1,192 @@
+package registry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hosgf/element/uerrors"
+)
+
+// OverflowPolicy 发送队列满时的处理策略
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"      // 阻塞等待队列空闲
+	OverflowDropOldest OverflowPolicy = "dropOldest" // 丢弃最早入队的报文
+	OverflowError      OverflowPolicy = "error"      // 直接返回错误
+)
+
+const DefaultQueueSize = 1024
+
+var (
+	ErrQueueFull    = uerrors.NewSystemError("注册服务发送队列已满")
+	ErrQueueDropped = uerrors.NewSystemError("报文因发送队列溢出被丢弃")
+	ErrQueueClosed  = uerrors.NewSystemError("注册服务发送队列已关闭")
+)
+
+// QueueConfig 发送队列配置
+type QueueConfig struct {
+	Size     int            `json:"size"`     // 队列容量，默认 DefaultQueueSize
+	Overflow OverflowPolicy `json:"overflow"` // 队列满时的处理策略，默认 OverflowBlock
+	Timeout  time.Duration  `json:"timeout"`  // OverflowBlock 时的最长等待时间，0 表示等待到 ctx 结束
+}
+
+// DeliveryCallback 报文投递结果回调，err 为 nil 表示已写入通道
+type DeliveryCallback func(err error)
+
+// QueueMetrics 发送队列指标
+type QueueMetrics struct {
+	Depth    int   `json:"depth"`
+	Capacity int   `json:"capacity"`
+	Enqueued int64 `json:"enqueued"`
+	Sent     int64 `json:"sent"`
+	Dropped  int64 `json:"dropped"`
+	Failed   int64 `json:"failed"`
+}
+
+type pendingMessage struct {
+	message  Message
+	callback DeliveryCallback
+}
+
+func (p *pendingMessage) done(err error) {
+	if p.callback != nil {
+		p.callback(err)
+	}
+}
+
+type outboundQueue struct {
+	config   QueueConfig
+	messages chan *pendingMessage
+	active   chan struct{}
+	mutex    sync.Mutex
+	metrics  QueueMetrics
+}
+
+func newOutboundQueue(config *QueueConfig) *outboundQueue {
+	q := &outboundQueue{active: make(chan struct{}, 1)}
+	if config != nil {
+		q.config = *config
+	}
+	if q.config.Size <= 0 {
+		q.config.Size = DefaultQueueSize
+	}
+	if len(q.config.Overflow) < 1 {
+		q.config.Overflow = OverflowBlock
+	}
+	q.messages = make(chan *pendingMessage, q.config.Size)
+	q.metrics.Capacity = q.config.Size
+	return q
+}
+
+func (q *outboundQueue) offer(ctx context.Context, msg *pendingMessage) error {
+	select {
+	case q.messages <- msg:
+		q.record(func(m *QueueMetrics) { m.Enqueued++ })
+		return nil
+	default:
+	}
+	switch q.config.Overflow {
+	case OverflowError:
+		q.record(func(m *QueueMetrics) { m.Dropped++ })
+		return ErrQueueFull
+	case OverflowDropOldest:
+		for {
+			select {
+			case q.messages <- msg:
+				q.record(func(m *QueueMetrics) { m.Enqueued++ })
+				return nil
+			default:
+			}
+			select {
+			case oldest := <-q.messages:
+				q.record(func(m *QueueMetrics) { m.Dropped++ })
+				oldest.done(ErrQueueDropped)
+			default:
+			}
+		}
+	default:
+		if q.config.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, q.config.Timeout)
+			defer cancel()
+		}
+		select {
+		case q.messages <- msg:
+			q.record(func(m *QueueMetrics) { m.Enqueued++ })
+			return nil
+		case <-ctx.Done():
+			q.record(func(m *QueueMetrics) { m.Dropped++ })
+			return ErrQueueFull
+		}
+	}
+}
+
+// notify 通道变为可用时唤醒发送协程
+func (q *outboundQueue) notify() {
+	select {
+	case q.active <- struct{}{}:
+	default:
+	}
+}
+
+func (q *outboundQueue) record(fn func(m *QueueMetrics)) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	fn(&q.metrics)
+}
+
+func (q *outboundQueue) snapshot() QueueMetrics {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	metrics := q.metrics
+	metrics.Depth = len(q.messages)
+	return metrics
+}
+
+// run 按入队顺序发送报文，通道不可用时保留报文直到重连成功
+func (q *outboundQueue) run(ctx context.Context, c *Client) {
+	for {
+		var msg *pendingMessage
+		select {
+		case <-ctx.Done():
+			q.drain()
+			return
+		case msg = <-q.messages:
+		}
+		for {
+			if c.isActive() {
+				err := c.write(&msg.message)
+				if err == nil {
+					q.record(func(m *QueueMetrics) { m.Sent++ })
+					msg.done(nil)
+					break
+				}
+				if c.isActive() {
+					q.record(func(m *QueueMetrics) { m.Failed++ })
+					msg.done(err)
+					break
+				}
+			}
+			select {
+			case <-ctx.Done():
+				msg.done(ErrQueueClosed)
+				q.drain()
+				return
+			case <-q.active:
+			}
+		}
+	}
+}
+
+func (q *outboundQueue) drain() {
+	for {
+		select {
+		case msg := <-q.messages:
+			msg.done(ErrQueueClosed)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+
+	"github.com/go-netty/go-netty/transport"
+	"github.com/go-netty/go-netty/transport/tcp"
+	"github.com/hosgf/element/uerrors"
+)
+
+// TLSConfig 注册通道的 TLS 配置
+type TLSConfig struct {
+	Enabled            bool   `json:"enabled"`
+	CAFile             string `json:"caFile"`             // 服务端证书的 CA 证书包
+	CertFile           string `json:"certFile"`           // 客户端证书（双向认证）
+	KeyFile            string `json:"keyFile"`            // 客户端私钥（双向认证）
+	ServerName         string `json:"serverName"`         // 校验服务端证书时使用的主机名
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"` // 跳过服务端证书校验，仅用于调试
+}
+
+func (c *TLSConfig) toTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if len(c.CAFile) > 0 {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, uerrors.WrapSystemError(err, "读取CA证书失败")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, uerrors.NewValidationError("caFile", "CA证书内容无效")
+		}
+		config.RootCAs = pool
+	}
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, uerrors.WrapSystemError(err, "加载客户端证书失败")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// newTransport 根据配置返回明文 TCP 或 TLS 传输层
+func newTransport(config *ClientConfig) (transport.Factory, error) {
+	if config.TLS == nil || !config.TLS.Enabled {
+		return tcp.New(), nil
+	}
+	tlsConfig, err := config.TLS.toTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &tlsFactory{config: tlsConfig}, nil
+}
+
+type tlsFactory struct {
+	config *tls.Config
+}
+
+func (f *tlsFactory) Schemes() transport.Schemes {
+	return transport.Schemes{"tcp", "tcp4", "tcp6"}
+}
+
+func (f *tlsFactory) Connect(options *transport.Options) (transport.Transport, error) {
+	if err := f.Schemes().FixScheme(options.Address); err != nil {
+		return nil, err
+	}
+	tcpOptions := tcp.FromContext(options.Context, tcp.DefaultOption)
+	config := f.config.Clone()
+	if len(config.ServerName) < 1 {
+		config.ServerName = options.Address.Hostname()
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tcpOptions.Timeout, KeepAlive: tcpOptions.KeepAlivePeriod},
+		Config:    config,
+	}
+	conn, err := dialer.DialContext(options.Context, options.Address.Scheme, options.Address.Host)
+	if err != nil {
+		return nil, err
+	}
+	return transport.NewTransport(conn, tcpOptions.ReadBufferSize, tcpOptions.WriteBufferSize), nil
+}
+
+func (f *tlsFactory) Listen(options *transport.Options) (transport.Acceptor, error) {
+	return nil, uerrors.NewSystemError("注册客户端不支持监听TLS端口")
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"time"
 
 	"github.com/go-netty/go-netty"
 	"github.com/gogf/gf/v2/util/gconv"
@@ -16,8 +17,9 @@ import (
 type MessageType int32
 
 const (
-	MessageTypeBIZ MessageType = 1 // 业务报文
-	MessageTypeHB  MessageType = 2 // 心跳报文
+	MessageTypeBIZ  MessageType = 1 // 业务报文
+	MessageTypeHB   MessageType = 2 // 心跳报文
+	MessageTypeAUTH MessageType = 3 // 认证报文
 )
 
 func (m MessageType) ToInt32() int32 {
@@ -33,14 +35,20 @@ const (
 )
 
 type ClientConfig struct {
-	Name            string          `json:"name"`
-	Enabled         bool            `json:"enabled"`
-	Address         string          `json:"address"`
-	Retry           bool            `json:"retry"`
-	MaxRetries      int             `json:"maxRetries"`
-	Handler         netty.Handler   `json:"handler"`
-	SendDataHandler SendDataHandler `json:"sendDataHandler"`
-	MessageHandler  MessageHandler  `json:"messageHandler"`
+	Name             string           `json:"name"`
+	Enabled          bool             `json:"enabled"`
+	Address          string           `json:"address"`
+	Addresses        []string         `json:"addresses"` // 备用注册节点，与 Address 合并后按顺序使用
+	Strategy         EndpointStrategy `json:"strategy"`
+	FailbackInterval time.Duration    `json:"failbackInterval"` // 回切探测间隔，默认 DefaultFailbackInterval
+	Retry            bool             `json:"retry"`
+	MaxRetries       int              `json:"maxRetries"`
+	TLS              *TLSConfig       `json:"tls"`
+	Auth             *AuthConfig      `json:"auth"`
+	Queue            *QueueConfig     `json:"queue"`
+	Handler          netty.Handler    `json:"handler"`
+	SendDataHandler  SendDataHandler  `json:"sendDataHandler"`
+	MessageHandler   MessageHandler   `json:"messageHandler"`
 }
 
 type Message struct {
@@ -0,0 +1,173 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hosgf/element/client/k8s"
+	"github.com/hosgf/element/types"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFakeCache(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient(fakePod("sandbox", "group-a", "group-a-0", corev1.PodRunning))
+	cache := kubernetes.Cache()
+	events := make(chan *k8s.CacheEvent, 16)
+	cancel := cache.Subscribe(func(event *k8s.CacheEvent) {
+		events <- event
+	})
+	defer cancel()
+	if err := cache.Start(ctx, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Stop()
+	pods, err := cache.Pods(ctx, "sandbox", "group-a")
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("pods = %v, err = %v", pods, err)
+	}
+
+	pod := fakePod("sandbox", "group-a", "group-a-0", corev1.PodRunning)
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:         "group-a",
+		RestartCount: 1,
+		State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+	if _, err := api.CoreV1().Pods("sandbox").UpdateStatus(ctx, pod, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Type == k8s.CacheProcessStatusChanged && e.Status == k8s.CrashLoopBackOff {
+				return
+			}
+		case <-timeout:
+			t.Fatal("status change event not received")
+		}
+	}
+}
+
+func TestFakeCacheWorkloads(t *testing.T) {
+	ctx := context.Background()
+	replicas := int32(2)
+	labels := map[string]string{types.LabelGroup.String(): "db"}
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "db", Labels: labels},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "db", Image: "mysql:8"}}}},
+		},
+		Status: appsv1.StatefulSetStatus{ReadyReplicas: 2},
+	}
+	kubernetes, api := fakeClient(sts)
+	cache := kubernetes.Cache()
+	events := make(chan *k8s.CacheEvent, 16)
+	cancel := cache.Subscribe(func(event *k8s.CacheEvent) {
+		events <- event
+	})
+	defer cancel()
+	if err := cache.Start(ctx, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Stop()
+
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "agent", Labels: map[string]string{types.LabelGroup.String(): "agent"}},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "agent", Image: "agent:1"}}}},
+		},
+	}
+	if _, err := api.AppsV1().DaemonSets("sandbox").Create(ctx, ds, v1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// 启动时已存在的 db 属于首次同步，只有新建的 agent 发布事件
+	timeout := time.After(5 * time.Second)
+	for added := false; !added; {
+		select {
+		case e := <-events:
+			if e.Type == k8s.CacheGroupAdded && e.Group == "db" {
+				t.Fatal("existing group should not be published as added")
+			}
+			added = e.Type == k8s.CacheGroupAdded && e.Group == "agent"
+		case <-timeout:
+			t.Fatal("group added event not received")
+		}
+	}
+
+	workloads, err := cache.Workloads(ctx, "sandbox")
+	if err != nil || len(workloads) != 2 {
+		t.Fatalf("workloads = %v, err = %v", workloads, err)
+	}
+	kinds := map[string]k8s.WorkloadKind{}
+	for _, w := range workloads {
+		kinds[w.Name] = w.Kind
+	}
+	if kinds["db"] != k8s.WorkloadStatefulSet || kinds["agent"] != k8s.WorkloadDaemonSet {
+		t.Fatalf("kinds = %v", kinds)
+	}
+	if workloads, err = cache.Workloads(ctx, "sandbox", ""); err != nil || len(workloads) != 2 {
+		t.Fatalf("workloads with empty group = %v, err = %v", workloads, err)
+	}
+	workloads, err = cache.Workloads(ctx, "sandbox", "db")
+	if err != nil || len(workloads) != 1 || workloads[0].Replicas != 2 || workloads[0].Status != k8s.Running {
+		t.Fatalf("workloads = %v, err = %v", workloads, err)
+	}
+	if deployments, _ := cache.Deployments(ctx, "sandbox"); len(deployments) != 0 {
+		t.Fatalf("deployments = %v", deployments)
+	}
+
+	if err := api.AppsV1().StatefulSets("sandbox").Delete(ctx, "db", v1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case e := <-events:
+			if e.Type == k8s.CacheGroupDeleted && e.Group == "db" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("group deleted event not received")
+		}
+	}
+}
+
+func TestFakeCacheAutoscalers(t *testing.T) {
+	ctx := context.Background()
+	minReplicas := int32(2)
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "group-a", Labels: map[string]string{types.LabelGroup.String(): "group-a"}},
+		Spec:       autoscalingv2.HorizontalPodAutoscalerSpec{MinReplicas: &minReplicas, MaxReplicas: 5},
+		Status:     autoscalingv2.HorizontalPodAutoscalerStatus{CurrentReplicas: 3, DesiredReplicas: 3},
+	}
+	kubernetes, api := fakeClient(fakePod("sandbox", "group-a", "group-a-0", corev1.PodRunning), hpa)
+	cache := kubernetes.Cache()
+	if err := cache.Start(ctx, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Stop()
+	autoscalers, err := cache.Autoscalers(ctx, "sandbox", "group-a")
+	if err != nil || len(autoscalers) != 1 || autoscalers[0].MaxReplicas != 5 {
+		t.Fatalf("autoscalers = %v, err = %v", autoscalers, err)
+	}
+
+	api.ClearActions()
+	list, err := kubernetes.Process().List(ctx, "sandbox")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("process = %v, err = %v", list, err)
+	}
+	if r, ok := list[0].Details["replicas"].(*k8s.Replicas); !ok || r.Min != 2 || r.Current != 3 {
+		t.Fatalf("replicas = %+v", list[0].Details["replicas"])
+	}
+	// 缓存已同步时不再请求 API Server 获取 HPA
+	for _, action := range api.Actions() {
+		if action.GetResource().Resource == "horizontalpodautoscalers" {
+			t.Fatalf("unexpected action: %v", action)
+		}
+	}
+}
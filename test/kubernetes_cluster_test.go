@@ -0,0 +1,144 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hosgf/element/client/k8s"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const fakeKubeconfig = `apiVersion: v1
+kind: Config
+current-context: cn-east
+clusters:
+- name: east
+  cluster:
+    server: https://10.0.0.1:6443
+- name: west
+  cluster:
+    server: https://10.0.1.1:6443
+users:
+- name: admin
+  user:
+    token: secret
+contexts:
+- name: cn-east
+  context: {cluster: east, user: admin}
+- name: cn-west
+  context: {cluster: west, user: admin}
+`
+
+func TestClustersLoadKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(fakeKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clusters := k8s.NewClusters(false)
+	regions, err := clusters.LoadKubeconfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 2 || clusters.Regions()[1] != "cn-west" {
+		t.Fatalf("regions = %v", clusters.Regions())
+	}
+	west, err := clusters.Get("cn-west")
+	if err != nil || west.Region() != "cn-west" {
+		t.Fatalf("cluster = %+v, err = %v", west, err)
+	}
+	if _, err := clusters.LoadKubeconfig(path, "cn-north"); err == nil {
+		t.Fatal("unknown context should be rejected")
+	}
+	if _, err := clusters.Register(k8s.ClusterConfig{Region: "cn-south", Host: "https://10.0.2.1:6443"}); err == nil {
+		t.Fatal("host without token should be rejected")
+	}
+	if _, err := clusters.Register(k8s.ClusterConfig{Region: "cn-south", Host: "https://10.0.2.1:6443", Token: "secret", Insecure: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clusters.Get("cn-north"); err == nil {
+		t.Fatal("unregistered region should be rejected")
+	}
+}
+
+func TestFakeClustersFanOut(t *testing.T) {
+	ctx := context.Background()
+	node := &corev1.Node{
+		ObjectMeta: v1.ObjectMeta{Name: "node-1"},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}},
+	}
+	east, _ := fakeClient(node, fakePod("default", "web", "web-1", corev1.PodRunning))
+	west, _ := fakeClient(node.DeepCopy(), fakePod("default", "api", "api-1", corev1.PodRunning))
+	clusters := k8s.NewClusters(false)
+	clusters.Add("cn-west", west)
+	clusters.Add("cn-east", east)
+
+	list, err := clusters.ProcessList(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Region != "cn-east" || list[0].PID != "web-1" || list[1].Region != "cn-west" {
+		t.Fatalf("processes = %+v", list)
+	}
+	resources, err := clusters.Resources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 || resources[0].Region != "cn-east" || len(resources[1].Nodes) != 1 {
+		t.Fatalf("resources = %+v", resources)
+	}
+}
+
+func TestFakeClustersReplaceStopsCache(t *testing.T) {
+	ctx := context.Background()
+	old, _ := fakeClient()
+	if err := old.Cache().Start(ctx, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	clusters := k8s.NewClusters(false)
+	clusters.Add("cn-east", old)
+	replaced, _ := fakeClient()
+	if err := replaced.Cache().Start(ctx, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	clusters.Add("cn-east", replaced)
+	if old.Cache().Synced("sandbox") {
+		t.Fatal("cache of the replaced client should be stopped")
+	}
+	clusters.Remove("cn-east")
+	if replaced.Cache().Synced("sandbox") {
+		t.Fatal("cache of the removed client should be stopped")
+	}
+}
+
+func TestFakeClustersTimeout(t *testing.T) {
+	ctx := context.Background()
+	// 不可达的集群：请求一直挂起直到客户端超时
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	clusters := k8s.NewClusters(false).WithTimeout(200 * time.Millisecond)
+	if _, err := clusters.Register(k8s.ClusterConfig{Region: "cn-slow", Host: server.URL, Token: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	fast, _ := fakeClient(fakePod("default", "web", "web-1", corev1.PodRunning))
+	clusters.Add("cn-east", fast)
+
+	start := time.Now()
+	list, err := clusters.ProcessList(ctx, "default")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("slow cluster blocked the query: %v", elapsed)
+	}
+	if err == nil {
+		t.Fatal("slow cluster should report an error")
+	}
+	if len(list) != 1 || list[0].Region != "cn-east" {
+		t.Fatalf("processes = %+v", list)
+	}
+}
@@ -0,0 +1,121 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hosgf/element/health"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func diagnosisEvent(name, kind, object string, uid k8stypes.UID, reason string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: name},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: kind, Namespace: "sandbox", Name: object, UID: uid,
+		},
+		Type:          corev1.EventTypeWarning,
+		Reason:        reason,
+		LastTimestamp: v1.Now(),
+	}
+}
+
+func TestFakeDiagnosis(t *testing.T) {
+	ctx := context.Background()
+	d := rolloutDeployment("web", "1", 2)
+	d.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 1, AvailableReplicas: 1}
+
+	healthy := fakePod("sandbox", "web", "web-ok", corev1.PodRunning)
+	healthy.UID = "pod-ok"
+	crash := fakePod("sandbox", "web", "web-crash", corev1.PodRunning)
+	crash.UID = "pod-crash"
+	crash.Spec.Volumes = []corev1.Volume{
+		{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "web-data"}}},
+		{Name: "cache", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "web-cache"}}},
+	}
+	crash.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:         "web",
+		RestartCount: 4,
+		State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off"}},
+	}}
+	pending := &corev1.PersistentVolumeClaim{
+		ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "web-data", UID: "pvc-data"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	other := fakePod("sandbox", "other", "other-0", corev1.PodRunning)
+	other.UID = "pod-other"
+
+	kubernetes, api := fakeClient(d, healthy, crash, pending, other,
+		diagnosisEvent("e1", "Pod", "web-crash", crash.UID, "BackOff"),
+		diagnosisEvent("e2", "PersistentVolumeClaim", "web-data", pending.UID, "ProvisioningFailed"),
+		diagnosisEvent("e3", "Pod", "other-0", other.UID, "BackOff"),
+		// 同名但已被重建的旧实例的事件
+		diagnosisEvent("e4", "Pod", "web-crash", "pod-crash-old", "Killing"),
+	)
+
+	report, err := kubernetes.Diagnosis().Get(ctx, "sandbox", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Health != health.DOWN || report.Rollout == nil || report.Rollout.Available != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(report.Pods) != 2 {
+		t.Fatalf("pods = %d, want 2", len(report.Pods))
+	}
+	for _, p := range report.Pods {
+		if p.Name == "web-crash" && (p.Containers[0].Reason != "CrashLoopBackOff" || p.Containers[0].RestartCount != 4) {
+			t.Fatalf("crash pod = %+v", p.Containers[0])
+		}
+	}
+	storages := map[string]string{}
+	for _, s := range report.Storages {
+		storages[s.Name] = s.Problem
+	}
+	if storages["web-data"] != "PVC未绑定" || storages["web-cache"] != "PVC不存在" {
+		t.Fatalf("storages = %v", storages)
+	}
+	reasons := map[string]bool{}
+	for _, e := range report.Events {
+		reasons[e.Kind+"/"+e.Name+"/"+e.Reason] = true
+	}
+	if len(report.Events) != 2 || !reasons["Pod/web-crash/BackOff"] || !reasons["PersistentVolumeClaim/web-data/ProvisioningFailed"] {
+		t.Fatalf("events = %v", reasons)
+	}
+	problems := strings.Join(report.Problems, "; ")
+	if !strings.Contains(problems, "CrashLoopBackOff") || !strings.Contains(problems, "web-cache") {
+		t.Fatalf("problems = %s", problems)
+	}
+
+	// 事件按资源逐个查询，不再读取整个命名空间
+	selectors := map[string]bool{}
+	for _, action := range api.Actions() {
+		if action.GetVerb() != "list" || action.GetResource().Resource != "events" {
+			continue
+		}
+		fields := action.(k8stesting.ListAction).GetListRestrictions().Fields.String()
+		if len(fields) == 0 {
+			t.Fatal("events should be listed with a field selector")
+		}
+		selectors[fields] = true
+	}
+	if !selectors["involvedObject.kind=Pod,involvedObject.name=web-crash,involvedObject.namespace=sandbox"] {
+		t.Fatalf("field selectors = %v", selectors)
+	}
+
+	// 没有读取权限时返回错误，而不是误报 PVC 不存在
+	api.PrependReactor("get", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "persistentvolumeclaims"}, "web-cache", nil)
+	})
+	if _, err := kubernetes.Diagnosis().Get(ctx, "sandbox", "web"); err == nil {
+		t.Fatal("forbidden pvc lookup should fail the diagnosis")
+	}
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hosgf/element/client/k8s"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTerminalSession(t *testing.T) {
+	messages := [][]byte{
+		[]byte(`{"op":"resize","rows":40,"cols":120}`),
+		[]byte(`{"op":"stdin","data":"ls\n"}`),
+	}
+	var written []byte
+	session := k8s.NewTerminalSession(func() ([]byte, error) {
+		if len(messages) < 1 {
+			return nil, io.EOF
+		}
+		m := messages[0]
+		messages = messages[1:]
+		return m, nil
+	}, func(data []byte) error {
+		written = data
+		return nil
+	})
+	buf := make([]byte, 16)
+	n, err := session.Read(buf)
+	if err != nil || string(buf[:n]) != "ls\n" {
+		t.Fatalf("read = %q, err = %v", buf[:n], err)
+	}
+	size := session.Next()
+	if size == nil || size.Width != 120 || size.Height != 40 {
+		t.Fatalf("size = %+v", size)
+	}
+	if _, err := session.Write([]byte("total 0")); err != nil {
+		t.Fatal(err)
+	}
+	var msg k8s.TerminalMessage
+	if err := json.Unmarshal(written, &msg); err != nil || msg.Op != k8s.TerminalStdout || msg.Data != "total 0" {
+		t.Fatalf("written = %s, err = %v", written, err)
+	}
+	if _, err := session.Read(buf); !errors.Is(err, io.EOF) {
+		t.Fatalf("err = %v, want EOF", err)
+	}
+	if session.Next() != nil {
+		t.Fatal("closed session should return nil size")
+	}
+}
+
+func TestFakeCommandWithoutRestConfig(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, _ := fakeClient(fakePod("sandbox", "group-a", "group-a-0", "Running"))
+	results, err := kubernetes.Process().Command(ctx, "sandbox", "group-a", "group-a", "echo", "ok")
+	if err == nil || len(results) != 1 || results[0].Pod != "group-a-0" || results[0].ExitCode != -1 {
+		t.Fatalf("results = %+v, err = %v", results, err)
+	}
+}
+
+func TestFakeExecError(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, _ := fakeClient(fakePod("sandbox", "group-a", "group-a-0", corev1.PodRunning))
+	_, err := kubernetes.Pod().Exec(ctx, "sandbox", "group-a-0", "group-a", "echo", "ok")
+	if err == nil || !strings.Contains(err.Error(), "process=group-a") {
+		t.Fatalf("err = %v, want exec context", err)
+	}
+	if _, err := kubernetes.Pod().Exec(ctx, "sandbox", "group-a-0", "group-a"); err == nil || strings.Contains(err.Error(), "exitCode") {
+		t.Fatalf("err = %v, want validation error", err)
+	}
+}
+
+func TestFakePortForwardReadyPod(t *testing.T) {
+	ctx := context.Background()
+	pending := fakePod("sandbox", "group-a", "group-a-0", corev1.PodPending)
+	kubernetes, api := fakeClient(pending)
+	if _, err := kubernetes.Pod().PortForward(ctx, "sandbox", "group-a", "group-a", 0, 8080); err == nil ||
+		!strings.Contains(err.Error(), "没有就绪的实例") {
+		t.Fatalf("err = %v, want no ready replica", err)
+	}
+	ready := fakePod("sandbox", "group-a", "group-a-1", corev1.PodRunning)
+	ready.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "group-a", Ready: true}}
+	if _, err := api.CoreV1().Pods("sandbox").Create(ctx, ready, v1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubernetes.Pod().PortForward(ctx, "sandbox", "group-a", "group-a", 0, 0); err == nil ||
+		!strings.Contains(err.Error(), "端口不合法") {
+		t.Fatalf("err = %v, want invalid port without declared container port", err)
+	}
+	_, err := kubernetes.Pod().PortForward(ctx, "sandbox", "group-a", "group-a", 0, 8080, k8s.ForwardPort{Remote: 9090})
+	if err == nil || !strings.Contains(err.Error(), "REST") {
+		t.Fatalf("err = %v, want missing rest config", err)
+	}
+}
+
+func TestFakeCopyValidation(t *testing.T) {
+	client, _ := fakeClient()
+	ctx := context.Background()
+	for _, remote := range []string{"", "tmp/app.log", "/", "/tmp/a\nb"} {
+		if _, err := client.Pod().CopyFrom(ctx, "default", "app-0", "app", remote, t.TempDir(), k8s.CopyOptions{}); err == nil {
+			t.Fatalf("CopyFrom(%q) should be rejected", remote)
+		}
+	}
+	if _, err := client.Pod().CopyTo(ctx, "default", "app-0", "app", "/not/exist", "/tmp/app", k8s.CopyOptions{}); err == nil {
+		t.Fatal("CopyTo with a missing local path should be rejected")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.conf"), []byte(strings.Repeat("x", 64)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Pod().CopyTo(ctx, "default", "app-0", "app", dir, "/etc/app", k8s.CopyOptions{MaxBytes: 32})
+	if err == nil || !strings.Contains(err.Error(), "大小上限") {
+		t.Fatalf("CopyTo over the size limit: err = %v", err)
+	}
+}
@@ -0,0 +1,979 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hosgf/element/client/k8s"
+	"github.com/hosgf/element/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+// fakeClient 基于 fake clientset 创建客户端，objects 为预置的资源
+func fakeClient(objects ...runtime.Object) (*k8s.Kubernetes, *fake.Clientset) {
+	api := fake.NewSimpleClientset(objects...)
+	return k8s.NewWithClient(true, api, metricsfake.NewSimpleClientset()), api
+}
+
+func fakePod(namespace, group, name string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels: map[string]string{
+				types.LabelApp.String():   group,
+				types.LabelGroup.String(): group,
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{{
+				Name:  group,
+				Image: "nginx:latest",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+}
+
+func TestFakeProcessStart(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Storage = []types.Storage{toStorage()}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{}); err != nil {
+		t.Fatalf("deployment not created: %v", err)
+	}
+	svcs, err := api.CoreV1().Services(config.Namespace).List(ctx, v1.ListOptions{})
+	if err != nil || len(svcs.Items) != 1 {
+		t.Fatalf("services = %v, err = %v", svcs, err)
+	}
+	if _, err := api.CoreV1().PersistentVolumeClaims(config.Namespace).Get(ctx, toStorage().Name, v1.GetOptions{}); err != nil {
+		t.Fatalf("pvc not created: %v", err)
+	}
+}
+
+func TestFakeEnsureStorageExists(t *testing.T) {
+	ctx := context.Background()
+	config := toProcessGroupConfig()
+	storage := toStorage()
+	config.Storage = []types.Storage{storage}
+	existing := &corev1.PersistentVolumeClaim{
+		ObjectMeta: v1.ObjectMeta{Namespace: config.Namespace, Name: storage.Name},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	kubernetes, api := fakeClient(existing)
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range api.Actions() {
+		if action.GetVerb() == "create" && action.GetResource().Resource == "persistentvolumeclaims" {
+			t.Fatalf("existing pvc should not be recreated")
+		}
+	}
+}
+
+func TestFakeToProcess(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, _ := fakeClient(
+		fakePod("sandbox", "group-a", "group-a-0", corev1.PodRunning),
+		fakePod("sandbox", "group-b", "group-b-0", corev1.PodPending),
+	)
+	list, err := kubernetes.Process().List(ctx, "sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("len(list) = %d, want 2", len(list))
+	}
+	for _, p := range list {
+		if p.Details["runningNode"] != "node-1" {
+			t.Fatalf("runningNode = %v", p.Details["runningNode"])
+		}
+	}
+}
+
+func TestFakeConfigMapAndSecret(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	secret := k8s.SecretConfig{Name: "sandbox-secret", Data: map[string]string{"password": "p@ss"}, Env: true}
+	secret.Encrypt()
+	config.ConfigMaps = []k8s.ConfigMapConfig{{Name: "sandbox-conf", Data: map[string]string{"app.yaml": "a: 1"}, Path: "/etc/app"}}
+	config.Secrets = []k8s.SecretConfig{secret}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	data, err := api.CoreV1().Secrets(config.Namespace).Get(ctx, "sandbox-secret", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data.Data["password"]) != "p@ss" {
+		t.Fatalf("secret value = %q, want decrypted value", data.Data["password"])
+	}
+	d, err := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := d.Spec.Template.Annotations[k8s.AnnotationConfigHash]
+	if len(hash) == 0 {
+		t.Fatal("config hash annotation missing")
+	}
+	c := d.Spec.Template.Spec.Containers[0]
+	if len(c.EnvFrom) != 1 || c.EnvFrom[0].SecretRef == nil {
+		t.Fatalf("envFrom = %+v", c.EnvFrom)
+	}
+
+	config.ConfigMaps[0].Data["app.yaml"] = "a: 2"
+	if err := kubernetes.Process().Running(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	d, _ = api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if d.Spec.Template.Annotations[k8s.AnnotationConfigHash] == hash {
+		t.Fatal("config hash should change with content")
+	}
+}
+
+func TestFakeIngress(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Process[0].Expose = &k8s.ExposeConfig{Host: "sandbox.example.com", TLSSecret: "sandbox-tls"}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	name := config.Process[0].Service
+	ing, err := api.NetworkingV1().Ingresses(config.Namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := ing.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	if backend.Name != name || backend.Port.Number != config.Process[0].Ports[0].Port {
+		t.Fatalf("backend = %+v", backend)
+	}
+	if ing.Labels[types.LabelGroup.String()] != config.GroupName {
+		t.Fatalf("labels = %v", ing.Labels)
+	}
+	if err := kubernetes.Process().Destroy(ctx, config.Namespace, config.GroupName); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := api.NetworkingV1().Ingresses(config.Namespace).List(ctx, v1.ListOptions{})
+	if len(list.Items) != 0 {
+		t.Fatalf("ingress should be deleted, got %d", len(list.Items))
+	}
+}
+
+func TestFakeIngressRestart(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Process[0].Expose = &k8s.ExposeConfig{Host: "sandbox.example.com"}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := kubernetes.Process().Stop(ctx, config.Namespace, config.GroupName); err != nil {
+		t.Fatal(err)
+	}
+	// 已暴露的进程组再次启动时沿用已有的 Ingress
+	config.Process[0].Expose.Path = "/api"
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	name := config.Process[0].Service
+	ing, err := api.NetworkingV1().Ingresses(config.Namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := ing.Spec.Rules[0].HTTP.Paths[0].Path; path != "/api" {
+		t.Fatalf("path = %s, want /api", path)
+	}
+
+	// 取消暴露后清理该进程组遗留的 Ingress，其他进程组的不受影响
+	other := &networkingv1.Ingress{ObjectMeta: v1.ObjectMeta{
+		Namespace: config.Namespace,
+		Name:      "other",
+		Labels:    map[string]string{types.LabelGroup.String(): "other"},
+	}}
+	if _, err := api.NetworkingV1().Ingresses(config.Namespace).Create(ctx, other, v1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	config.Process[0].Expose = nil
+	config.AllowUpdate = true
+	if err := kubernetes.Process().Running(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := api.NetworkingV1().Ingresses(config.Namespace).List(ctx, v1.ListOptions{})
+	if len(list.Items) != 1 || list.Items[0].Name != "other" {
+		t.Fatalf("ingresses = %+v", list.Items)
+	}
+}
+
+func TestFakeHTTPRoute(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	api.Resources = []*v1.APIResourceList{{
+		GroupVersion: k8s.GatewayGroupVersion,
+		APIResources: []v1.APIResource{{Name: k8s.HTTPRouteResource.Resource, Kind: "HTTPRoute", Namespaced: true}},
+	}}
+	dynamicApi := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{k8s.HTTPRouteResource: "HTTPRouteList"})
+	kubernetes.WithDynamic(dynamicApi)
+	model := k8s.Model{Namespace: "sandbox", AllowUpdate: true}
+	for _, name := range []string{"web", "api"} {
+		model.Name = name
+		ingress := &k8s.Ingress{Model: model, Service: name, ExposeConfig: k8s.ExposeConfig{
+			Host: name + ".example.com", Port: 80, Gateway: "infra/public", PathType: "ImplementationSpecific",
+		}}
+		if err := kubernetes.Ingress().Apply(ctx, ingress); err != nil {
+			t.Fatal(err)
+		}
+	}
+	route, err := dynamicApi.Resource(k8s.HTTPRouteResource).Namespace("sandbox").Get(ctx, "web", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	matches := rules[0].(map[string]interface{})["matches"].([]interface{})
+	if pathType := matches[0].(map[string]interface{})["path"].(map[string]interface{})["type"]; pathType != "PathPrefix" {
+		t.Fatalf("path type = %v", pathType)
+	}
+	// Gateway API 的探测结果按客户端缓存
+	discovery := 0
+	for _, action := range api.Actions() {
+		if action.GetResource().Resource == "resource" {
+			discovery++
+		}
+	}
+	if discovery != 1 {
+		t.Fatalf("discovery calls = %d, want 1", discovery)
+	}
+}
+
+func TestFakeAutoscaler(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Autoscaling = &k8s.AutoscalingConfig{
+		MinReplicas: 2,
+		MaxReplicas: 5,
+		CPU:         80,
+		Metrics:     []k8s.MetricTarget{{Name: "requests_per_second", Target: "100"}},
+	}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	hpa, err := api.AutoscalingV2().HorizontalPodAutoscalers(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *hpa.Spec.MinReplicas != 2 || hpa.Spec.MaxReplicas != 5 || len(hpa.Spec.Metrics) != 2 {
+		t.Fatalf("hpa spec = %+v", hpa.Spec)
+	}
+	d, _ := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if *d.Spec.Replicas != 2 {
+		t.Fatalf("replicas = %d, want min replicas", *d.Spec.Replicas)
+	}
+	if err := kubernetes.Process().Scale(ctx, config.Namespace, config.GroupName, 3); err == nil {
+		t.Fatal("scale should be rejected while autoscaling")
+	}
+
+	config.Autoscaling = nil
+	if err := kubernetes.Process().Running(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := api.AutoscalingV2().HorizontalPodAutoscalers(config.Namespace).List(ctx, v1.ListOptions{})
+	if len(list.Items) != 0 {
+		t.Fatalf("hpa should be deleted, got %d", len(list.Items))
+	}
+	if err := kubernetes.Process().Scale(ctx, config.Namespace, config.GroupName, 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeStorageResource(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	cases := map[string]types.StorageResource{
+		"nfs":    {Type: "NFS", Item: `{"server":"10.0.0.1","path":"/exports/data"}`},
+		"cephfs": {Type: "CephFS", Item: `{"monitors":["10.0.0.2:6789"],"path":"/volumes","user":"admin","secretRef":{"name":"ceph-secret","namespace":"sandbox"}}`},
+		"local":  {Type: "Local", Item: `{"path":"/mnt/ssd","nodes":["node-1"]}`},
+		"csi":    {Type: "CSI", Item: `{"driver":"disk.csi.example.com","volumeHandle":"vol-1","volumeAttributes":{"tier":"ssd"},"nodePublishSecretRef":{"name":"csi-secret","namespace":"sandbox"}}`},
+	}
+	for name, res := range cases {
+		storage := &k8s.PersistentStorageResource{Storage: types.Storage{Name: name, Size: "1Gi", Item: "manual", Resource: res}}
+		if err := kubernetes.StorageResource().Apply(ctx, storage); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		pv, err := api.CoreV1().PersistentVolumes().Get(ctx, name, v1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pv.Spec.HostPath != nil {
+			t.Fatalf("%s: unexpected hostPath source", name)
+		}
+		s, err := kubernetes.StorageResource().Get(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k8s.ToStorageResourceType(s.Resource.Type) != k8s.ToStorageResourceType(res.Type) || len(s.Resource.Item) == 0 {
+			t.Fatalf("%s: decoded resource = %+v", name, s.Resource)
+		}
+	}
+	pv, _ := api.CoreV1().PersistentVolumes().Get(ctx, "local", v1.GetOptions{})
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions[0].Values[0] != "node-1" {
+		t.Fatalf("local node affinity = %+v", pv.Spec.NodeAffinity)
+	}
+	pv, _ = api.CoreV1().PersistentVolumes().Get(ctx, "csi", v1.GetOptions{})
+	if pv.Spec.CSI.VolumeAttributes["tier"] != "ssd" || pv.Spec.CSI.NodePublishSecretRef == nil {
+		t.Fatalf("csi source = %+v", pv.Spec.CSI)
+	}
+
+	invalid := &k8s.PersistentStorageResource{Storage: types.Storage{Name: "bad", Size: "1Gi",
+		Resource: types.StorageResource{Type: "Local", Item: `{"path":"/mnt/ssd"}`}}}
+	if err := kubernetes.StorageResource().Apply(ctx, invalid); err == nil {
+		t.Fatal("local storage without nodes should be rejected")
+	}
+}
+
+func TestFakeStorageResize(t *testing.T) {
+	ctx := context.Background()
+	expandable, fixed := true, false
+	pvc := func(name, class string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: name},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &class,
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Phase:    corev1.ClaimBound,
+				Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		}
+	}
+	kubernetes, api := fakeClient(
+		&storagev1.StorageClass{ObjectMeta: v1.ObjectMeta{Name: "expandable"}, AllowVolumeExpansion: &expandable},
+		&storagev1.StorageClass{ObjectMeta: v1.ObjectMeta{Name: "fixed"}, AllowVolumeExpansion: &fixed},
+		pvc("data", "expandable"),
+		pvc("logs", "fixed"),
+	)
+	storage := kubernetes.Storage()
+	if _, err := storage.Resize(ctx, "sandbox", "data", "512Mi", k8s.ResizeOptions{NoWait: true}); err == nil {
+		t.Fatal("shrink should be rejected")
+	}
+	if _, err := storage.Resize(ctx, "sandbox", "logs", "2Gi", k8s.ResizeOptions{NoWait: true}); err == nil {
+		t.Fatal("resize should be rejected when storage class does not allow expansion")
+	}
+	status, err := storage.Resize(ctx, "sandbox", "data", "2Gi", k8s.ResizeOptions{NoWait: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.IsComplete() || status.Requested != "2Gi" {
+		t.Fatalf("status = %s", status)
+	}
+
+	data, _ := api.CoreV1().PersistentVolumeClaims("sandbox").Get(ctx, "data", v1.GetOptions{})
+	data.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("2Gi")}
+	if _, err := api.CoreV1().PersistentVolumeClaims("sandbox").UpdateStatus(ctx, data, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	status, err = storage.WaitResize(ctx, "sandbox", "data", k8s.ResizeOptions{Timeout: time.Second, Interval: 10 * time.Millisecond})
+	if err != nil || !status.IsComplete() {
+		t.Fatalf("status = %v, err = %v", status, err)
+	}
+	s, err := storage.Get(ctx, "sandbox", "data")
+	if err != nil || s.Size != "2Gi" || s.Capacity != "2Gi" {
+		t.Fatalf("storage = %+v, err = %v", s, err)
+	}
+
+	// 按原配置再次下发时不改动已扩容的容量
+	model := k8s.Model{Namespace: "sandbox"}
+	if err := storage.BatchApply(ctx, model, []types.Storage{{Name: "data", Size: "1Gi", Item: "expandable"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = api.CoreV1().PersistentVolumeClaims("sandbox").Get(ctx, "data", v1.GetOptions{})
+	resources := data.Spec.Resources
+	if resources.Requests.Storage().String() != "2Gi" {
+		t.Fatalf("resources = %+v", resources)
+	}
+	if limit, ok := resources.Limits[corev1.ResourceStorage]; ok && limit.Cmp(*resources.Requests.Storage()) < 0 {
+		t.Fatalf("limits below requests: %+v", resources)
+	}
+}
+
+func TestFakeSnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	replicas := int32(2)
+	labels := map[string]string{types.LabelGroup.String(): "group-a"}
+	kubernetes, api := fakeClient(
+		&appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "group-a", Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "data", Labels: labels},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			},
+		},
+	)
+	dynamicApi := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{k8s.VolumeSnapshotResource: "VolumeSnapshotList"})
+	kubernetes.WithDynamic(dynamicApi)
+
+	snapshots, err := kubernetes.Process().Snapshot(ctx, "sandbox", "group-a", "before-upgrade")
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("snapshots = %v, err = %v", snapshots, err)
+	}
+	if err := kubernetes.Process().RestoreSnapshot(ctx, "sandbox", "group-a", "before-upgrade"); err == nil {
+		t.Fatal("restore should wait for snapshots to be ready")
+	}
+	snapshotApi := dynamicApi.Resource(k8s.VolumeSnapshotResource).Namespace("sandbox")
+	data, _ := snapshotApi.Get(ctx, snapshots[0].Name, v1.GetOptions{})
+	_ = unstructured.SetNestedField(data.Object, true, "status", "readyToUse")
+	_ = unstructured.SetNestedField(data.Object, "2Gi", "status", "restoreSize")
+	if _, err := snapshotApi.Update(ctx, data, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := kubernetes.Snapshot().List(ctx, "sandbox", "group-a", "before-upgrade")
+	if err != nil || len(list) != 1 || !list[0].ReadyToUse || list[0].Storage != "data" {
+		t.Fatalf("list = %v, err = %v", list, err)
+	}
+	if err := kubernetes.Process().RestoreSnapshot(ctx, "sandbox", "group-a", "before-upgrade"); err != nil {
+		t.Fatal(err)
+	}
+	pvc, err := api.CoreV1().PersistentVolumeClaims("sandbox").Get(ctx, "data", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != snapshots[0].Name {
+		t.Fatalf("dataSource = %+v", pvc.Spec.DataSource)
+	}
+	if pvc.Spec.Resources.Requests.Storage().String() != "2Gi" || pvc.Labels[types.LabelGroup.String()] != "group-a" {
+		t.Fatalf("pvc = %+v", pvc)
+	}
+	d, _ := api.AppsV1().Deployments("sandbox").Get(ctx, "group-a", v1.GetOptions{})
+	if *d.Spec.Replicas != 2 {
+		t.Fatalf("replicas = %d, want restored to 2", *d.Spec.Replicas)
+	}
+	if err := kubernetes.Snapshot().DeleteSet(ctx, "sandbox", "group-a", "before-upgrade"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeGroupLogger(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, _ := fakeClient(
+		fakePod("sandbox", "group-a", "group-a-0", corev1.PodRunning),
+		fakePod("sandbox", "group-a", "group-a-1", corev1.PodRunning),
+	)
+	reader, err := kubernetes.Process().Logger(ctx, "sandbox", "group-a", "", k8s.ProcessLogger{Format: k8s.LoggerFormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var l k8s.LoggerLine
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		pods[l.Pod] = true
+	}
+	if len(pods) != 2 {
+		t.Fatalf("pods = %v, want logs from both replicas", pods)
+	}
+
+	reader, err = kubernetes.Process().Logger(ctx, "sandbox", "group-a", "group-a", k8s.ProcessLogger{Grep: "^nothing$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = io.ReadAll(reader)
+	if len(data) != 0 {
+		t.Fatalf("filtered logs = %q", data)
+	}
+}
+
+func TestFakeGroupLoggerRewatch(t *testing.T) {
+	ctx := context.Background()
+	pod := fakePod("sandbox", "group-a", "group-a-0", corev1.PodRunning)
+	kubernetes, api := fakeClient(pod)
+	watchers := make(chan *watch.FakeWatcher, 4)
+	api.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watchers <- w
+		return true, w, nil
+	})
+	reader, err := kubernetes.Process().Logger(ctx, "sandbox", "group-a", "", k8s.ProcessLogger{Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("log line not received")
+			return ""
+		}
+	}
+	nextWatcher := func() *watch.FakeWatcher {
+		select {
+		case w := <-watchers:
+			return w
+		case <-time.After(5 * time.Second):
+			t.Fatal("pods not watched")
+			return nil
+		}
+	}
+	if line := next(); line != "[group-a-0/group-a] fake logs" {
+		t.Fatalf("line = %q", line)
+	}
+
+	// 监听被服务端关闭后重新监听
+	nextWatcher().Stop()
+	w := nextWatcher()
+	running := pod.DeepCopy()
+	running.ResourceVersion = "2"
+	running.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "group-a",
+		State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}}
+	w.Modify(running)
+	// 进程重启后重新开启日志流
+	restarted := running.DeepCopy()
+	restarted.ResourceVersion = "3"
+	restarted.Status.ContainerStatuses[0].RestartCount = 1
+	w.Modify(restarted)
+	if line := next(); line != "[group-a-0/group-a] fake logs" {
+		t.Fatalf("line = %q", line)
+	}
+	select {
+	case line := <-lines:
+		t.Fatalf("unexpected line %q", line)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestFakeNodeDrain(t *testing.T) {
+	ctx := context.Background()
+	controller := true
+	owned := func(pod *corev1.Pod, kind string) *corev1.Pod {
+		pod.UID = k8stypes.UID(pod.Name)
+		pod.OwnerReferences = []v1.OwnerReference{{Kind: kind, Name: pod.Name, Controller: &controller}}
+		return pod
+	}
+	mirror := fakePod("kube-system", "etcd", "etcd-node-1", corev1.PodRunning)
+	mirror.Annotations = map[string]string{"kubernetes.io/config.mirror": "etcd"}
+	client, api := fakeClient(
+		&corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "node-1"}},
+		owned(fakePod("default", "web", "web-1", corev1.PodRunning), "ReplicaSet"),
+		owned(fakePod("default", "web", "web-2", corev1.PodRunning), "ReplicaSet"),
+		owned(fakePod("kube-system", "proxy", "proxy-1", corev1.PodRunning), "DaemonSet"),
+		mirror,
+	)
+	blocked := 1
+	api.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		if eviction.Name == "web-2" && blocked > 0 {
+			blocked--
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		}
+		gvr := corev1.SchemeGroupVersion.WithResource("pods")
+		return true, nil, api.Tracker().Delete(gvr, eviction.Namespace, eviction.Name)
+	})
+	var reports []*k8s.DrainStatus
+	status, err := client.Nodes().Drain(ctx, "node-1", k8s.DrainOptions{
+		Timeout:  5 * time.Second,
+		Interval: 10 * time.Millisecond,
+		Progress: func(progress *k8s.DrainStatus) { reports = append(reports, progress) },
+	})
+	if err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if !status.Complete || status.Total != 2 || len(status.Evicted) != 2 || len(status.Skipped) != 2 {
+		t.Fatalf("status = %+v", status)
+	}
+	if len(reports) < 3 || len(reports[1].Blocked) != 1 {
+		t.Fatalf("reports = %+v", reports)
+	}
+	node, err := client.Nodes().Get(ctx, "node-1")
+	if err != nil || node.Schedulable || node.ToNode().Schedulable {
+		t.Fatalf("node should be cordoned: %+v, err = %v", node, err)
+	}
+	if _, err := api.CoreV1().Pods("kube-system").Get(ctx, "proxy-1", v1.GetOptions{}); err != nil {
+		t.Fatalf("daemonset pod should be kept: %v", err)
+	}
+	if err := client.Nodes().Uncordon(ctx, "node-1"); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ = client.Nodes().Get(ctx, "node-1"); !node.Schedulable {
+		t.Fatal("node should be schedulable")
+	}
+}
+
+func TestFakeNodeDrainUnmanaged(t *testing.T) {
+	client, _ := fakeClient(
+		&corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "node-1"}},
+		fakePod("default", "web", "web-1", corev1.PodRunning),
+	)
+	if _, err := client.Nodes().Drain(context.Background(), "node-1", k8s.DrainOptions{}); err == nil {
+		t.Fatal("unmanaged pods should require force")
+	}
+}
+
+func TestFakeNodeLabelsAndTaints(t *testing.T) {
+	ctx := context.Background()
+	client, _ := fakeClient(&corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "node-1", Labels: map[string]string{"zone": "a"}}})
+	nodes := client.Nodes()
+	if err := nodes.SetLabels(ctx, "node-1", map[string]string{"disk": "ssd"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes.RemoveLabels(ctx, "node-1", "zone"); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes.AddTaints(ctx, "node-1", k8s.Taint{Key: "maintenance", Effect: k8s.TaintNoSchedule}); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes.AddTaints(ctx, "node-1", k8s.Taint{Key: "maintenance", Value: "true", Effect: k8s.TaintNoSchedule},
+		k8s.Taint{Key: "gpu", Effect: k8s.TaintNoExecute}); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes.AddTaints(ctx, "node-1", k8s.Taint{Key: "bad", Effect: "Never"}); err == nil {
+		t.Fatal("invalid taint effect should be rejected")
+	}
+	node, err := nodes.Get(ctx, "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Labels["disk"] != "ssd" || len(node.Labels["zone"]) > 0 {
+		t.Fatalf("labels = %v", node.Labels)
+	}
+	if len(node.Taints) != 2 || node.Taints[0].Value != "true" {
+		t.Fatalf("taints = %+v", node.Taints)
+	}
+	if err := nodes.RemoveTaints(ctx, "node-1", k8s.Taint{Key: "maintenance"}); err != nil {
+		t.Fatal(err)
+	}
+	if node, _ = nodes.Get(ctx, "node-1"); len(node.Taints) != 1 || node.Taints[0].Key != "gpu" {
+		t.Fatalf("taints = %+v", node.Taints)
+	}
+}
+
+func TestFakeNamespaceQuota(t *testing.T) {
+	ctx := context.Background()
+	client, api := fakeClient(&corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "tenant-a"}})
+	namespaces := client.Namespace()
+	if err := namespaces.ApplyQuota(ctx, "tenant-a", k8s.QuotaConfig{CPU: "bad"}); err == nil {
+		t.Fatal("invalid quantity should be rejected")
+	}
+	if err := namespaces.ApplyQuota(ctx, "tenant-a", k8s.QuotaConfig{
+		CPU: "4", Memory: "8Gi", Storage: "100Gi", Objects: map[string]int64{"pods": 20},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := namespaces.ApplyQuota(ctx, "tenant-a", k8s.QuotaConfig{CPU: "8", Memory: "8Gi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := namespaces.ApplyLimitRange(ctx, "tenant-a", k8s.LimitRangeConfig{
+		DefaultCPU: "500m", DefaultMemory: "512Mi", DefaultRequestCPU: "100m", MaxCPU: "2",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟配额控制器统计的使用量
+	quota, _ := api.CoreV1().ResourceQuotas("tenant-a").Get(ctx, k8s.QuotaName, v1.GetOptions{})
+	quota.Status.Hard = quota.Spec.Hard
+	quota.Status.Used = corev1.ResourceList{
+		corev1.ResourceLimitsCPU:    resource.MustParse("1500m"),
+		corev1.ResourceLimitsMemory: resource.MustParse("2Gi"),
+	}
+	if _, err := api.CoreV1().ResourceQuotas("tenant-a").UpdateStatus(ctx, quota, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := namespaces.Quota(ctx, "tenant-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Quota.CPU != "8" || len(result.Quota.Storage) > 0 || result.Limits.DefaultCPU != "500m" || result.Limits.MaxCPU != "2" {
+		t.Fatalf("quota = %+v, limits = %+v", result.Quota, result.Limits)
+	}
+	cpu := result.Usage.Items["limits.cpu"]
+	if cpu.Total != 8000 || cpu.Usage != 1500 || cpu.Free != 6500 || cpu.Unit != "m" {
+		t.Fatalf("cpu = %+v", cpu)
+	}
+	if memory := result.Usage.Items["limits.memory"]; memory.Total != 8192 || memory.Usage != 2048 {
+		t.Fatalf("memory = %+v", memory)
+	}
+	quotas, err := namespaces.Quotas(ctx, "")
+	if err != nil || len(quotas) != 1 || quotas[0].Namespace != "tenant-a" {
+		t.Fatalf("quotas = %+v, err = %v", quotas, err)
+	}
+	if err := namespaces.DeleteQuota(ctx, "tenant-a"); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ = namespaces.Quota(ctx, "tenant-a"); result.Quota != nil || result.Limits != nil {
+		t.Fatalf("quota should be deleted: %+v", result)
+	}
+}
+
+func TestFakeResourceQuotaUnavailable(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient(&corev1.Node{ObjectMeta: v1.ObjectMeta{Name: "node-1"}})
+	api.PrependReactor("list", "resourcequotas", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "resourcequotas"}, "", nil)
+	})
+	// 没有配额的读取权限时仍返回节点资源
+	res, err := kubernetes.Resource().Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Nodes) != 1 || len(res.Quotas) != 0 {
+		t.Fatalf("resource = %+v", res)
+	}
+}
+
+func TestFakeNetworkPolicy(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Labels.App = "shop"
+	config.Network = &k8s.NetworkConfig{
+		Ingress: []k8s.NetworkRule{
+			{SameGroup: true},
+			{SameApp: true, Namespaces: []string{"gateway"}, Ports: []k8s.NetworkPort{{Port: 8080}}},
+		},
+		Egress: []k8s.NetworkRule{
+			{CIDRs: []string{"10.0.0.0/8"}, Ports: []k8s.NetworkPort{{Port: 5432}, {Port: 9000, EndPort: 9100, Protocol: types.ProtocolUdp}}},
+		},
+	}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := api.NetworkingV1().NetworkPolicies(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Spec.PodSelector.MatchLabels[types.LabelGroup.String()] != config.GroupName || len(policy.Spec.PolicyTypes) != 2 {
+		t.Fatalf("policy spec = %+v", policy.Spec)
+	}
+	if len(policy.Spec.Ingress) != 2 || len(policy.Spec.Ingress[1].From) != 2 || len(policy.Spec.Egress) != 2 {
+		t.Fatalf("policy rules = %+v", policy.Spec)
+	}
+	got, err := kubernetes.NetworkPolicy().Get(ctx, config.Namespace, config.GroupName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Egress) != 1 || got.Egress[0].Ports[1].EndPort != 9100 || !got.Ingress[1].SameApp || got.Ingress[1].Namespaces[0] != "gateway" {
+		t.Fatalf("network policy = %+v", got)
+	}
+
+	config.Network.Egress[0].CIDRs = []string{"10.0.0.0/33"}
+	if err := kubernetes.Process().Running(ctx, config); err == nil {
+		t.Fatal("invalid cidr should be rejected")
+	}
+	config.Network = nil
+	if err := kubernetes.Process().Running(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = kubernetes.NetworkPolicy().Get(ctx, config.Namespace, config.GroupName); got != nil {
+		t.Fatalf("network policy should be pruned: %+v", got)
+	}
+
+	if err := kubernetes.NetworkPolicy().DefaultDeny(ctx, config.Namespace, true, true); err != nil {
+		t.Fatal(err)
+	}
+	deny, err := api.NetworkingV1().NetworkPolicies(config.Namespace).Get(ctx, k8s.DefaultDenyPolicyName, v1.GetOptions{})
+	if err != nil || len(deny.Spec.PolicyTypes) != 2 || len(deny.Spec.Egress) != 1 {
+		t.Fatalf("default deny = %+v, err = %v", deny, err)
+	}
+	if err := kubernetes.NetworkPolicy().DefaultDeny(ctx, config.Namespace, false, false); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := api.NetworkingV1().NetworkPolicies(config.Namespace).List(ctx, v1.ListOptions{})
+	if len(list.Items) != 0 {
+		t.Fatalf("policies = %d, want 0", len(list.Items))
+	}
+}
+
+func TestFakeProbes(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Process[0].Probe = k8s.ProbeConfig{Enabled: true, ProbeType: k8s.ProbeTcp, TcpSocketPort: 28001}
+	config.Process[0].Probes = k8s.ProbesConfig{
+		Liveness: &k8s.ProbeConfig{
+			Enabled: true, ProbeType: k8s.ProbeHttp, HttpGetPath: "/health", HttpGetPort: 28001,
+			InitialDelaySeconds: k8s.ProbeNoInitialDelay, PeriodSeconds: 5, SuccessThreshold: 3,
+		},
+		Startup: &k8s.ProbeConfig{Enabled: true, ProbeType: k8s.ProbeExec, ExecCommand: "test -f /tmp/ready", FailureThreshold: 60},
+	}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	deployment, err := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := deployment.Spec.Template.Spec.Containers[0]
+	if c.LivenessProbe == nil || c.LivenessProbe.InitialDelaySeconds != 0 || c.LivenessProbe.SuccessThreshold != 1 ||
+		c.LivenessProbe.PeriodSeconds != 5 || c.LivenessProbe.HTTPGet.Path != "/health" {
+		t.Fatalf("liveness = %+v", c.LivenessProbe)
+	}
+	if c.ReadinessProbe == nil || c.ReadinessProbe.TCPSocket == nil ||
+		c.ReadinessProbe.InitialDelaySeconds != k8s.DefaultProbeInitialDelaySeconds {
+		t.Fatalf("readiness = %+v", c.ReadinessProbe)
+	}
+	if c.StartupProbe == nil || c.StartupProbe.InitialDelaySeconds != k8s.DefaultStartupProbeInitialDelaySeconds ||
+		c.StartupProbe.FailureThreshold != 60 || c.StartupProbe.Exec.Command[2] != "test -f /tmp/ready" {
+		t.Fatalf("startup = %+v", c.StartupProbe)
+	}
+
+	pods, err := kubernetes.Pod().Deployments(ctx, config.Namespace, config.GroupName)
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("pods = %v, err = %v", pods, err)
+	}
+	probes := pods[0].Containers[0].Probes
+	if probes.Liveness == nil || probes.Liveness.InitialDelaySeconds != k8s.ProbeNoInitialDelay || probes.Liveness.HttpGetPort != 28001 {
+		t.Fatalf("liveness config = %+v", probes.Liveness)
+	}
+	if probes.Readiness == nil || probes.Readiness.ProbeType != k8s.ProbeTcp || probes.Readiness.TcpSocketPort != 28001 {
+		t.Fatalf("readiness config = %+v", probes.Readiness)
+	}
+	if probes.Startup == nil || probes.Startup.ExecCommand != "test -f /tmp/ready" || probes.Startup.InitialDelaySeconds != k8s.ProbeNoInitialDelay {
+		t.Fatalf("startup config = %+v", probes.Startup)
+	}
+
+	// 读取的配置再次下发时保持不延迟
+	config.AllowUpdate = true
+	config.Process[0].Probes = probes
+	if err := kubernetes.Process().Running(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	deployment, _ = api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if c := deployment.Spec.Template.Spec.Containers[0]; c.LivenessProbe.InitialDelaySeconds != 0 {
+		t.Fatalf("liveness = %+v", c.LivenessProbe)
+	}
+}
+
+func TestFakeScheduling(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	seconds := int64(60)
+	config.RunningNode = "node-1"
+	config.Secret = "registry-a, registry-b,"
+	config.Scheduling = &k8s.SchedulingConfig{
+		NodeSelector: map[string]string{"disk": "ssd"},
+		NodeAffinity: []k8s.NodeAffinity{
+			{Key: "zone", Values: []string{"a", "b"}, Required: true},
+			{Key: "gpu", Operator: "Exists", Weight: 20},
+		},
+		PodAntiAffinity: []k8s.PodAffinity{{Required: true}},
+		Tolerations: []k8s.Toleration{
+			{Key: "dedicated", Operator: "Equal", Value: "data", Effect: "NoSchedule"},
+			{Key: "unreachable", Operator: "Exists", Effect: "NoExecute", TolerationSeconds: &seconds},
+		},
+		TopologySpread: []k8s.TopologySpread{{TopologyKey: "zone"}},
+	}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	deployment, err := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := deployment.Spec.Template.Spec
+	if spec.NodeSelector[k8s.LabelHostname] != "node-1" || spec.NodeSelector["disk"] != "ssd" {
+		t.Fatalf("node selector = %v", spec.NodeSelector)
+	}
+	if len(spec.ImagePullSecrets) != 2 || spec.ImagePullSecrets[0].Name != "registry-a" || spec.ImagePullSecrets[1].Name != "registry-b" {
+		t.Fatalf("image pull secrets = %v", spec.ImagePullSecrets)
+	}
+	na := spec.Affinity.NodeAffinity
+	if na.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0].Operator != corev1.NodeSelectorOpIn ||
+		len(na.PreferredDuringSchedulingIgnoredDuringExecution) != 1 || na.PreferredDuringSchedulingIgnoredDuringExecution[0].Weight != 20 {
+		t.Fatalf("node affinity = %+v", na)
+	}
+	anti := spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(anti) != 1 || anti[0].TopologyKey != k8s.LabelHostname || anti[0].LabelSelector.MatchLabels[types.LabelGroup.String()] != config.GroupName {
+		t.Fatalf("pod anti affinity = %+v", anti)
+	}
+	if len(spec.TopologySpreadConstraints) != 1 || spec.TopologySpreadConstraints[0].MaxSkew != 1 ||
+		spec.TopologySpreadConstraints[0].WhenUnsatisfiable != corev1.ScheduleAnyway {
+		t.Fatalf("topology spread = %+v", spec.TopologySpreadConstraints)
+	}
+
+	pods, err := kubernetes.Pod().Deployments(ctx, config.Namespace, config.GroupName)
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("pods = %v, err = %v", pods, err)
+	}
+	pod := pods[0]
+	if pod.RunningNode != "node-1" || len(pod.Secrets) != 2 || pod.Secrets[1] != "registry-b" {
+		t.Fatalf("running node = %s, secrets = %v", pod.RunningNode, pod.Secrets)
+	}
+	s := pod.Scheduling
+	if s == nil || len(s.NodeSelector) != 1 || s.NodeSelector["disk"] != "ssd" {
+		t.Fatalf("scheduling = %+v", s)
+	}
+	if len(s.NodeAffinity) != 2 || !s.NodeAffinity[0].Required || s.NodeAffinity[0].Values[1] != "b" ||
+		s.NodeAffinity[1].Operator != "Exists" || s.NodeAffinity[1].Weight != 20 {
+		t.Fatalf("node affinity = %+v", s.NodeAffinity)
+	}
+	if len(s.PodAntiAffinity) != 1 || !s.PodAntiAffinity[0].Required || s.PodAntiAffinity[0].TopologyKey != k8s.LabelHostname {
+		t.Fatalf("pod anti affinity = %+v", s.PodAntiAffinity)
+	}
+	if len(s.Tolerations) != 2 || s.Tolerations[0].Value != "data" || *s.Tolerations[1].TolerationSeconds != 60 {
+		t.Fatalf("tolerations = %+v", s.Tolerations)
+	}
+	if len(s.TopologySpread) != 1 || s.TopologySpread[0].TopologyKey != "zone" {
+		t.Fatalf("topology spread = %+v", s.TopologySpread)
+	}
+}
@@ -0,0 +1,222 @@
+package test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hosgf/element/client/k8s"
+	"github.com/hosgf/element/types"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func toJobConfig(name, schedule string) *k8s.JobConfig {
+	process := toProcess("sandbox", "01")
+	process.Name = name
+	return &k8s.JobConfig{
+		Namespace: "sandbox",
+		Name:      name,
+		Schedule:  schedule,
+		Labels:    types.Labels{App: name, Owner: "match-data-platform"},
+		Process:   []k8s.ProcessConfig{process},
+	}
+}
+
+func TestFakeJobApply(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	migrate := toJobConfig("migrate", "")
+	if err := kubernetes.Job().Apply(ctx, migrate); err != nil {
+		t.Fatal(err)
+	}
+	job, err := api.BatchV1().Jobs("sandbox").Get(ctx, "migrate", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyOnFailure ||
+		job.Labels[types.LabelGroup.String()] != "migrate" || len(job.Spec.Template.Spec.Containers) != 1 {
+		t.Fatalf("job = %+v", job)
+	}
+	if err := kubernetes.Job().Apply(ctx, migrate); err == nil {
+		t.Fatal("existing job should not be replaced without AllowUpdate")
+	}
+
+	cleanup := toJobConfig("cleanup", "0 3 * * *")
+	cleanup.TimeZone = "Asia/Shanghai"
+	if err := kubernetes.Job().Apply(ctx, cleanup); err != nil {
+		t.Fatal(err)
+	}
+	cronJob, err := api.BatchV1().CronJobs("sandbox").Get(ctx, "cleanup", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cronJob.Spec.ConcurrencyPolicy != batchv1.ForbidConcurrent || *cronJob.Spec.TimeZone != "Asia/Shanghai" {
+		t.Fatalf("cronjob spec = %+v", cronJob.Spec)
+	}
+	cleanup.AllowUpdate = true
+	cleanup.Schedule = "0 4 * * *"
+	cleanup.ConcurrencyPolicy = "replace"
+	if err := kubernetes.Job().Apply(ctx, cleanup); err != nil {
+		t.Fatal(err)
+	}
+	cronJob, _ = api.BatchV1().CronJobs("sandbox").Get(ctx, "cleanup", v1.GetOptions{})
+	if cronJob.Spec.Schedule != "0 4 * * *" || cronJob.Spec.ConcurrencyPolicy != batchv1.ReplaceConcurrent {
+		t.Fatalf("cronjob spec = %+v", cronJob.Spec)
+	}
+
+	list, err := kubernetes.Job().List(ctx, "sandbox")
+	if err != nil || len(list) != 2 {
+		t.Fatalf("list = %v, err = %v", list, err)
+	}
+	if list[0].Kind != k8s.CronJobKind || list[0].Status != k8s.Active || list[1].Kind != k8s.JobKind || list[1].Status != k8s.Pending {
+		t.Fatalf("list = %+v, %+v", list[0], list[1])
+	}
+	list, err = kubernetes.Job().List(ctx, "sandbox", "migrate")
+	if err != nil || len(list) != 1 || list[0].Name != "migrate" {
+		t.Fatalf("list = %v, err = %v", list, err)
+	}
+
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	if _, err := api.BatchV1().Jobs("sandbox").UpdateStatus(ctx, job, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := kubernetes.Job().Wait(ctx, "sandbox", "migrate", time.Second)
+	if err != nil || got.Status != k8s.Succeeded {
+		t.Fatalf("job = %+v, err = %v", got, err)
+	}
+}
+
+func TestFakeJobTrigger(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	if _, err := kubernetes.Job().Trigger(ctx, "sandbox", "cleanup"); err == nil {
+		t.Fatal("trigger of missing cronjob should fail")
+	}
+	if err := kubernetes.Job().Apply(ctx, toJobConfig("cleanup", "0 3 * * *")); err != nil {
+		t.Fatal(err)
+	}
+	cronJob, err := api.BatchV1().CronJobs("sandbox").Get(ctx, "cleanup", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := kubernetes.Job().Trigger(ctx, "sandbox", "cleanup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一秒内多次触发不冲突
+	again, err := kubernetes.Job().Trigger(ctx, "sandbox", "cleanup")
+	if err != nil || again.Name == job.Name {
+		t.Fatalf("second trigger = %v, err = %v", again, err)
+	}
+	created, err := api.BatchV1().Jobs("sandbox").Get(ctx, job.Name, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created.OwnerReferences) != 1 || created.OwnerReferences[0].Name != "cleanup" ||
+		created.Annotations[k8s.AnnotationInstantiate] != "manual" ||
+		created.Labels[types.LabelGroup.String()] != cronJob.Spec.JobTemplate.Labels[types.LabelGroup.String()] ||
+		len(created.Spec.Template.Spec.Containers) != 1 {
+		t.Fatalf("triggered job = %+v", created)
+	}
+}
+
+func TestFakeJobLatestRun(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	if err := kubernetes.Job().Apply(ctx, toJobConfig("cleanup", "0 3 * * *")); err != nil {
+		t.Fatal(err)
+	}
+	cronJob, err := api.BatchV1().CronJobs("sandbox").Get(ctx, "cleanup", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	run := func(name, owner string, age time.Duration) {
+		job := &batchv1.Job{ObjectMeta: v1.ObjectMeta{
+			Namespace:         "sandbox",
+			Name:              name,
+			Labels:            cronJob.Spec.JobTemplate.Labels,
+			CreationTimestamp: v1.NewTime(now.Add(-age)),
+			OwnerReferences:   []v1.OwnerReference{{Kind: k8s.CronJobKind, Name: owner}},
+		}}
+		if _, err := api.BatchV1().Jobs("sandbox").Create(ctx, job, v1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := kubernetes.Job().Logger(ctx, "sandbox", "cleanup", "cleanup", k8s.ProcessLogger{}); err == nil {
+		t.Fatal("cronjob without runs should have no logs")
+	}
+	run("cleanup-old", "cleanup", 2*time.Hour)
+	run("cleanup-new", "cleanup", time.Hour)
+	// 相同标签但属于其他 CronJob 的 Job 不应被选中
+	run("other-run", "other", time.Minute)
+	pod := fakePod("sandbox", "cleanup", "cleanup-new-x1", corev1.PodSucceeded)
+	pod.Labels[k8s.LabelJobName] = "cleanup-new"
+	if _, err := api.CoreV1().Pods("sandbox").Create(ctx, pod, v1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := kubernetes.Job().Logger(ctx, "sandbox", "cleanup", "cleanup", k8s.ProcessLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if data, _ := io.ReadAll(stream); len(data) == 0 {
+		t.Fatal("logs should not be empty")
+	}
+
+	// 按 CronJob 名称等待最近一次运行
+	latest, _ := api.BatchV1().Jobs("sandbox").Get(ctx, "cleanup-new", v1.GetOptions{})
+	latest.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "boom"}}
+	if _, err := api.BatchV1().Jobs("sandbox").UpdateStatus(ctx, latest, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := kubernetes.Job().Wait(ctx, "sandbox", "cleanup", time.Second)
+	if err != nil || got.Name != "cleanup-new" || got.Status != k8s.Failed {
+		t.Fatalf("job = %+v, err = %v", got, err)
+	}
+}
+
+func TestFakeJobStorage(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toJobConfig("migrate", "")
+	storage := toStorage()
+	config.Storage = []types.Storage{storage}
+	config.Process[0].Mounts = []types.Mount{{Name: storage.Name, Path: "/data"}}
+	if err := kubernetes.Job().Apply(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.CoreV1().PersistentVolumeClaims("sandbox").Get(ctx, storage.Name, v1.GetOptions{}); err != nil {
+		t.Fatalf("pvc not created: %v", err)
+	}
+	job, err := api.BatchV1().Jobs("sandbox").Get(ctx, "migrate", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range job.Spec.Template.Spec.Volumes {
+		if v.PersistentVolumeClaim != nil && v.PersistentVolumeClaim.ClaimName == storage.Name {
+			return
+		}
+	}
+	t.Fatalf("claim volume not mounted: %+v", job.Spec.Template.Spec.Volumes)
+}
+
+func TestFakeJobWaitDeleted(t *testing.T) {
+	kubernetes, _ := fakeClient(&batchv1.Job{ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "migrate"}})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := kubernetes.Job().WaitDeleted(ctx, "sandbox", "migrate", time.Minute); err == nil {
+		t.Fatal("wait should stop when context is done")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("wait ignored context: %v", elapsed)
+	}
+	if err := kubernetes.Job().WaitDeleted(context.Background(), "sandbox", "missing", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,215 @@
+package test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hosgf/element/client/k8s"
+	"github.com/hosgf/element/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func rolloutLabels(group string) map[string]string {
+	return map[string]string{types.LabelGroup.String(): group}
+}
+
+func rolloutTemplate(group, image string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: v1.ObjectMeta{Labels: rolloutLabels(group)},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: group, Image: image}}},
+	}
+}
+
+func rolloutDeployment(group string, revision string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:   "sandbox",
+			Name:        group,
+			UID:         k8stypes.UID(group + "-uid"),
+			Labels:      rolloutLabels(group),
+			Annotations: map[string]string{k8s.AnnotationRevision: revision},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &v1.LabelSelector{MatchLabels: rolloutLabels(group)},
+			Template: rolloutTemplate(group, "nginx:3"),
+		},
+	}
+}
+
+func replicaSet(group, name, revision, image string, owner k8stypes.UID, created time.Time) *appsv1.ReplicaSet {
+	template := rolloutTemplate(group, image)
+	template.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = name
+	return &appsv1.ReplicaSet{
+		ObjectMeta: v1.ObjectMeta{
+			Namespace:         "sandbox",
+			Name:              name,
+			Labels:            rolloutLabels(group),
+			CreationTimestamp: v1.NewTime(created),
+			Annotations: map[string]string{
+				k8s.AnnotationRevision:    revision,
+				k8s.AnnotationChangeCause: "release " + revision,
+			},
+			OwnerReferences: []v1.OwnerReference{{Kind: "Deployment", Name: group, UID: owner}},
+		},
+		Spec: appsv1.ReplicaSetSpec{Template: template},
+	}
+}
+
+func TestFakeRolloutStatus(t *testing.T) {
+	ctx := context.Background()
+	d := rolloutDeployment("web", "3", 2)
+	d.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1, ReadyReplicas: 1, AvailableReplicas: 1}
+	crash := fakePod("sandbox", "web", "web-crash", corev1.PodRunning)
+	crash.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "web",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "not found"}},
+	}}
+	pending := fakePod("sandbox", "web", "web-pending", corev1.PodPending)
+	event := &corev1.Event{
+		ObjectMeta:     v1.ObjectMeta{Namespace: "sandbox", Name: "web-pending.1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "sandbox", Name: "web-pending"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         "FailedScheduling",
+		Message:        "0/3 nodes are available",
+		LastTimestamp:  v1.Now(),
+	}
+	kubernetes, api := fakeClient(d, crash, pending, event)
+
+	status, err := kubernetes.Rollout().Status(ctx, "sandbox", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Phase != k8s.RolloutProgressing || status.Revision != 3 || status.Desired != 2 || status.Updated != 1 {
+		t.Fatalf("status = %+v", status)
+	}
+	reasons := map[string]*k8s.RolloutReason{}
+	for _, r := range status.Reasons {
+		reasons[r.Pod] = r
+	}
+	if r := reasons["web-crash"]; r == nil || r.Reason != "ImagePullBackOff" || r.Process != "web" || !r.Fatal {
+		t.Fatalf("crash reason = %+v", r)
+	}
+	if r := reasons["web-pending"]; r == nil || r.Reason != "FailedScheduling" || !r.Fatal {
+		t.Fatalf("pending reason = %+v", r)
+	}
+	if _, err := kubernetes.Rollout().Wait(ctx, "sandbox", "web", k8s.RolloutOptions{FailFast: true, Interval: time.Millisecond}); err == nil {
+		t.Fatal("wait should fail fast on fatal reasons")
+	}
+
+	d.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded", Message: "deadline exceeded",
+	}}
+	if _, err := api.AppsV1().Deployments("sandbox").UpdateStatus(ctx, d, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ = kubernetes.Rollout().Status(ctx, "sandbox", "web"); status.Phase != k8s.RolloutFailed {
+		t.Fatalf("status = %+v", status)
+	}
+
+	d.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2}
+	if _, err := api.AppsV1().Deployments("sandbox").UpdateStatus(ctx, d, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	status, err = kubernetes.Rollout().Wait(ctx, "sandbox", "web", k8s.RolloutOptions{Interval: time.Millisecond})
+	if err != nil || !status.IsComplete() || len(status.Reasons) != 0 {
+		t.Fatalf("status = %+v, err = %v", status, err)
+	}
+}
+
+func TestFakeRolloutHistoryAndRollback(t *testing.T) {
+	ctx := context.Background()
+	d := rolloutDeployment("web", "3", 2)
+	now := time.Now()
+	kubernetes, api := fakeClient(d,
+		replicaSet("web", "web-3", "3", "nginx:3", d.UID, now),
+		replicaSet("web", "web-1", "1", "nginx:1", d.UID, now.Add(-2*time.Hour)),
+		replicaSet("web", "web-2", "2", "nginx:2", d.UID, now.Add(-time.Hour)),
+		// 标签相同但不属于该 Deployment 的 ReplicaSet
+		replicaSet("web", "web-x", "9", "nginx:9", "other", now),
+	)
+
+	history, err := kubernetes.Rollout().History(ctx, "sandbox", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("history = %d, want 3", len(history))
+	}
+	for i, r := range history {
+		if r.Revision != int64(i+1) || r.Images["web"] != "nginx:"+strconv.Itoa(i+1) || r.Current != (i == 2) {
+			t.Fatalf("history[%d] = %+v", i, r)
+		}
+	}
+
+	if err := kubernetes.Rollout().Rollback(ctx, "sandbox", "web", 1); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := api.AppsV1().Deployments("sandbox").Get(ctx, "web", v1.GetOptions{})
+	if got.Spec.Template.Spec.Containers[0].Image != "nginx:1" || got.Annotations[k8s.AnnotationChangeCause] != "release 1" {
+		t.Fatalf("rollback to 1 = %+v", got.Spec.Template)
+	}
+	if _, ok := got.Spec.Template.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+		t.Fatal("pod-template-hash should not be copied into the deployment template")
+	}
+
+	if err := kubernetes.Rollout().Rollback(ctx, "sandbox", "web", 0); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = api.AppsV1().Deployments("sandbox").Get(ctx, "web", v1.GetOptions{})
+	if got.Spec.Template.Spec.Containers[0].Image != "nginx:2" {
+		t.Fatalf("rollback to previous = %+v", got.Spec.Template)
+	}
+	if err := kubernetes.Rollout().Rollback(ctx, "sandbox", "web", 9); err == nil {
+		t.Fatal("rollback to a foreign revision should fail")
+	}
+}
+
+func TestFakeRolloutStatefulSetHistory(t *testing.T) {
+	ctx := context.Background()
+	replicas := int32(1)
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: v1.ObjectMeta{Namespace: "sandbox", Name: "db", UID: "db-uid", Labels: rolloutLabels("db")},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &v1.LabelSelector{MatchLabels: rolloutLabels("db")},
+			Template: rolloutTemplate("db", "mysql:8"),
+		},
+		Status: appsv1.StatefulSetStatus{UpdateRevision: "db-b", CurrentRevision: "db-b"},
+	}
+	revision := func(name string, number int64, image string) *appsv1.ControllerRevision {
+		return &appsv1.ControllerRevision{
+			ObjectMeta: v1.ObjectMeta{
+				Namespace:       "sandbox",
+				Name:            name,
+				Labels:          rolloutLabels("db"),
+				OwnerReferences: []v1.OwnerReference{{Kind: "StatefulSet", Name: "db", UID: sts.UID}},
+			},
+			Revision: number,
+			Data: runtime.RawExtension{Raw: []byte(
+				`{"spec":{"template":{"spec":{"containers":[{"name":"db","image":"` + image + `"}]}}}}`)},
+		}
+	}
+	kubernetes, api := fakeClient(sts, revision("db-b", 2, "mysql:8"), revision("db-a", 1, "mysql:5"))
+
+	history, err := kubernetes.Rollout().History(ctx, "sandbox", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Name != "db-a" || history[1].Name != "db-b" || !history[1].Current {
+		t.Fatalf("history = %+v, %+v", history[0], history[1])
+	}
+	if err := kubernetes.Rollout().Rollback(ctx, "sandbox", "db", 0); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := api.AppsV1().StatefulSets("sandbox").Get(ctx, "db", v1.GetOptions{})
+	if got.Spec.Template.Spec.Containers[0].Image != "mysql:5" {
+		t.Fatalf("rollback = %+v", got.Spec.Template)
+	}
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hosgf/element/client/k8s"
+	"github.com/hosgf/element/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFakeStatefulSet(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Kind = k8s.WorkloadStatefulSet
+	config.Replicas = 3
+	storage := toStorage()
+	config.Storage = []types.Storage{storage}
+	config.Process[0].Mounts = []types.Mount{{Name: storage.Name, Path: "/data"}}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	sts, err := api.AppsV1().StatefulSets(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *sts.Spec.Replicas != 3 || len(sts.Spec.VolumeClaimTemplates) != 1 {
+		t.Fatalf("statefulset spec = %+v", sts.Spec)
+	}
+	claim := sts.Spec.VolumeClaimTemplates[0]
+	if claim.Name != storage.Name || claim.Namespace != "" || claim.Spec.VolumeName != "" ||
+		claim.Spec.Resources.Requests.Storage().String() != storage.Size {
+		t.Fatalf("claim template = %+v", claim)
+	}
+	for _, v := range sts.Spec.Template.Spec.Volumes {
+		if v.Name == storage.Name {
+			t.Fatalf("claim template should not be a pod volume: %+v", v)
+		}
+	}
+	if _, err := api.CoreV1().PersistentVolumeClaims(config.Namespace).Get(ctx, storage.Name, v1.GetOptions{}); err == nil {
+		t.Fatal("shared pvc should not be created for claim templates")
+	}
+	if _, err := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{}); err == nil {
+		t.Fatal("deployment should not be created for statefulset")
+	}
+
+	headless, err := api.CoreV1().Services(config.Namespace).Get(ctx, sts.Spec.ServiceName, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("headless service not created: %v", err)
+	}
+	if headless.Spec.ClusterIP != corev1.ClusterIPNone || headless.Spec.Selector[types.LabelGroup.String()] != config.GroupName ||
+		len(headless.OwnerReferences) != 1 || headless.OwnerReferences[0].Kind != k8s.WorkloadStatefulSet.String() {
+		t.Fatalf("headless service = %+v", headless)
+	}
+	services, err := kubernetes.Service().List(ctx, config.Namespace, config.GroupName)
+	if err != nil || len(services) != 1 {
+		t.Fatalf("group services = %v, err = %v", services, err)
+	}
+
+	// 再次启动时已存在的无头 Service 保持不变
+	if err := kubernetes.Process().Running(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := kubernetes.Pod().Workloads(ctx, config.Namespace, config.GroupName)
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("workloads = %v, err = %v", pods, err)
+	}
+	pod := pods[0]
+	if pod.Kind != k8s.WorkloadStatefulSet || pod.Replicas != 3 || len(pod.Storage) != 1 {
+		t.Fatalf("workload = %+v", pod)
+	}
+	if s := pod.Storage[0]; s.Name != storage.Name || s.Size != storage.Size || s.AccessMode != storage.AccessMode || s.Item != storage.Item {
+		t.Fatalf("storage = %+v", s)
+	}
+	if len(pod.Containers) != 1 || pod.Containers[0].Mounts[0].Path != "/data" {
+		t.Fatalf("containers = %+v", pod.Containers)
+	}
+
+	// 已有 StatefulSet 时不能直接切换为 Deployment
+	config.Kind = k8s.WorkloadDeployment
+	config.AllowUpdate = true
+	if err := kubernetes.Process().Running(ctx, config); err == nil {
+		t.Fatal("workload kind change should be rejected")
+	}
+	if _, err := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{}); err == nil {
+		t.Fatal("deployment should not be created next to the statefulset")
+	}
+}
+
+func TestFakeDaemonSet(t *testing.T) {
+	ctx := context.Background()
+	kubernetes, api := fakeClient()
+	config := toProcessGroupConfig()
+	config.Kind = k8s.WorkloadDaemonSet
+	config.Scheduling = &k8s.SchedulingConfig{
+		Tolerations: []k8s.Toleration{{Operator: "Exists"}},
+	}
+	if err := kubernetes.Process().Start(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	ds, err := api.AppsV1().DaemonSets(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Spec.Selector.MatchLabels[types.LabelGroup.String()] != config.GroupName ||
+		len(ds.Spec.Template.Spec.Tolerations) != 1 || len(ds.Spec.Template.Spec.Containers) != 1 {
+		t.Fatalf("daemonset spec = %+v", ds.Spec)
+	}
+	if _, err := api.AppsV1().Deployments(config.Namespace).Get(ctx, config.GroupName, v1.GetOptions{}); err == nil {
+		t.Fatal("deployment should not be created for daemonset")
+	}
+
+	ds.Status = appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: 2}
+	if _, err := api.AppsV1().DaemonSets(config.Namespace).UpdateStatus(ctx, ds, v1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pods, err := kubernetes.Pod().Workloads(ctx, config.Namespace, config.GroupName)
+	if err != nil || len(pods) != 1 {
+		t.Fatalf("workloads = %v, err = %v", pods, err)
+	}
+	pod := pods[0]
+	if pod.Kind != k8s.WorkloadDaemonSet || pod.Replicas != 2 || pod.Status != k8s.Running ||
+		pod.Scheduling == nil || pod.Scheduling.Tolerations[0].Operator != "Exists" {
+		t.Fatalf("workload = %+v", pod)
+	}
+}
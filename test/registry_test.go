@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hosgf/element/registry"
+)
+
+func TestRegistryAuthSign(t *testing.T) {
+	auth := &registry.AuthConfig{Enabled: true, AppCode: "element", Secret: "s3cret"}
+	payload := auth.NewAuthPayload()
+	if payload.Signature == "" || payload.Token != "" {
+		t.Fatalf("expected signature only, got %+v", payload)
+	}
+	if !registry.VerifySign("s3cret", payload) {
+		t.Fatal("signature verify failed")
+	}
+	if registry.VerifySign("other", payload) {
+		t.Fatal("signature verified with wrong secret")
+	}
+}
+
+func TestRegistryAuthToken(t *testing.T) {
+	auth := &registry.AuthConfig{Enabled: true, AppCode: "element", Token: "token"}
+	payload := auth.NewAuthPayload()
+	if payload.Token != "token" || payload.Signature != "" {
+		t.Fatalf("expected token only, got %+v", payload)
+	}
+}
+
+func TestRegistryQueueOverflow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := registry.NewClient(ctx, &registry.ClientConfig{
+		Enabled: true,
+		Address: "127.0.0.1:0",
+		Queue:   &registry.QueueConfig{Size: 1, Overflow: registry.OverflowError},
+	})
+	var err error
+	sent := 0
+	for ; sent < 3; sent++ {
+		if err = client.SendData(ctx, "data"); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, registry.ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	metrics := client.Metrics()
+	if metrics.Dropped != 1 || metrics.Enqueued != int64(sent) {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestRegistryEndpointFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := registry.NewClient(ctx, &registry.ClientConfig{
+		Enabled:   true,
+		Address:   "127.0.0.1:1",
+		Addresses: []string{"127.0.0.1:2"},
+		Strategy:  registry.StrategyFailover,
+	})
+	if err := client.Run(false); err == nil {
+		t.Fatal("expected connect error")
+	}
+	if err := client.Run(false); err == nil {
+		t.Fatal("expected connect error")
+	}
+	endpoints := client.Endpoints()
+	if len(endpoints) != 2 || endpoints[0].Failures != 1 || endpoints[1].Failures != 1 {
+		t.Fatalf("unexpected endpoints: %+v", endpoints)
+	}
+}
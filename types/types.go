@@ -166,6 +166,7 @@ type Storage struct {
 	Type       StorageType     `json:"type,omitempty"`       // 存储类型,config , volume , pvc
 	AccessMode AccessMode      `json:"accessMode,omitempty"` // 访问模式
 	Size       string          `json:"size,omitempty"`       // 存储大小
+	Capacity   string          `json:"capacity,omitempty"`   // 实际容量,查询时返回,扩容未完成时小于存储大小
 	Path       string          `json:"path,omitempty"`       // 存储路径
 	Item       interface{}     `json:"item,omitempty"`       // 存储详情,
 	Resource   StorageResource `json:"resource,omitempty"`   // 存储资源，可为空